@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/lmullen/cchc/common/config"
+	"github.com/lmullen/cchc/common/db"
+	"github.com/lmullen/cchc/common/health"
+	"github.com/lmullen/cchc/common/items"
+	"github.com/lmullen/cchc/common/metrics"
+	"github.com/lmullen/cchc/common/results"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// shutdownTimeout is how long requests in progress have to finish when the API
+// is shutting down.
+const shutdownTimeout = 15 * time.Second
+
+// The Config type stores the shared configuration, along with settings for the
+// API which are read from environment variables.
+type Config struct {
+	*config.Config
+	addr string
+}
+
+// The App type shares access to the database and other resources.
+type App struct {
+	DB          *pgxpool.Pool
+	Metrics     *http.Server
+	Health      *health.Checker
+	Config      *Config
+	ItemsRepo   items.Repository
+	ResultsRepo results.Repository
+}
+
+// Init creates a new app and connects to the database or returns an error
+func (app *App) Init(ctx context.Context) error {
+	// Set a timeout for getting the application set up
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	// Read the shared configuration from a file, environment variables, and flags
+	app.Config = &Config{Config: config.Default()}
+	err := app.Config.Load("cchc-api", os.Args[1:])
+	if err != nil {
+		return err
+	}
+	err = app.Config.SetupLogging("cchc-api")
+	if err != nil {
+		return err
+	}
+	log.Info("Starting the API")
+	app.Config.Log()
+
+	// Set the address the API listens on
+	addr, ok := os.LookupEnv("CCHC_API_ADDR")
+	if !ok || addr == "" {
+		addr = ":8090"
+	}
+	app.Config.addr = addr
+
+	// Connect to the database and create the various repositories needed
+	pool, err := db.Connect(ctx, app.Config.DBStr, "cchc-api")
+	if err != nil {
+		return err
+	}
+	app.DB = pool
+	app.ItemsRepo = items.NewItemRepo(pool)
+	app.ResultsRepo = results.NewRepo(pool)
+	log.Info("Connected to the database successfully")
+
+	// Make sure the database schema is the version this application expects.
+	// The API only reads from the database, so it never migrates it.
+	err = db.CheckSchema(ctx, app.Config.DBStr, false)
+	if err != nil {
+		return err
+	}
+	log.WithField("version", db.RequiredVersion).Info("The database schema is at the required version")
+
+	// Serve metrics about the database for Prometheus, along with health checks.
+	// The API has no main loop, so it only reports progress if a window is set.
+	err = metrics.RegisterPool(pool)
+	if err != nil {
+		return err
+	}
+	app.Health = health.New(pool.Ping, app.Config.ProgressWindow, 0)
+	mux := http.NewServeMux()
+	app.Health.Register(mux)
+	app.Metrics, err = metrics.Serve(app.Config.MetricsAddr, mux)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Serve serves the API until the context is canceled, then waits for requests
+// in progress to finish.
+func (app *App) Serve(ctx context.Context) error {
+	s := newServer(app.ItemsRepo, app.ResultsRepo, app.Config.DBTimeout)
+	srv := &http.Server{
+		Addr:              app.Config.addr,
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		timeout, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err := srv.Shutdown(timeout)
+		if err != nil {
+			log.WithError(err).Error("Error stopping the API")
+		}
+	}()
+
+	log.WithField("addr", app.Config.addr).Info("Serving the API")
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops serving metrics and closes the connection to the database.
+func (app *App) Shutdown() {
+	if app.Metrics != nil {
+		app.Metrics.Close()
+	}
+	log.Info("Closing the connection to the database")
+	app.DB.Close()
+	log.Info("Shutdown the API")
+}
@@ -0,0 +1,48 @@
+// This program serves a read-only JSON API over the items, collections, and
+// results in the database.
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var app = &App{}
+
+func main() {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	// Clean up function that will be called at program end no matter what
+	defer func() {
+		signal.Stop(quit)
+		cancel()
+	}()
+	// Listen for shutdown signals in a go-routine and cancel context then
+	go func() {
+		select {
+		case <-quit:
+			log.Info("Shutdown signal received; quitting API")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := app.Init(ctx)
+	if err != nil {
+		log.Fatal("Error initializing application: ", err)
+	}
+	defer app.Shutdown()
+	app.Health.Ready(ctx)
+
+	err = app.Serve(ctx)
+	if err != nil {
+		log.WithError(err).Error("Error serving the API")
+	}
+
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lmullen/cchc/common/items"
+	"github.com/lmullen/cchc/common/results"
+)
+
+// The types in this file are the JSON representations of the models, which
+// leave out null values rather than exposing how they are stored.
+
+type itemJSON struct {
+	ID        string         `json:"id"`
+	URL       *string        `json:"url,omitempty"`
+	Title     *string        `json:"title,omitempty"`
+	Year      *int32         `json:"year,omitempty"`
+	Date      *string        `json:"date,omitempty"`
+	Subjects  []string       `json:"subjects"`
+	Languages []string       `json:"languages"`
+	Updated   time.Time      `json:"updated"`
+	Resources []resourceJSON `json:"resources"`
+	Files     []fileJSON     `json:"files"`
+}
+
+type resourceJSON struct {
+	ResourceSeq  int     `json:"resource_seq"`
+	FullTextFile *string `json:"fulltext_file,omitempty"`
+	DJVUTextFile *string `json:"djvu_text_file,omitempty"`
+	Image        *string `json:"image,omitempty"`
+	PDF          *string `json:"pdf,omitempty"`
+	URL          *string `json:"url,omitempty"`
+	Caption      *string `json:"caption,omitempty"`
+}
+
+type fileJSON struct {
+	ResourceSeq     int     `json:"resource_seq"`
+	FileSeq         int     `json:"file_seq"`
+	FormatSeq       int     `json:"format_seq"`
+	Mimetype        *string `json:"mimetype,omitempty"`
+	FullText        *string `json:"fulltext,omitempty"`
+	FullTextService *string `json:"fulltext_service,omitempty"`
+	WordCoordinates *string `json:"word_coordinates,omitempty"`
+	URL             *string `json:"url,omitempty"`
+	Info            *string `json:"info,omitempty"`
+	Use             *string `json:"use,omitempty"`
+}
+
+func newItemJSON(item *items.Item) *itemJSON {
+	j := &itemJSON{
+		ID:        item.ID,
+		URL:       str(item.URL),
+		Title:     str(item.Title),
+		Date:      str(item.Date),
+		Subjects:  nonNil(item.Subjects),
+		Languages: nonNil(item.Languages),
+		Updated:   item.Updated,
+		Resources: make([]resourceJSON, 0, len(item.Resources)),
+		Files:     make([]fileJSON, 0, len(item.Files)),
+	}
+	if item.Year.Valid {
+		j.Year = &item.Year.Int32
+	}
+	for _, r := range item.Resources {
+		j.Resources = append(j.Resources, resourceJSON{
+			ResourceSeq:  r.ResourceSeq,
+			FullTextFile: str(r.FullTextFile),
+			DJVUTextFile: str(r.DJVUTextFile),
+			Image:        str(r.Image),
+			PDF:          str(r.PDF),
+			URL:          str(r.URL),
+			Caption:      str(r.Caption),
+		})
+	}
+	for _, f := range item.Files {
+		j.Files = append(j.Files, fileJSON{
+			ResourceSeq:     f.ResourceSeq,
+			FileSeq:         f.FileSeq,
+			FormatSeq:       f.FormatSeq,
+			Mimetype:        str(f.Mimetype),
+			FullText:        str(f.FullText),
+			FullTextService: str(f.FullTextService),
+			WordCoordinates: str(f.WordCoordinates),
+			URL:             str(f.URL),
+			Info:            str(f.Info),
+			Use:             str(f.Use),
+		})
+	}
+	return j
+}
+
+type collectionJSON struct {
+	ID          string   `json:"id"`
+	Title       *string  `json:"title,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	URL         *string  `json:"url,omitempty"`
+	Subjects    []string `json:"subjects"`
+	Count       *int32   `json:"count,omitempty"`
+	Items       int      `json:"items"`
+}
+
+func newCollectionJSON(c *items.Collection) *collectionJSON {
+	j := &collectionJSON{
+		ID:          c.ID,
+		Title:       str(c.Title),
+		Description: str(c.Description),
+		URL:         str(c.URL),
+		Subjects:    nonNil(c.Subjects),
+		Items:       c.Items,
+	}
+	if c.Count.Valid {
+		j.Count = &c.Count.Int32
+	}
+	return j
+}
+
+type quotationJSON struct {
+	JobID       uuid.UUID    `json:"job_id"`
+	ItemID      string       `json:"item_id"`
+	ReferenceID string       `json:"reference_id"`
+	VerseID     string       `json:"verse_id"`
+	Probability float64      `json:"probability"`
+	Translation string       `json:"translation"`
+	Passage     *passageJSON `json:"passage,omitempty"`
+}
+
+type passageJSON struct {
+	Page         int    `json:"page"`
+	StartChar    int    `json:"start_char"`
+	EndChar      int    `json:"end_char"`
+	Text         string `json:"text"`
+	Context      string `json:"context"`
+	ContextStart int    `json:"context_start"`
+}
+
+func newQuotationJSON(q *results.Quotation) *quotationJSON {
+	j := &quotationJSON{
+		JobID:       q.JobID,
+		ItemID:      q.ItemID,
+		ReferenceID: q.ReferenceID,
+		VerseID:     q.VerseID,
+		Probability: q.Probability,
+		Translation: q.Translation,
+	}
+	if p := q.Passage; p != nil {
+		j.Passage = &passageJSON{
+			Page:         p.Page,
+			StartChar:    p.StartChar,
+			EndChar:      p.EndChar,
+			Text:         p.Match(),
+			Context:      p.Context,
+			ContextStart: p.ContextStart,
+		}
+	}
+	return j
+}
+
+type quotationCountJSON struct {
+	ReferenceID  string  `json:"reference_id"`
+	Threshold    float64 `json:"threshold"`
+	CollectionID string  `json:"collection_id"`
+	Year         int     `json:"year"`
+	Items        int     `json:"items"`
+	Pages        int     `json:"pages"`
+	ItemsCounted int     `json:"items_counted"`
+	ItemsShare   float64 `json:"items_share"`
+}
+
+func newQuotationCountJSON(c *results.QuotationCount) *quotationCountJSON {
+	return &quotationCountJSON{
+		ReferenceID:  c.ReferenceID,
+		Threshold:    c.Threshold,
+		CollectionID: c.CollectionID,
+		Year:         c.Year,
+		Items:        c.Items,
+		Pages:        c.Pages,
+		ItemsCounted: c.ItemsCounted,
+		ItemsShare:   c.Share(),
+	}
+}
+
+type languageStatJSON struct {
+	ItemID    string `json:"item_id"`
+	Language  string `json:"lang"`
+	Sentences int    `json:"sentences"`
+}
+
+type languageTotalJSON struct {
+	Language  string `json:"lang"`
+	Items     int    `json:"items"`
+	Sentences int    `json:"sentences"`
+}
+
+// str returns a pointer to the string, or nil if it is null.
+func str(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+// nonNil returns an empty slice instead of nil, so that it is encoded as an
+// empty array rather than null.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
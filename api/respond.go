@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lmullen/cchc/common/logging"
+)
+
+// Limits on the number of results in a single page
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// page is a page of results, with a link to the next page if there is one.
+type page struct {
+	Results interface{} `json:"results"`
+	Limit   int         `json:"limit"`
+	Offset  int         `json:"offset"`
+	Next    string      `json:"next,omitempty"`
+}
+
+// pagination reads the limit and offset from the query string.
+func pagination(r *http.Request) (limit, offset int, err error) {
+	limit = defaultLimit
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return 0, 0, fmt.Errorf("limit must be an integer from 1 to %d", maxLimit)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be an integer of at least 0")
+		}
+	}
+	return limit, offset, nil
+}
+
+// newPage creates a page of results. The repository is asked for one more
+// result than the limit, so that we know whether there is a next page without
+// having to count every result.
+func newPage(r *http.Request, results []interface{}, limit, offset int) *page {
+	p := &page{Results: results, Limit: limit, Offset: offset}
+	if len(results) > limit {
+		p.Results = results[:limit]
+		q := r.URL.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(offset+limit))
+		p.Next = r.URL.Path + "?" + q.Encode()
+	}
+	return p
+}
+
+// writeJSON sends a response as JSON with an ETag computed from the response.
+// If the client already has the current version of the response, it is sent a
+// 304 Not Modified instead.
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Error encoding the response")
+		logging.From(r.Context()).WithError(err).Error("Error encoding the response as JSON")
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if matchesETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// matchesETag checks whether an If-None-Match header matches the ETag.
+func matchesETag(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeError sends an error message as JSON.
+func writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{msg})
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lmullen/cchc/common/items"
+	"github.com/lmullen/cchc/common/logging"
+	"github.com/lmullen/cchc/common/results"
+	log "github.com/sirupsen/logrus"
+)
+
+// server handles requests to the API using the repositories.
+type server struct {
+	items   items.Repository
+	results results.Repository
+	timeout time.Duration // How long each request may spend querying the database
+}
+
+func newServer(itemsRepo items.Repository, resultsRepo results.Repository, timeout time.Duration) *server {
+	return &server{items: itemsRepo, results: resultsRepo, timeout: timeout}
+}
+
+// routes returns the handler for every endpoint in the API.
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/items/", s.getItem)
+	mux.HandleFunc("/collections", s.listCollections)
+	mux.HandleFunc("/collections/", s.getCollection)
+	mux.HandleFunc("/quotations", s.listQuotations)
+	mux.HandleFunc("/quotations/timeseries", s.quotationTimeSeries)
+	mux.HandleFunc("/languages", s.listLanguageStats)
+	mux.HandleFunc("/languages/totals", s.languageTotals)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, http.StatusNotFound, "There is no such endpoint")
+	})
+	return logRequests(readOnly(mux))
+}
+
+// readOnly rejects any request which would modify a resource.
+func readOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, r, http.StatusMethodNotAllowed, "The API is read-only")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder keeps track of the status code sent in a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests gives each request its own correlation ID and logs it when it
+// has been served.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r = r.WithContext(logging.NewCorrelationID(r.Context()))
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		logging.From(r.Context()).WithFields(log.Fields{
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"query":    r.URL.RawQuery,
+			"code":     rec.status,
+			"duration": time.Since(start).String(),
+		}).Debug("Served API request")
+	})
+}
+
+// expandID turns the short form of an ID, such as mal1285100, into the full ID
+// used in the database, such as http://www.loc.gov/item/mal1285100/. Full IDs
+// are returned unchanged.
+func expandID(kind, id string) string {
+	if id == "" || strings.HasPrefix(id, "http://") || strings.HasPrefix(id, "https://") {
+		return id
+	}
+	return "http://www.loc.gov/" + kind + "/" + strings.Trim(id, "/") + "/"
+}
+
+// serverError logs an unexpected error and tells the client something went wrong.
+func serverError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	logging.From(r.Context()).WithError(err).Error(msg)
+	writeError(w, r, http.StatusInternalServerError, msg)
+}
+
+// getItem returns an item with its resources and files.
+func (s *server) getItem(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/items/")
+	if id == "" {
+		writeError(w, r, http.StatusNotFound, "An item ID is required")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	item, err := s.items.Get(ctx, expandID("item", id))
+	if errors.Is(err, items.ErrNotFound) {
+		writeError(w, r, http.StatusNotFound, "There is no item with that ID")
+		return
+	}
+	if err != nil {
+		serverError(w, r, err, "Error getting item")
+		return
+	}
+	writeJSON(w, r, newItemJSON(item))
+}
+
+// getCollection returns a collection with the number of its items.
+func (s *server) getCollection(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/collections/")
+	if id == "" {
+		s.listCollections(w, r)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	c, err := s.items.GetCollection(ctx, expandID("collections", id))
+	if errors.Is(err, items.ErrNotFound) {
+		writeError(w, r, http.StatusNotFound, "There is no collection with that ID")
+		return
+	}
+	if err != nil {
+		serverError(w, r, err, "Error getting collection")
+		return
+	}
+	writeJSON(w, r, newCollectionJSON(c))
+}
+
+// listCollections returns a page of collections with the number of their items.
+func (s *server) listCollections(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pagination(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	collections, err := s.items.ListCollections(ctx, limit+1, offset)
+	if err != nil {
+		serverError(w, r, err, "Error listing collections")
+		return
+	}
+	res := make([]interface{}, 0, len(collections))
+	for _, c := range collections {
+		res = append(res, newCollectionJSON(c))
+	}
+	writeJSON(w, r, newPage(r, res, limit, offset))
+}
+
+// listQuotations returns a page of quotations, filtered by verse, item, the
+// source and year of the item, and the probability of the quotation.
+func (s *server) listQuotations(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pagination(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := r.URL.Query()
+	f := results.QuotationFilter{
+		ReferenceID: q.Get("reference"),
+		VerseID:     q.Get("verse"),
+		Translation: strings.ToLower(q.Get("translation")),
+		ItemID:      expandID("item", q.Get("item")),
+		Source:      q.Get("source"),
+		Limit:       limit + 1,
+		Offset:      offset,
+	}
+	switch f.Source {
+	case "", "items":
+	case "stacks":
+		// Books from the Stacks are identified by their LCCN, not a loc.gov URL
+		f.ItemID = q.Get("item")
+	default:
+		writeError(w, r, http.StatusBadRequest, "source must be items or stacks")
+		return
+	}
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{{"year_from", &f.YearFrom}, {"year_to", &f.YearTo}} {
+		if v := q.Get(p.name); v != "" {
+			*p.value, err = strconv.Atoi(v)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, p.name+" must be a year")
+				return
+			}
+		}
+	}
+	if f.YearFrom != 0 && f.YearTo != 0 && f.YearFrom > f.YearTo {
+		writeError(w, r, http.StatusBadRequest, "year_from must not be after year_to")
+		return
+	}
+	if v := q.Get("min_probability"); v != "" {
+		f.MinProbability, err = strconv.ParseFloat(v, 64)
+		if err != nil || f.MinProbability < 0 || f.MinProbability > 1 {
+			writeError(w, r, http.StatusBadRequest, "min_probability must be a number from 0 to 1")
+			return
+		}
+	}
+	if v := q.Get("dedup"); v != "" {
+		f.Deduplicate, err = strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "dedup must be true or false")
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	quotations, err := s.results.Quotations(ctx, f)
+	if err != nil {
+		serverError(w, r, err, "Error listing quotations")
+		return
+	}
+	res := make([]interface{}, 0, len(quotations))
+	for _, q := range quotations {
+		res = append(res, newQuotationJSON(q))
+	}
+	writeJSON(w, r, newPage(r, res, limit, offset))
+}
+
+// quotationTimeSeries returns the number of items quoting a verse in each year
+// or decade, normalized by the number of items checked for quotations.
+func (s *server) quotationTimeSeries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := results.TimeSeriesFilter{ReferenceID: q.Get("reference")}
+	if f.ReferenceID == "" {
+		writeError(w, r, http.StatusBadRequest, "reference is required")
+		return
+	}
+	if c := q.Get("collection"); c != "" {
+		f.CollectionID = expandID("collections", c)
+	}
+	switch q.Get("by") {
+	case "", "year":
+	case "decade":
+		f.Decades = true
+	default:
+		writeError(w, r, http.StatusBadRequest, "by must be year or decade")
+		return
+	}
+	if v := q.Get("threshold"); v != "" {
+		var err error
+		f.Threshold, err = strconv.ParseFloat(v, 64)
+		if err != nil || f.Threshold <= 0 || f.Threshold > 1 {
+			writeError(w, r, http.StatusBadRequest, "threshold must be a number greater than 0 and at most 1")
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	counts, err := s.results.QuotationTimeSeries(ctx, f)
+	if err != nil {
+		serverError(w, r, err, "Error getting the quotation time series")
+		return
+	}
+	res := make([]*quotationCountJSON, 0, len(counts))
+	for _, c := range counts {
+		res = append(res, newQuotationCountJSON(c))
+	}
+	writeJSON(w, r, struct {
+		Results []*quotationCountJSON `json:"results"`
+	}{res})
+}
+
+// listLanguageStats returns a page of the number of sentences in each language
+// in each item, filtered by item or language, from the jobs for one
+// destination.
+func (s *server) listLanguageStats(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pagination(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := r.URL.Query()
+	f := results.LanguageFilter{
+		ItemID:      expandID("item", q.Get("item")),
+		Language:    strings.ToUpper(q.Get("lang")),
+		Destination: q.Get("destination"),
+		Limit:       limit + 1,
+		Offset:      offset,
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	stats, err := s.results.LanguageStats(ctx, f)
+	if err != nil {
+		serverError(w, r, err, "Error listing language statistics")
+		return
+	}
+	res := make([]interface{}, 0, len(stats))
+	for _, st := range stats {
+		res = append(res, &languageStatJSON{ItemID: st.ItemID, Language: st.Language, Sentences: st.Sentences})
+	}
+	writeJSON(w, r, newPage(r, res, limit, offset))
+}
+
+// languageTotals returns the number of items and sentences in each language,
+// from the jobs for one destination.
+func (s *server) languageTotals(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	totals, err := s.results.LanguageTotals(ctx, r.URL.Query().Get("destination"))
+	if err != nil {
+		serverError(w, r, err, "Error getting language totals")
+		return
+	}
+	res := make([]*languageTotalJSON, 0, len(totals))
+	for _, t := range totals {
+		res = append(res, &languageTotalJSON{Language: t.Language, Items: t.Items, Sentences: t.Sentences})
+	}
+	writeJSON(w, r, struct {
+		Results []*languageTotalJSON `json:"results"`
+	}{res})
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lmullen/cchc/common/items"
+	"github.com/lmullen/cchc/common/results"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeItems is an items repository with a single item and collection. Methods
+// which the API doesn't use are left to the embedded interface.
+type fakeItems struct {
+	items.Repository
+}
+
+func (fakeItems) Get(ctx context.Context, ID string) (*items.Item, error) {
+	if ID != "http://www.loc.gov/item/mal1285100/" {
+		return nil, items.ErrNotFound
+	}
+	return &items.Item{
+		ID:        ID,
+		Title:     sql.NullString{String: "Abraham Lincoln papers", Valid: true},
+		Year:      sql.NullInt32{Int32: 1861, Valid: true},
+		Resources: []items.ItemResource{{ItemID: ID, ResourceSeq: 0}},
+	}, nil
+}
+
+func (fakeItems) GetCollection(ctx context.Context, ID string) (*items.Collection, error) {
+	if ID != "http://www.loc.gov/collections/civil-war-maps/" {
+		return nil, items.ErrNotFound
+	}
+	return &items.Collection{ID: ID, Items: 2}, nil
+}
+
+func (fakeItems) ListCollections(ctx context.Context, limit, offset int) ([]*items.Collection, error) {
+	var cs []*items.Collection
+	for i := offset; i < 3 && len(cs) < limit; i++ {
+		cs = append(cs, &items.Collection{ID: string(rune('a' + i))})
+	}
+	return cs, nil
+}
+
+// fakeResults is a results repository which records the last filter it was
+// given.
+type fakeResults struct {
+	results.Repository
+	quotations results.QuotationFilter
+	timeSeries results.TimeSeriesFilter
+	languages  results.LanguageFilter
+	totals     string
+}
+
+func (f *fakeResults) Quotations(ctx context.Context, filter results.QuotationFilter) ([]*results.Quotation, error) {
+	f.quotations = filter
+	return []*results.Quotation{{
+		ItemID:      "http://www.loc.gov/item/mal1285100/",
+		VerseID:     "John 3:16",
+		Probability: 0.9,
+		Passage: &results.QuotedPassage{
+			Page:         2,
+			StartChar:    20,
+			EndChar:      36,
+			Context:      "the preacher said: for God so loved the world",
+			ContextStart: 1,
+		},
+	}}, nil
+}
+
+func (f *fakeResults) QuotationTimeSeries(ctx context.Context, filter results.TimeSeriesFilter) ([]*results.QuotationCount, error) {
+	f.timeSeries = filter
+	return []*results.QuotationCount{{ReferenceID: filter.ReferenceID, Year: 1860, Items: 3, ItemsCounted: 12}}, nil
+}
+
+func (f *fakeResults) LanguageStats(ctx context.Context, filter results.LanguageFilter) ([]*results.LanguageStat, error) {
+	f.languages = filter
+	return []*results.LanguageStat{{ItemID: "http://www.loc.gov/item/mal1285100/", Language: "ENG", Sentences: 10}}, nil
+}
+
+func (f *fakeResults) LanguageTotals(ctx context.Context, destination string) ([]*results.LanguageTotal, error) {
+	f.totals = destination
+	return []*results.LanguageTotal{{Language: "ENG", Items: 1, Sentences: 10}}, nil
+}
+
+func request(h http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestItems(t *testing.T) {
+	h := newServer(fakeItems{}, &fakeResults{}, time.Second).routes()
+
+	rec := request(h, "GET", "/items/mal1285100", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	item := &itemJSON{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), item))
+	assert.Equal(t, "http://www.loc.gov/item/mal1285100/", item.ID)
+	assert.Equal(t, int32(1861), *item.Year)
+	assert.Nil(t, item.URL)
+	assert.Len(t, item.Resources, 1)
+	assert.Equal(t, []fileJSON{}, item.Files)
+
+	rec = request(h, "GET", "/items/missing", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = request(h, "POST", "/items/mal1285100", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestCollections(t *testing.T) {
+	h := newServer(fakeItems{}, &fakeResults{}, time.Second).routes()
+
+	rec := request(h, "GET", "/collections/civil-war-maps", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"items":2`)
+
+	// Paginate through the collections
+	rec = request(h, "GET", "/collections?limit=2", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	p := &page{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), p))
+	assert.Len(t, p.Results, 2)
+	assert.Equal(t, "/collections?limit=2&offset=2", p.Next)
+
+	rec = request(h, "GET", p.Next, nil)
+	p = &page{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), p))
+	assert.Len(t, p.Results, 1)
+	assert.Empty(t, p.Next)
+
+	rec = request(h, "GET", "/collections?limit=5000", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestQuotations(t *testing.T) {
+	res := &fakeResults{}
+	h := newServer(fakeItems{}, res, time.Second).routes()
+
+	rec := request(h, "GET", "/quotations?reference=John+3:16&verse=John+3:16+(KJV)&translation=KJV&item=mal1285100&year_from=1850&year_to=1870&min_probability=0.5&offset=10", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, results.QuotationFilter{
+		ReferenceID:    "John 3:16",
+		VerseID:        "John 3:16 (KJV)",
+		Translation:    "kjv",
+		ItemID:         "http://www.loc.gov/item/mal1285100/",
+		YearFrom:       1850,
+		YearTo:         1870,
+		MinProbability: 0.5,
+		Limit:          defaultLimit + 1,
+		Offset:         10,
+	}, res.quotations)
+	assert.Contains(t, rec.Body.String(), `"text":"for God so loved"`)
+
+	rec = request(h, "GET", "/quotations?reference=John+3:16&dedup=true", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, res.quotations.Deduplicate)
+
+	rec = request(h, "GET", "/quotations?source=stacks&item=2004558432", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "stacks", res.quotations.Source)
+	assert.Equal(t, "2004558432", res.quotations.ItemID)
+
+	for _, bad := range []string{"year_from=1870&year_to=1850", "year_from=eighteen", "min_probability=2", "dedup=maybe", "source=books"} {
+		rec = request(h, "GET", "/quotations?"+bad, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, bad)
+	}
+}
+
+func TestQuotationTimeSeries(t *testing.T) {
+	res := &fakeResults{}
+	h := newServer(fakeItems{}, res, time.Second).routes()
+
+	rec := request(h, "GET", "/quotations/timeseries?reference=John+3:16&by=decade&threshold=0.9&collection=civil-war-maps", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, results.TimeSeriesFilter{
+		ReferenceID:  "John 3:16",
+		Threshold:    0.9,
+		CollectionID: "http://www.loc.gov/collections/civil-war-maps/",
+		Decades:      true,
+	}, res.timeSeries)
+	assert.Contains(t, rec.Body.String(), `"items_share":0.25`)
+
+	for _, bad := range []string{"", "reference=John+3:16&by=century", "reference=John+3:16&threshold=0"} {
+		rec = request(h, "GET", "/quotations/timeseries?"+bad, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, bad)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	res := &fakeResults{}
+	h := newServer(fakeItems{}, res, time.Second).routes()
+
+	rec := request(h, "GET", "/languages?lang=eng", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ENG", res.languages.Language)
+	assert.Empty(t, res.languages.Destination)
+	assert.Contains(t, rec.Body.String(), `"sentences":10`)
+
+	rec = request(h, "GET", "/languages?destination=language-whatlang", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "language-whatlang", res.languages.Destination)
+
+	rec = request(h, "GET", "/languages/totals?destination=language-whatlang", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "language-whatlang", res.totals)
+	assert.Contains(t, rec.Body.String(), `"items":1`)
+}
+
+func TestETag(t *testing.T) {
+	h := newServer(fakeItems{}, &fakeResults{}, time.Second).routes()
+
+	rec := request(h, "GET", "/items/mal1285100", nil)
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	rec = request(h, "GET", "/items/mal1285100", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = request(h, "GET", "/items/mal1285100", http.Header{"If-None-Match": {`"stale"`}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func Test_expandID(t *testing.T) {
+	assert.Equal(t, "http://www.loc.gov/item/mal1285100/", expandID("item", "mal1285100"))
+	assert.Equal(t, "http://www.loc.gov/item/mal1285100/", expandID("item", "http://www.loc.gov/item/mal1285100/"))
+	assert.Equal(t, "", expandID("item", ""))
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/lmullen/cchc/common/results"
+	"github.com/spf13/cobra"
+)
+
+var aggregateDestination string
+var aggregateThresholds []float64
+var aggregateRemove []float64
+var aggregateFull bool
+
+// aggregateCmd represents the aggregate command
+var aggregateCmd = &cobra.Command{
+	Use:   "aggregate",
+	Short: "Count quotations by verse, year, and collection",
+	Long: `Counts the items and pages quoting each verse in each year and collection,
+along with the number of items checked for quotations, so that the counts can
+be normalized. The counts are stored in the results.quotation_counts and
+results.quotation_items_counted tables, and the results.quotation_rates and
+results.quotation_rates_by_decade views.
+
+The counts are kept for each probability threshold. By default, only the jobs
+which have finished since the counts were last refreshed are added; the
+quotation detector does this after each batch. Use --threshold to start
+counting at a new threshold (or to recount an existing one), --full to recount
+every threshold, and --remove to stop counting at a threshold.
+
+The counts only include the jobs for one destination, since items searched for
+quotations in different reference texts can't be compared. By default, that is
+the destination of the jobs which are already counted, or quotations if none
+are. Counting another --destination removes the jobs for the old one from the
+counts.
+`,
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		repo := results.NewRepo(database)
+
+		destination, err := countsDestination(ctx, cmd, repo, aggregateDestination)
+		if err != nil {
+			fmt.Printf("Failed to get the destination of the counted jobs with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(22)
+		}
+
+		for _, t := range aggregateRemove {
+			err := repo.RemoveQuotationThreshold(ctx, t)
+			if err != nil {
+				fmt.Printf("Failed to remove the threshold %v with error:\n	%s\n", t, err)
+				shutdown(nil, nil)
+				os.Exit(22)
+			}
+			fmt.Printf("Removed the counts at the threshold %v\n", t)
+		}
+
+		reset := aggregateThresholds
+		if aggregateFull {
+			rows, err := database.Query(ctx, `SELECT threshold::numeric::double precision FROM results.quotation_thresholds;`)
+			if err != nil {
+				fmt.Printf("Failed to get the thresholds with error:\n	%s\n", err)
+				shutdown(nil, nil)
+				os.Exit(22)
+			}
+			for rows.Next() {
+				var t float64
+				err = rows.Scan(&t)
+				if err != nil {
+					fmt.Printf("Failed to get the thresholds with error:\n	%s\n", err)
+					shutdown(nil, nil)
+					os.Exit(22)
+				}
+				reset = append(reset, t)
+			}
+			rows.Close()
+		}
+		for _, t := range reset {
+			if t <= 0 || t > 1 {
+				fmt.Printf("The threshold must be greater than 0 and at most 1, not %v.\n", t)
+				shutdown(nil, nil)
+				os.Exit(22)
+			}
+			err := repo.ResetQuotationCounts(ctx, t)
+			if err != nil {
+				fmt.Printf("Failed to reset the counts at the threshold %v with error:\n	%s\n", t, err)
+				shutdown(nil, nil)
+				os.Exit(22)
+			}
+		}
+
+		fmt.Println("Counting quotations might take a long time ...")
+		n, err := repo.RefreshQuotationCounts(ctx, destination)
+		if err != nil {
+			fmt.Printf("Failed to count quotations with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(23)
+		}
+		fmt.Printf("Counted the quotations from %d jobs successfully\n", n)
+	},
+	PostRun: shutdown,
+}
+
+func init() {
+	rootCmd.AddCommand(aggregateCmd)
+	aggregateCmd.Flags().StringVarP(&aggregateDestination, "destination", "d", "quotations", "destination of the jobs which found the quotations, if not the one already counted")
+	aggregateCmd.Flags().Float64SliceVar(&aggregateThresholds, "threshold", nil, "count (or recount) at these probability thresholds (comma separated)")
+	aggregateCmd.Flags().Float64SliceVar(&aggregateRemove, "remove", nil, "stop counting at these probability thresholds (comma separated)")
+	aggregateCmd.Flags().BoolVar(&aggregateFull, "full", false, "recount every threshold from scratch")
+}
+
+// countsDestination returns the destination whose jobs should be counted. If
+// the destination flag was not set, it is the destination of the jobs which
+// are already counted, so that recounting doesn't switch to other jobs.
+func countsDestination(ctx context.Context, cmd *cobra.Command, repo results.Repository, flag string) (string, error) {
+	if cmd.Flags().Changed("destination") {
+		return flag, nil
+	}
+	counted, err := repo.CountedDestination(ctx)
+	if err != nil || counted == "" {
+		return flag, err
+	}
+	return counted, nil
+}
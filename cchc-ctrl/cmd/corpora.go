@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/lmullen/cchc/common/corpora"
+	"github.com/spf13/cobra"
+)
+
+var corpusTitle string
+var corpusDescription string
+var corpusReplace bool
+
+// corporaCmd represents the corpora command
+var corporaCmd = &cobra.Command{
+	Use:   "corpora",
+	Short: "Import and list reference corpora",
+	Long: `Reference corpora are collections of passages, such as the Constitution,
+the Declaration of Independence, or a hymnal, in which the quotation detector
+can look for quotations. Each passage has an ID which is unique within its
+corpus.
+
+To look for quotations of a corpus, run the quotation detector with CCHC_CORPUS
+set to the ID of the corpus. It builds the matching index for the corpus when it
+starts, creates jobs with the destination quotations-<corpus>, and saves the
+quotations it finds in the results.quotations table. The
+results.all_quotations view combines them with the biblical quotations.
+`,
+}
+
+// corporaImportCmd represents the corpora import command
+var corporaImportCmd = &cobra.Command{
+	Use:   "import <corpus> <passages.csv>",
+	Short: "Import the passages in a reference corpus",
+	Args:  cobra.ExactArgs(2),
+	Long: `Imports a reference corpus from a CSV file with a header and two columns:
+the ID of each passage and its text. For example:
+
+  passage_id,text
+  Preamble,"We the People of the United States, in Order to form ..."
+  Amendment I,"Congress shall make no law respecting an establishment ..."
+
+The ID of the corpus is lowercased, and bible is reserved for the biblical
+quotations. Importing a corpus which already exists updates the text of its
+passages and adds any new ones. Pass --replace to also remove the passages
+which are not in the file; passages which have already been quoted cannot be
+removed until the jobs which found them are purged. Rerun the quotation
+detector for the corpus so that it rebuilds the matching index.
+`,
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		repo := corpora.NewCorporaRepo(database)
+
+		corpus := &corpora.Corpus{ID: strings.ToLower(strings.TrimSpace(args[0]))}
+		if corpus.ID == "" {
+			fmt.Println("The ID of the corpus cannot be empty.")
+			shutdown(nil, nil)
+			os.Exit(29)
+		}
+		if corpusTitle != "" {
+			corpus.Title = sql.NullString{String: corpusTitle, Valid: true}
+		}
+		if corpusDescription != "" {
+			corpus.Description = sql.NullString{String: corpusDescription, Valid: true}
+		}
+
+		passages, err := readPassages(args[1])
+		if err != nil {
+			fmt.Printf("Failed to read the passages with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(29)
+		}
+
+		err = repo.Save(ctx, corpus, passages, corpusReplace)
+		if err != nil {
+			fmt.Printf("Failed to save the corpus with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(29)
+		}
+		fmt.Printf("Imported %d passages into the corpus %s successfully\n", len(passages), corpus.ID)
+	},
+	PostRun: shutdown,
+}
+
+// corporaListCmd represents the corpora list command
+var corporaListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the reference corpora",
+	Long: `Lists the reference corpora, with the number of passages in each and
+the number of quotations which have been found of them.
+`,
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := timeout()
+		defer cancel()
+		repo := corpora.NewCorporaRepo(database)
+
+		list, err := repo.List(ctx)
+		if err != nil {
+			fmt.Printf("Failed to list the corpora with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(30)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CORPUS\tPASSAGES\tQUOTATIONS\tUPDATED\tTITLE")
+		for _, c := range list {
+			var quotations int64
+			err = database.QueryRow(ctx, `SELECT COUNT(*) FROM results.quotations WHERE corpus_id = $1;`, c.ID).Scan(&quotations)
+			if err != nil {
+				fmt.Printf("Failed to count the quotations with error:\n	%s\n", err)
+				shutdown(nil, nil)
+				os.Exit(30)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", c.ID, c.Passages, quotations,
+				c.Updated.Format("2006-01-02"), c.Title.String)
+		}
+		w.Flush()
+	},
+	PostRun: shutdown,
+}
+
+func init() {
+	rootCmd.AddCommand(corporaCmd)
+	corporaCmd.AddCommand(corporaImportCmd, corporaListCmd)
+	corporaImportCmd.Flags().StringVar(&corpusTitle, "title", "", "title of the corpus")
+	corporaImportCmd.Flags().StringVar(&corpusDescription, "description", "", "description of the corpus")
+	corporaImportCmd.Flags().BoolVar(&corpusReplace, "replace", false, "remove the passages which are not in the file")
+}
+
+// readPassages reads a CSV file of passages, skipping the header.
+func readPassages(path string) ([]*corpora.Passage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	_, err = r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the header: %w", err)
+	}
+
+	var passages []*corpora.Passage
+	seen := make(map[string]bool)
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		id, text := strings.TrimSpace(row[0]), strings.TrimSpace(row[1])
+		if id == "" || text == "" {
+			return nil, fmt.Errorf("empty value on line %d", line)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("the passage %s is given more than once, on line %d", id, line)
+		}
+		seen[id] = true
+		passages = append(passages, &corpora.Passage{ID: id, Text: text})
+	}
+	if len(passages) == 0 {
+		return nil, errors.New("there are no passages in the file")
+	}
+	return passages, nil
+}
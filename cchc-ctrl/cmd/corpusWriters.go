@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// corpusDoc is a single document in an exported corpus, which is either an item
+// from the loc.gov collections or a book from the Stacks.
+type corpusDoc struct {
+	ID          string   `json:"id"`
+	Source      string   `json:"source"`
+	Title       string   `json:"title"`
+	Year        *int     `json:"year"`
+	Date        string   `json:"date,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	Languages   []string `json:"languages"`
+	Collections []string `json:"collections,omitempty"`
+	Pages       []string `json:"pages"`
+}
+
+// corpusWriter writes the documents in a corpus in some format
+type corpusWriter interface {
+	Write(doc *corpusDoc) error
+	Close() error
+}
+
+// corpusMetadataColumns are the columns in the metadata CSV for a corpus
+var corpusMetadataColumns = []string{"id", "file", "source", "title", "year", "date",
+	"url", "languages", "collections", "pages"}
+
+// unsafeFilename matches characters which should not be used in file names
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// newCorpusWriter returns a writer for the format. The text format writes to a
+// directory; the others write to a single file, or standard output if the path
+// is empty or -.
+func newCorpusWriter(format, path string) (corpusWriter, error) {
+	switch format {
+	case "text":
+		if path == "" || path == "-" {
+			return nil, fmt.Errorf("The text format needs a directory passed to --output")
+		}
+		return newTextCorpusWriter(path)
+	case "jsonl", "tei":
+		var out io.WriteCloser = os.Stdout
+		if path != "" && path != "-" {
+			f, err := os.Create(path)
+			if err != nil {
+				return nil, err
+			}
+			out = f
+		}
+		if format == "jsonl" {
+			return &jsonlCorpusWriter{out: out, enc: json.NewEncoder(out)}, nil
+		}
+		return newTEICorpusWriter(out)
+	default:
+		return nil, fmt.Errorf("%s is not a valid corpus format; use text, jsonl, or tei", format)
+	}
+}
+
+// docFilename turns the ID of a document into a file name
+func docFilename(doc *corpusDoc, ext string) string {
+	return doc.Source + "-" + strings.Trim(unsafeFilename.ReplaceAllString(doc.ID, "_"), "_") + ext
+}
+
+// metadataRecord returns the row in the metadata CSV for a document
+func metadataRecord(doc *corpusDoc, file string) []string {
+	year := ""
+	if doc.Year != nil {
+		year = strconv.Itoa(*doc.Year)
+	}
+	return []string{doc.ID, file, doc.Source, doc.Title, year, doc.Date, doc.URL,
+		strings.Join(doc.Languages, ";"), strings.Join(doc.Collections, ";"),
+		strconv.Itoa(len(doc.Pages))}
+}
+
+// textCorpusWriter writes each document as a plain text file in a directory,
+// along with a metadata.csv file describing the documents. Pages are separated
+// by form feeds.
+type textCorpusWriter struct {
+	dir  string
+	f    *os.File
+	meta *csv.Writer
+}
+
+func newTextCorpusWriter(dir string) (*textCorpusWriter, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filepath.Join(dir, "metadata.csv"))
+	if err != nil {
+		return nil, err
+	}
+	t := &textCorpusWriter{dir: dir, f: f, meta: csv.NewWriter(f)}
+	err = t.meta.Write(corpusMetadataColumns)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *textCorpusWriter) Write(doc *corpusDoc) error {
+	name := docFilename(doc, ".txt")
+	err := os.WriteFile(filepath.Join(t.dir, name), []byte(strings.Join(doc.Pages, "\n\f\n")), 0644)
+	if err != nil {
+		return err
+	}
+	return t.meta.Write(metadataRecord(doc, name))
+}
+
+func (t *textCorpusWriter) Close() error {
+	t.meta.Flush()
+	if err := t.meta.Error(); err != nil {
+		t.f.Close()
+		return err
+	}
+	return t.f.Close()
+}
+
+// jsonlCorpusWriter writes each document, with its metadata and pages, as a
+// JSON object on its own line
+type jsonlCorpusWriter struct {
+	out io.WriteCloser
+	enc *json.Encoder
+}
+
+func (j *jsonlCorpusWriter) Write(doc *corpusDoc) error {
+	return j.enc.Encode(doc)
+}
+
+func (j *jsonlCorpusWriter) Close() error {
+	if j.out == os.Stdout {
+		return nil
+	}
+	return j.out.Close()
+}
+
+// teiCorpusWriter writes a zip file containing a TEI-lite XML file for each
+// document, along with a metadata.csv file describing the documents
+type teiCorpusWriter struct {
+	out  io.WriteCloser
+	zw   *zip.Writer
+	rows [][]string
+}
+
+func newTEICorpusWriter(out io.WriteCloser) (*teiCorpusWriter, error) {
+	return &teiCorpusWriter{out: out, zw: zip.NewWriter(out)}, nil
+}
+
+func (t *teiCorpusWriter) Write(doc *corpusDoc) error {
+	name := docFilename(doc, ".xml")
+	w, err := t.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	err = writeTEI(w, doc)
+	if err != nil {
+		return err
+	}
+	t.rows = append(t.rows, metadataRecord(doc, name))
+	return nil
+}
+
+func (t *teiCorpusWriter) Close() error {
+	w, err := t.zw.Create("metadata.csv")
+	if err == nil {
+		meta := csv.NewWriter(w)
+		meta.Write(corpusMetadataColumns)
+		meta.WriteAll(t.rows)
+		err = meta.Error()
+	}
+	if err == nil {
+		err = t.zw.Close()
+	}
+	if t.out != os.Stdout {
+		errClose := t.out.Close()
+		if err == nil {
+			err = errClose
+		}
+	}
+	return err
+}
+
+// writeTEI writes a document in a minimal TEI layout: a header with the
+// bibliographic metadata, and a body with a div for each page and a paragraph
+// for each block of text separated by blank lines.
+func writeTEI(w io.Writer, doc *corpusDoc) error {
+	var b strings.Builder
+	esc := func(s string) string {
+		var e strings.Builder
+		xml.EscapeText(&e, []byte(s))
+		return e.String()
+	}
+
+	b.WriteString(xml.Header)
+	b.WriteString(`<TEI xmlns="http://www.tei-c.org/ns/1.0">` + "\n")
+	b.WriteString("  <teiHeader>\n    <fileDesc>\n")
+	b.WriteString("      <titleStmt><title>" + esc(doc.Title) + "</title></titleStmt>\n")
+	b.WriteString("      <publicationStmt><p>Exported from the CCHC database.</p></publicationStmt>\n")
+	b.WriteString("      <sourceDesc>\n        <bibl>\n")
+	b.WriteString("          <title>" + esc(doc.Title) + "</title>\n")
+	if doc.Year != nil {
+		date := doc.Date
+		if date == "" {
+			date = strconv.Itoa(*doc.Year)
+		}
+		b.WriteString(fmt.Sprintf("          <date when=\"%04d\">%s</date>\n", *doc.Year, esc(date)))
+	}
+	b.WriteString("          <idno type=\"" + esc(doc.Source) + "\">" + esc(doc.ID) + "</idno>\n")
+	if doc.URL != "" {
+		b.WriteString("          <ref target=\"" + esc(doc.URL) + "\"/>\n")
+	}
+	b.WriteString("        </bibl>\n      </sourceDesc>\n    </fileDesc>\n")
+	if len(doc.Languages) > 0 {
+		b.WriteString("    <profileDesc>\n      <langUsage>\n")
+		for _, l := range doc.Languages {
+			b.WriteString("        <language>" + esc(l) + "</language>\n")
+		}
+		b.WriteString("      </langUsage>\n    </profileDesc>\n")
+	}
+	b.WriteString("  </teiHeader>\n  <text>\n    <body>\n")
+	for i, page := range doc.Pages {
+		b.WriteString(fmt.Sprintf("      <div type=\"page\" n=\"%d\">\n", i+1))
+		for _, para := range strings.Split(page, "\n\n") {
+			para = strings.TrimSpace(para)
+			if para == "" {
+				continue
+			}
+			b.WriteString("        <p>" + esc(para) + "</p>\n")
+		}
+		b.WriteString("      </div>\n")
+	}
+	b.WriteString("    </body>\n  </text>\n</TEI>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
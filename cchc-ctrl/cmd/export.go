@@ -0,0 +1,317 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/cobra"
+)
+
+var exportFormat string
+var exportOutput string
+var exportMinProbability float64
+var exportYearFrom int
+var exportYearTo int
+var exportCollection string
+var exportLanguages []string
+var exportDestination string
+
+// exportBatchSize is the number of rows fetched from the cursor at a time
+const exportBatchSize = 5000
+
+// exportItemColumns are the item metadata included with every export
+var exportItemColumns = []exportColumn{
+	{"item_id", colText},
+	{"title", colText},
+	{"year", colInt},
+	{"url", colText},
+	{"collections", colText},
+}
+
+// exportItemSelect selects the item metadata matching exportItemColumns. The
+// collections for an item are separated by semicolons.
+const exportItemSelect = `
+	i.id,
+	i.title,
+	i.year::bigint,
+	i.url,
+	(SELECT string_agg(collection_id, ';' ORDER BY collection_id)
+		FROM items_in_collections WHERE item_id = i.id)`
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:       "export <quotations|languages>",
+	Short:     "Export results joined with item metadata",
+	ValidArgs: []string{"quotations", "languages"},
+	Args:      cobra.ExactValidArgs(1),
+	Long: `Exports results along with the metadata for each item (title, year, URL,
+and collections) as CSV, JSON Lines, or Parquet. The results can be either
+biblical quotations or the language stats for items.
+
+The results can be filtered by year, collection, and language. For language
+stats, the language is the detected language (an ISO 639-3 code such as eng);
+for quotations, the language is one of the catalog languages for the item
+(such as english). Quotations can also be filtered by a minimum probability,
+and language stats by the destination of the job that produced them.
+
+Results are read from the database through a cursor and written as they are
+read, so very large exports do not need much memory.
+`,
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		var columns []exportColumn
+		var query string
+		var params []interface{}
+
+		switch args[0] {
+		case "quotations":
+			columns, query, params = quotationsExport(cmd)
+		case "languages":
+			columns, query, params = languagesExport(cmd)
+		}
+
+		var out io.Writer = os.Stdout
+		if exportOutput != "" && exportOutput != "-" {
+			f, err := os.Create(exportOutput)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to create the output file with error:\n	%s\n", err)
+				shutdown(nil, nil)
+				os.Exit(18)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		w, err := newRowWriter(exportFormat, out, columns)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to export results with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(18)
+		}
+
+		n, err := exportRows(context.Background(), query, params, columns, w)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to export results with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(19)
+		}
+		err = w.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to export results with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(19)
+		}
+
+		fmt.Fprintf(os.Stderr, "Exported %d rows successfully\n", n)
+	},
+	PostRun: shutdown,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVar(&exportFormat, "format", "csv", "output format: csv, jsonl, or parquet")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "file to write to (default is standard output)")
+	exportCmd.Flags().Float64Var(&exportMinProbability, "min-probability", 0, "only quotations with at least this probability")
+	exportCmd.Flags().IntVar(&exportYearFrom, "year-from", 0, "only items from this year or later")
+	exportCmd.Flags().IntVar(&exportYearTo, "year-to", 0, "only items from this year or earlier")
+	exportCmd.Flags().StringVar(&exportCollection, "collection", "", "only items in this collection ID")
+	exportCmd.Flags().StringSliceVar(&exportLanguages, "language", nil, "only these languages (comma separated)")
+	exportCmd.Flags().StringVarP(&exportDestination, "destination", "d", "", "only language stats from jobs for this destination")
+}
+
+// quotationsExport builds the query for exporting biblical quotations
+func quotationsExport(cmd *cobra.Command) ([]exportColumn, string, []interface{}) {
+	columns := append(exportItemColumns[:len(exportItemColumns):len(exportItemColumns)],
+		exportColumn{"reference_id", colText},
+		exportColumn{"verse_id", colText},
+		exportColumn{"probability", colFloat},
+		exportColumn{"translation", colText},
+		exportColumn{"canonical_reference_id", colText},
+		exportColumn{"job_id", colText},
+		exportColumn{"page", colInt},
+		exportColumn{"start_char", colInt},
+		exportColumn{"end_char", colInt},
+		exportColumn{"passage", colText},
+		exportColumn{"context", colText},
+	)
+
+	f := exportFilter(cmd)
+	if cmd.Flags().Changed("min-probability") {
+		f.add("q.probability >= $%d", exportMinProbability)
+	}
+	if len(exportLanguages) > 0 {
+		f.add("i.languages && $%d::text[]", lowerAll(exportLanguages))
+	}
+
+	query := `
+	SELECT ` + exportItemSelect + `,
+		q.reference_id,
+		q.verse_id,
+		q.probability::double precision,
+		q.translation,
+		q.canonical_reference_id,
+		q.job_id::text,
+		q.page::bigint,
+		q.start_char::bigint,
+		q.end_char::bigint,
+		substr(q.context, q.start_char - q.context_start + 1, q.end_char - q.start_char),
+		q.context
+	FROM results.biblical_quotations_canonical q
+	JOIN items i ON q.item_id = i.id
+	` + f.where()
+
+	return columns, query, f.params
+}
+
+// languagesExport builds the query for exporting language stats
+func languagesExport(cmd *cobra.Command) ([]exportColumn, string, []interface{}) {
+	columns := append(exportItemColumns[:len(exportItemColumns):len(exportItemColumns)],
+		exportColumn{"lang", colText},
+		exportColumn{"sentences", colInt},
+		exportColumn{"destination", colText},
+		exportColumn{"job_id", colText},
+	)
+
+	f := exportFilter(cmd)
+	if len(exportLanguages) > 0 {
+		langs := make([]string, len(exportLanguages))
+		for i, l := range exportLanguages {
+			langs[i] = strings.ToUpper(strings.TrimSpace(l))
+		}
+		f.add("l.lang = ANY($%d)", langs)
+	}
+	if exportDestination != "" {
+		f.add("j.destination = $%d", exportDestination)
+	}
+
+	query := `
+	SELECT ` + exportItemSelect + `,
+		l.lang,
+		l.sentences::bigint,
+		j.destination,
+		l.job_id::text
+	FROM results.languages l
+	JOIN items i ON l.item_id = i.id
+	JOIN jobs.fulltext j ON l.job_id = j.id
+	` + f.where()
+
+	return columns, query, f.params
+}
+
+// exportFilter adds the filters on item metadata which apply to every export
+func exportFilter(cmd *cobra.Command) *sqlFilter {
+	f := &sqlFilter{}
+	if cmd.Flags().Changed("year-from") {
+		f.add("i.year >= $%d", exportYearFrom)
+	}
+	if cmd.Flags().Changed("year-to") {
+		f.add("i.year <= $%d", exportYearTo)
+	}
+	if exportCollection != "" {
+		f.add("EXISTS (SELECT 1 FROM items_in_collections WHERE item_id = i.id AND collection_id = $%d)", exportCollection)
+	}
+	return f
+}
+
+// exportRows runs the query through a server-side cursor and writes each row
+// as it is read. It returns the number of rows written.
+func exportRows(ctx context.Context, query string, params []interface{}, columns []exportColumn, w rowWriter) (int64, error) {
+	dest, values := newExportRow(columns)
+	var n int64
+	err := streamCursor(ctx, query, params, func(rows pgx.Rows) error {
+		err := rows.Scan(dest...)
+		if err != nil {
+			return err
+		}
+		err = w.Write(values())
+		if err != nil {
+			return fmt.Errorf("Error writing results: %w", err)
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// streamCursor runs the query through a server-side cursor, fetching a batch of
+// rows at a time and calling fn on each row, so that the memory used does not
+// depend on how many rows the query returns.
+func streamCursor(ctx context.Context, query string, params []interface{}, fn func(pgx.Rows) error) error {
+	tx, err := database.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.TODO())
+
+	_, err = tx.Exec(ctx, `DECLARE export_cursor NO SCROLL CURSOR FOR `+query, params...)
+	if err != nil {
+		return fmt.Errorf("Error querying database: %w", err)
+	}
+
+	fetch := fmt.Sprintf(`FETCH FORWARD %d FROM export_cursor;`, exportBatchSize)
+	for {
+		rows, err := tx.Query(ctx, fetch)
+		if err != nil {
+			return err
+		}
+
+		batch := 0
+		for rows.Next() {
+			err = fn(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			batch++
+		}
+		rows.Close()
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+		if batch < exportBatchSize {
+			break
+		}
+	}
+
+	_, err = tx.Exec(ctx, `CLOSE export_cursor;`)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// newExportRow makes the scan destinations for a row of exported results,
+// typed according to the columns, along with a function that gets the values
+// out of those destinations once a row has been scanned.
+func newExportRow(columns []exportColumn) ([]interface{}, func() []interface{}) {
+	dest := make([]interface{}, len(columns))
+	for i, col := range columns {
+		switch col.Type {
+		case colInt:
+			dest[i] = new(*int64)
+		case colFloat:
+			dest[i] = new(*float64)
+		default:
+			dest[i] = new(*string)
+		}
+	}
+	values := func() []interface{} {
+		row := make([]interface{}, len(dest))
+		for i, d := range dest {
+			switch d := d.(type) {
+			case **int64:
+				row[i] = *d
+			case **float64:
+				row[i] = *d
+			case **string:
+				row[i] = *d
+			}
+		}
+		return row
+	}
+	return dest, values
+}
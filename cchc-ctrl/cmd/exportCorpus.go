@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/lmullen/cchc/common/items"
+	"github.com/spf13/cobra"
+)
+
+var corpusFormat string
+var corpusOutput string
+var corpusQuotations []string
+var corpusStacks bool
+var corpusLimit int
+
+// errCorpusLimit stops reading documents once the limit has been reached
+var errCorpusLimit = errors.New("Reached the limit on the number of documents")
+
+// exportCorpusCmd represents the export-corpus command
+var exportCorpusCmd = &cobra.Command{
+	Use:   "export-corpus",
+	Short: "Export the full text of items for use in other tools",
+	Long: `Exports the full text of items, along with their metadata, in formats that
+can be used by other text analysis tools. The formats are:
+
+	text   a directory of plain text files with a metadata.csv file
+	jsonl  a JSON object for each item, with its metadata and pages
+	tei    a zip file of TEI-lite XML files with a metadata.csv file
+
+Items can be selected by collection, year, catalog language (such as english),
+or by the biblical quotations found in them (e.g., --quotation "John 3:16",
+optionally with --min-probability). Items without full text are skipped.
+
+With the --stacks flag, books imported from the Stacks are included as well.
+Only the year and language filters apply to those books.
+`,
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		w, err := newCorpusWriter(corpusFormat, corpusOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to export corpus with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(18)
+		}
+
+		ctx := context.Background()
+		written, skipped, err := exportCorpusItems(ctx, cmd, w)
+		if err == nil && corpusStacks && (corpusLimit == 0 || written < corpusLimit) {
+			var n int
+			n, err = exportCorpusStacks(ctx, cmd, w, written)
+			written += n
+		}
+		if err != nil {
+			w.Close()
+			fmt.Fprintf(os.Stderr, "Failed to export corpus with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(19)
+		}
+
+		err = w.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to export corpus with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(19)
+		}
+
+		fmt.Fprintf(os.Stderr, "Exported %d documents successfully (skipped %d items without full text)\n", written, skipped)
+	},
+	PostRun: shutdown,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCorpusCmd)
+	exportCorpusCmd.Flags().StringVar(&corpusFormat, "format", "text", "output format: text, jsonl, or tei")
+	exportCorpusCmd.Flags().StringVarP(&corpusOutput, "output", "o", "", "directory (for text) or file to write to")
+	exportCorpusCmd.Flags().IntVar(&exportYearFrom, "year-from", 0, "only items from this year or later")
+	exportCorpusCmd.Flags().IntVar(&exportYearTo, "year-to", 0, "only items from this year or earlier")
+	exportCorpusCmd.Flags().StringVar(&exportCollection, "collection", "", "only items in this collection ID")
+	exportCorpusCmd.Flags().StringSliceVar(&exportLanguages, "language", nil, "only items in these catalog languages (comma separated)")
+	exportCorpusCmd.Flags().StringSliceVar(&corpusQuotations, "quotation", nil, "only items quoting these verses (e.g., \"John 3:16\")")
+	exportCorpusCmd.Flags().Float64Var(&exportMinProbability, "min-probability", 0, "minimum probability for the quotations")
+	exportCorpusCmd.Flags().BoolVar(&corpusStacks, "stacks", false, "include books imported from the Stacks")
+	exportCorpusCmd.Flags().IntVarP(&corpusLimit, "limit", "n", 0, "maximum number of documents to export (0 for no limit)")
+}
+
+// exportCorpusItems writes the selected items which have full text. It returns
+// the number of items written and the number skipped.
+func exportCorpusItems(ctx context.Context, cmd *cobra.Command, w corpusWriter) (int, int, error) {
+	f := exportFilter(cmd)
+	if len(exportLanguages) > 0 {
+		f.add("i.languages && $%d::text[]", lowerAll(exportLanguages))
+	}
+	if len(corpusQuotations) > 0 {
+		condition := "EXISTS (SELECT 1 FROM results.biblical_quotations_canonical q WHERE q.item_id = i.id AND q.canonical_reference_id = ANY($%d)"
+		if cmd.Flags().Changed("min-probability") {
+			f.add(condition+" AND q.probability >= $%d)", corpusQuotations, exportMinProbability)
+		} else {
+			f.add(condition+")", corpusQuotations)
+		}
+	}
+
+	query := `
+	SELECT i.id,
+		(SELECT array_agg(collection_id ORDER BY collection_id)
+			FROM items_in_collections WHERE item_id = i.id)
+	FROM items i
+	` + f.where() + `
+	ORDER BY i.id`
+
+	repo := items.NewItemRepo(database)
+	written, skipped := 0, 0
+
+	err := streamCursor(ctx, query, f.params, func(rows pgx.Rows) error {
+		if corpusLimit > 0 && written >= corpusLimit {
+			return errCorpusLimit
+		}
+
+		var id string
+		var collections []string
+		err := rows.Scan(&id, &collections)
+		if err != nil {
+			return err
+		}
+
+		timeoutCtx, cancel := timeout()
+		defer cancel()
+		item, err := repo.Get(timeoutCtx, id)
+		if err != nil {
+			return fmt.Errorf("Error getting item %s: %w", id, err)
+		}
+
+		pages, has := item.FullText()
+		if !has {
+			skipped++
+			return nil
+		}
+
+		doc := &corpusDoc{
+			ID:          item.ID,
+			Source:      "loc",
+			Title:       item.Title.String,
+			Date:        item.Date.String,
+			URL:         item.URL.String,
+			Languages:   item.Languages,
+			Collections: collections,
+		}
+		if item.Year.Valid {
+			year := int(item.Year.Int32)
+			doc.Year = &year
+		}
+		for _, p := range pages {
+			doc.Pages = append(doc.Pages, p.Text)
+		}
+
+		written++
+		return w.Write(doc)
+	})
+	if errors.Is(err, errCorpusLimit) {
+		err = nil
+	}
+
+	return written, skipped, err
+}
+
+// exportCorpusStacks writes the selected books from the Stacks. The number of
+// documents already written counts towards the limit.
+func exportCorpusStacks(ctx context.Context, cmd *cobra.Command, w corpusWriter, already int) (int, error) {
+	if exportCollection != "" || len(corpusQuotations) > 0 {
+		fmt.Fprintln(os.Stderr, "Books from the Stacks are not in collections and do not have quotations, so none will be exported")
+		return 0, nil
+	}
+
+	f := &sqlFilter{}
+	if cmd.Flags().Changed("year-from") {
+		f.add("year >= $%d", exportYearFrom)
+	}
+	if cmd.Flags().Changed("year-to") {
+		f.add("year <= $%d", exportYearTo)
+	}
+	if len(exportLanguages) > 0 {
+		f.add("EXISTS (SELECT 1 FROM unnest(lang) AS l WHERE lower(l) = ANY($%d))", lowerAll(exportLanguages))
+	}
+
+	query := `
+	SELECT lccn, COALESCE(title, ''), year, COALESCE(lang, '{}'), COALESCE(text, '')
+	FROM stacks_books
+	` + f.where() + `
+	ORDER BY lccn`
+
+	written := 0
+	err := streamCursor(ctx, query, f.params, func(rows pgx.Rows) error {
+		if corpusLimit > 0 && already+written >= corpusLimit {
+			return errCorpusLimit
+		}
+
+		doc := &corpusDoc{Source: "stacks"}
+		var text string
+		err := rows.Scan(&doc.ID, &doc.Title, &doc.Year, &doc.Languages, &text)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		doc.Pages = []string{text}
+
+		written++
+		return w.Write(doc)
+	})
+	if errors.Is(err, errCorpusLimit) {
+		err = nil
+	}
+
+	return written, err
+}
+
+// lowerAll lower-cases and trims a list of strings
+func lowerAll(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToLower(strings.TrimSpace(v))
+	}
+	return out
+}
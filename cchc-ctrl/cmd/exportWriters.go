@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Types of columns which can be exported
+const (
+	colText  = "text"
+	colInt   = "int"
+	colFloat = "float"
+)
+
+// exportColumn is a column in an export, with the type of the values in it
+type exportColumn struct {
+	Name string
+	Type string
+}
+
+// rowWriter writes rows of exported results in some format. Each value in a row
+// is a *string, *int64, or *float64 depending on the type of the column, and a
+// nil pointer is a missing value.
+type rowWriter interface {
+	Write(row []interface{}) error
+	Close() error
+}
+
+// newRowWriter returns a writer for the format
+func newRowWriter(format string, w io.Writer, columns []exportColumn) (rowWriter, error) {
+	switch format {
+	case "csv":
+		return newCSVRowWriter(w, columns)
+	case "jsonl":
+		return &jsonlRowWriter{enc: json.NewEncoder(w), columns: columns}, nil
+	case "parquet":
+		return newParquetRowWriter(w, columns)
+	default:
+		return nil, fmt.Errorf("%s is not a valid export format; use csv, jsonl, or parquet", format)
+	}
+}
+
+// csvRowWriter writes rows as CSV with a header
+type csvRowWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVRowWriter(w io.Writer, columns []exportColumn) (*csvRowWriter, error) {
+	c := &csvRowWriter{w: csv.NewWriter(w), record: make([]string, len(columns))}
+	for i, col := range columns {
+		c.record[i] = col.Name
+	}
+	err := c.w.Write(c.record)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvRowWriter) Write(row []interface{}) error {
+	for i, v := range row {
+		c.record[i] = formatValue(v)
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvRowWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonlRowWriter writes each row as a JSON object on its own line
+type jsonlRowWriter struct {
+	enc     *json.Encoder
+	columns []exportColumn
+}
+
+func (j *jsonlRowWriter) Write(row []interface{}) error {
+	obj := make(map[string]interface{}, len(row))
+	for i, v := range row {
+		obj[j.columns[i].Name] = v
+	}
+	return j.enc.Encode(obj)
+}
+
+func (j *jsonlRowWriter) Close() error {
+	return nil
+}
+
+// parquetRowWriter writes rows to a Parquet file. Rows are buffered until a row
+// group is full, so the row group size bounds the memory used.
+type parquetRowWriter struct {
+	pw      *writer.CSVWriter
+	columns []exportColumn
+}
+
+func newParquetRowWriter(w io.Writer, columns []exportColumn) (*parquetRowWriter, error) {
+	md := make([]string, len(columns))
+	for i, col := range columns {
+		switch col.Type {
+		case colInt:
+			md[i] = fmt.Sprintf("name=%s, type=INT64, repetitiontype=OPTIONAL", col.Name)
+		case colFloat:
+			md[i] = fmt.Sprintf("name=%s, type=DOUBLE, repetitiontype=OPTIONAL", col.Name)
+		default:
+			md[i] = fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL", col.Name)
+		}
+	}
+	pw, err := writer.NewCSVWriterFromWriter(md, w, 1)
+	if err != nil {
+		return nil, err
+	}
+	pw.RowGroupSize = 16 * 1024 * 1024
+	return &parquetRowWriter{pw: pw, columns: columns}, nil
+}
+
+func (p *parquetRowWriter) Write(row []interface{}) error {
+	rec := make([]interface{}, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case *string:
+			if v != nil {
+				rec[i] = *v
+			}
+		case *int64:
+			if v != nil {
+				rec[i] = *v
+			}
+		case *float64:
+			if v != nil {
+				rec[i] = *v
+			}
+		}
+	}
+	return p.pw.Write(rec)
+}
+
+func (p *parquetRowWriter) Close() error {
+	return p.pw.WriteStop()
+}
+
+// formatValue formats a value as a string, with missing values left empty
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case *string:
+		if v != nil {
+			return *v
+		}
+	case *int64:
+		if v != nil {
+			return strconv.FormatInt(*v, 10)
+		}
+	case *float64:
+		if v != nil {
+			return strconv.FormatFloat(*v, 'f', -1, 64)
+		}
+	}
+	return ""
+}
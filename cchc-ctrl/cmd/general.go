@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
@@ -68,3 +69,27 @@ func getConfirmation() {
 		os.Exit(8)
 	}
 }
+
+// sqlFilter accumulates the conditions and parameters for a WHERE clause
+type sqlFilter struct {
+	conditions []string
+	params     []interface{}
+}
+
+// add adds a condition, in which each %d is replaced with the placeholder for
+// the corresponding parameter
+func (f *sqlFilter) add(condition string, params ...interface{}) {
+	placeholders := make([]interface{}, len(params))
+	for i, p := range params {
+		f.params = append(f.params, p)
+		placeholders[i] = len(f.params)
+	}
+	f.conditions = append(f.conditions, fmt.Sprintf(condition, placeholders...))
+}
+
+func (f *sqlFilter) where() string {
+	if len(f.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(f.conditions, " AND ")
+}
@@ -0,0 +1,448 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/lmullen/cchc/common/results"
+	"github.com/lmullen/cchc/common/sources"
+	"github.com/spf13/cobra"
+)
+
+// Filters for selecting jobs, shared by the jobs subcommands
+var jobsDestination string
+var jobsStatuses []string
+var jobsItem string
+var jobsSource string
+var jobsCollection string
+var jobsSince string
+var jobsUntil string
+var jobsLimit int
+var dryRun bool
+
+// jobStatuses are the valid values of the job_statuses type
+var jobStatuses = []string{"ready", "running", "skipped", "failed", "finished", "cancelled"}
+
+// jobsCmd represents the jobs command
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "List, requeue, cancel, or purge jobs",
+	Long: `These commands manage jobs in the jobs.fulltext table. Jobs can be
+selected by destination, status, item, collection, and when they were started.
+
+The commands which change jobs (requeue, cancel, and purge) must be scoped to a
+single destination with the --destination flag. Pass --dry-run to see how many
+jobs would be changed, and how many rows of results would be deleted from each
+table, without changing them.
+`,
+}
+
+// jobsListCmd represents the jobs list command
+var jobsListCmd = &cobra.Command{
+	Use:    "list",
+	Short:  "List jobs matching the filters",
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		where, params := jobFilter(nil)
+		query := `
+		SELECT id, source, item_id, destination, status::text, started, finished
+		FROM jobs.fulltext
+		` + where + `
+		ORDER BY started DESC NULLS LAST
+		LIMIT ` + fmt.Sprint(jobsLimit) + `;`
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		rows, err := database.Query(ctx, query, params...)
+		if err != nil {
+			fmt.Printf("Failed to list jobs with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(14)
+		}
+		defer rows.Close()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSOURCE\tITEM\tDESTINATION\tSTATUS\tSTARTED\tFINISHED")
+		for rows.Next() {
+			var id uuid.UUID
+			var source, item, destination, status string
+			var started, finished *time.Time
+			err = rows.Scan(&id, &source, &item, &destination, &status, &started, &finished)
+			if err != nil {
+				fmt.Printf("Failed to list jobs with error:\n	%s\n", err)
+				shutdown(nil, nil)
+				os.Exit(14)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", id, source, item, destination, status,
+				formatTime(started), formatTime(finished))
+		}
+		w.Flush()
+		if rows.Err() != nil {
+			fmt.Printf("Failed to list jobs with error:\n	%s\n", rows.Err())
+			shutdown(nil, nil)
+			os.Exit(14)
+		}
+	},
+	PostRun: shutdown,
+}
+
+// jobsShowCmd represents the jobs show command
+var jobsShowCmd = &cobra.Command{
+	Use:    "show <job-id>",
+	Short:  "Show the details of a single job",
+	Args:   cobra.ExactArgs(1),
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := uuid.Parse(args[0])
+		if err != nil {
+			fmt.Printf("%s is not a valid job ID.\n", args[0])
+			shutdown(nil, nil)
+			os.Exit(15)
+		}
+
+		query := `
+		SELECT j.source, j.item_id, j.destination, j.status::text, j.started, j.finished,
+			COALESCE(i.title, s.title, ''), c.progress, c.updated
+		FROM jobs.fulltext j
+		LEFT JOIN items i ON j.source = 'items' AND j.item_id = i.id
+		LEFT JOIN stacks_books s ON j.source = 'stacks' AND j.item_id = s.lccn
+		LEFT JOIN jobs.checkpoints c ON j.id = c.job_id
+		WHERE j.id = $1;
+		`
+		var source, item, destination, status, title string
+		var started, finished, checkpointed *time.Time
+		var progress *int
+
+		ctx, cancel := timeout()
+		defer cancel()
+		err = database.QueryRow(ctx, query, id).Scan(&source, &item, &destination, &status,
+			&started, &finished, &title, &progress, &checkpointed)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				fmt.Printf("There is no job with the ID %s.\n", id)
+			} else {
+				fmt.Printf("Failed to get job with error:\n	%s\n", err)
+			}
+			shutdown(nil, nil)
+			os.Exit(15)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "ID\t%s\n", id)
+		fmt.Fprintf(w, "Source\t%s\n", source)
+		fmt.Fprintf(w, "Item\t%s\n", item)
+		fmt.Fprintf(w, "Title\t%s\n", title)
+		fmt.Fprintf(w, "Destination\t%s\n", destination)
+		fmt.Fprintf(w, "Status\t%s\n", status)
+		fmt.Fprintf(w, "Started\t%s\n", formatTime(started))
+		fmt.Fprintf(w, "Finished\t%s\n", formatTime(finished))
+		if progress != nil {
+			fmt.Fprintf(w, "Checkpoint\t%d pages at %s\n", *progress, formatTime(checkpointed))
+		}
+		for _, table := range results.JobTables {
+			var n int64
+			err = database.QueryRow(ctx, `SELECT COUNT(*) FROM `+table+` WHERE job_id = $1;`, id).Scan(&n)
+			if err != nil {
+				continue // The table might not exist yet if the database is not migrated
+			}
+			if n > 0 {
+				fmt.Fprintf(w, "Rows in %s\t%d\n", table, n)
+			}
+		}
+		w.Flush()
+	},
+	PostRun: shutdown,
+}
+
+// jobsRequeueCmd represents the jobs requeue command
+var jobsRequeueCmd = &cobra.Command{
+	Use:   "requeue",
+	Short: "Set jobs back to ready so they will be run again",
+	Long: `Sets the selected jobs back to ready so that they will be run again. By
+default, this selects failed, skipped, and cancelled jobs; use --status to
+select others. Any results and checkpoints for the jobs are removed, so that
+rerunning the jobs does not duplicate their results.
+`,
+	PreRun: connectScopedDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		where, params := jobFilter([]string{"failed", "skipped", "cancelled"})
+		changeJobs("requeue", where, params, true, func(ctx context.Context, tx pgx.Tx) (int64, error) {
+			err := deleteJobResults(ctx, tx, where, params)
+			if err != nil {
+				return 0, err
+			}
+			_, err = tx.Exec(ctx, `DELETE FROM jobs.checkpoints WHERE job_id IN (SELECT id FROM jobs.fulltext `+where+`);`, params...)
+			if err != nil {
+				return 0, err
+			}
+			tag, err := tx.Exec(ctx, `UPDATE jobs.fulltext SET status = 'ready', started = NULL, finished = NULL `+where+`;`, params...)
+			return tag.RowsAffected(), err
+		})
+	},
+	PostRun: shutdown,
+}
+
+// jobsCancelCmd represents the jobs cancel command
+var jobsCancelCmd = &cobra.Command{
+	Use:   "cancel",
+	Short: "Cancel jobs so they will not be run",
+	Long: `Marks the selected jobs as cancelled, so that they will not be run and will
+not be recreated. By default, this selects ready jobs; use --status to select
+others. A job which is already running will finish, but its status may then be
+overwritten. Cancelled jobs can be run later with the requeue command.
+`,
+	PreRun: connectScopedDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		where, params := jobFilter([]string{"ready"})
+		changeJobs("cancel", where, params, false, func(ctx context.Context, tx pgx.Tx) (int64, error) {
+			tag, err := tx.Exec(ctx, `UPDATE jobs.fulltext SET status = 'cancelled', finished = NOW() `+where+`;`, params...)
+			return tag.RowsAffected(), err
+		})
+	},
+	PostRun: shutdown,
+}
+
+// jobsPurgeCmd represents the jobs purge command
+var jobsPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Delete jobs along with their results",
+	Long: `Deletes the selected jobs, along with any results and checkpoints for
+those jobs. Jobs for items which still need work will be created again by the
+service for that destination. By default, this selects failed and skipped jobs;
+use --status to select others.
+`,
+	PreRun: connectScopedDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		where, params := jobFilter([]string{"failed", "skipped"})
+		changeJobs("purge", where, params, true, func(ctx context.Context, tx pgx.Tx) (int64, error) {
+			err := deleteJobResults(ctx, tx, where, params)
+			if err != nil {
+				return 0, err
+			}
+			tag, err := tx.Exec(ctx, `DELETE FROM jobs.fulltext `+where+`;`, params...)
+			return tag.RowsAffected(), err
+		})
+	},
+	PostRun: shutdown,
+}
+
+func init() {
+	rootCmd.AddCommand(jobsCmd)
+	jobsCmd.AddCommand(jobsListCmd, jobsShowCmd, jobsRequeueCmd, jobsCancelCmd, jobsPurgeCmd)
+
+	for _, c := range []*cobra.Command{jobsListCmd, jobsRequeueCmd, jobsCancelCmd, jobsPurgeCmd} {
+		c.Flags().StringVarP(&jobsDestination, "destination", "d", "", "only jobs for this destination")
+		c.Flags().StringSliceVarP(&jobsStatuses, "status", "s", nil, "only jobs with these statuses (comma separated)")
+		c.Flags().StringVar(&jobsItem, "item", "", "only jobs for this item ID")
+		c.Flags().StringVar(&jobsSource, "source", "", "only jobs for documents from this source: items or stacks")
+		c.Flags().StringVar(&jobsCollection, "collection", "", "only jobs for items in this collection ID")
+		c.Flags().StringVar(&jobsSince, "since", "", "only jobs started at or after this time (YYYY-MM-DD or RFC 3339)")
+		c.Flags().StringVar(&jobsUntil, "until", "", "only jobs started before this time (YYYY-MM-DD or RFC 3339)")
+	}
+	for _, c := range []*cobra.Command{jobsRequeueCmd, jobsCancelCmd, jobsPurgeCmd} {
+		c.Flags().BoolVar(&dryRun, "dry-run", false, "report how many jobs and results would be changed without changing them")
+	}
+	jobsListCmd.Flags().IntVarP(&jobsLimit, "limit", "n", 100, "maximum number of jobs to list")
+}
+
+// jobFilter builds a WHERE clause and its parameters from the filter flags. If
+// no statuses were passed in, then the default statuses are used. The program
+// exits if any of the filters are invalid.
+func jobFilter(defaultStatuses []string) (string, []interface{}) {
+	f := &sqlFilter{}
+
+	if jobsDestination != "" {
+		f.add("destination = $%d", jobsDestination)
+	}
+
+	statuses := jobsStatuses
+	if len(statuses) == 0 {
+		statuses = defaultStatuses
+	}
+	if len(statuses) > 0 {
+		for _, s := range statuses {
+			if !validStatus(s) {
+				fmt.Printf("%s is not a valid job status. Use one of: %s.\n", s, strings.Join(jobStatuses, ", "))
+				shutdown(nil, nil)
+				os.Exit(16)
+			}
+		}
+		f.add("status::text = ANY($%d)", statuses)
+	}
+
+	if jobsItem != "" {
+		f.add("item_id = $%d", jobsItem)
+	}
+	if jobsSource != "" {
+		if !sources.Valid(jobsSource) {
+			fmt.Printf("%s is not a valid source. Use one of: %s.\n", jobsSource, strings.Join(sources.All, ", "))
+			shutdown(nil, nil)
+			os.Exit(16)
+		}
+		f.add("source = $%d", jobsSource)
+	}
+	if jobsCollection != "" {
+		f.add("item_id IN (SELECT item_id FROM items_in_collections WHERE collection_id = $%d)", jobsCollection)
+	}
+	if jobsSince != "" {
+		f.add("started >= $%d", parseTimeFlag("since", jobsSince))
+	}
+	if jobsUntil != "" {
+		f.add("started < $%d", parseTimeFlag("until", jobsUntil))
+	}
+
+	return f.where(), f.params
+}
+
+// connectScopedDB makes sure that a change to jobs is scoped to a destination
+// before connecting to the database
+func connectScopedDB(cmd *cobra.Command, args []string) {
+	if jobsDestination == "" {
+		fmt.Println("You must choose a destination with the --destination flag.")
+		os.Exit(16)
+	}
+	connectDB(cmd, args)
+}
+
+// changeJobs counts the jobs which would be changed, then unless this is a dry
+// run, gets confirmation and makes the change in a transaction. A dry run of a
+// change which deletes the results of the jobs also counts those results.
+func changeJobs(action, where string, params []interface{}, deletesResults bool, change func(context.Context, pgx.Tx) (int64, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	var n int64
+	err := database.QueryRow(ctx, `SELECT COUNT(*) FROM jobs.fulltext `+where+`;`, params...).Scan(&n)
+	if err != nil {
+		fmt.Printf("Failed to %s jobs with error:\n	%s\n", action, err)
+		shutdown(nil, nil)
+		os.Exit(17)
+	}
+
+	if dryRun {
+		fmt.Printf("Would %s %d jobs for the %s destination\n", action, n, jobsDestination)
+		if deletesResults && n > 0 {
+			err = printJobResults(ctx, where, params)
+			if err != nil {
+				fmt.Printf("Failed to count the results of the jobs with error:\n	%s\n", err)
+				shutdown(nil, nil)
+				os.Exit(17)
+			}
+		}
+		return
+	}
+	if n == 0 {
+		fmt.Println("No jobs match those filters")
+		return
+	}
+	if !force {
+		fmt.Printf("This will %s %d jobs for the %s destination.\n", action, n, jobsDestination)
+		getConfirmation()
+	}
+
+	tx, err := database.Begin(ctx)
+	if err != nil {
+		fmt.Printf("Failed to %s jobs with error:\n	%s\n", action, err)
+		shutdown(nil, nil)
+		os.Exit(17)
+	}
+	defer tx.Rollback(context.TODO())
+
+	changed, err := change(ctx, tx)
+	if err != nil {
+		fmt.Printf("Failed to %s jobs with error:\n	%s\n", action, err)
+		tx.Rollback(context.TODO())
+		shutdown(nil, nil)
+		os.Exit(17)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		fmt.Printf("Failed to %s jobs with error:\n	%s\n", action, err)
+		shutdown(nil, nil)
+		os.Exit(17)
+	}
+
+	fmt.Printf("Changed %d jobs successfully (%s)\n", changed, action)
+}
+
+// printJobResults prints the number of rows in each results table which would
+// be deleted along with the results of the jobs selected by the filter.
+func printJobResults(ctx context.Context, where string, params []interface{}) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tROWS")
+	for _, table := range results.JobTables {
+		var n int64
+		err := database.QueryRow(ctx, `SELECT COUNT(*) FROM `+table+
+			` WHERE job_id IN (SELECT id FROM jobs.fulltext `+where+`);`, params...).Scan(&n)
+		if err != nil {
+			return fmt.Errorf("Error counting rows in %s: %w", table, err)
+		}
+		fmt.Fprintf(w, "%s\t%d\n", table, n)
+	}
+	return w.Flush()
+}
+
+// deleteJobResults removes the results for the jobs selected by the filter. Any
+// quotations from the jobs are first removed from the quotation counts, and any
+// clusters the jobs added items to are rebuilt.
+func deleteJobResults(ctx context.Context, tx pgx.Tx, where string, params []interface{}) error {
+	rows, err := tx.Query(ctx, `SELECT id FROM jobs.fulltext `+where+`;`, params...)
+	if err != nil {
+		return fmt.Errorf("Error getting jobs: %w", err)
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("Error getting jobs: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return fmt.Errorf("Error getting jobs: %w", rows.Err())
+	}
+
+	return results.DeleteJobResults(ctx, tx, ids)
+}
+
+func validStatus(s string) bool {
+	for _, status := range jobStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeFlag parses a date or timestamp passed to a flag or dies trying
+func parseTimeFlag(flag, value string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t
+		}
+	}
+	fmt.Printf("The --%s flag must be a date (YYYY-MM-DD) or an RFC 3339 timestamp.\n", flag)
+	shutdown(nil, nil)
+	os.Exit(16)
+	return time.Time{}
+}
+
+// formatTime formats a timestamp which might be null
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
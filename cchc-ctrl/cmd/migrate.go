@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/lmullen/cchc/common/db"
 	"github.com/spf13/cobra"
@@ -12,10 +13,22 @@ import (
 var migrateCmd = &cobra.Command{
 	Use:   "migrate",
 	Short: "Migrate the database to the current schema",
-	Long: `Migration will bring the database from its current state to 
-the current schema for the application.`,
+	Long: `Migration will bring the database from its current state to
+the current schema for the application.
+
+The subcommands show the status of the migrations, or migrate the database to a
+specific version, up or down by a number of steps, or force the version after a
+migration has failed. Pass --dry-run to list the migrations that would be run
+without running them.
+`,
 	PreRun: connectDB,
 	Run: func(cmd *cobra.Command, args []string) {
+		status := getMigrationStatus()
+		if dryRun {
+			printPlan(status.Pending, "applied")
+			return
+		}
+
 		ctx, cancel := timeout()
 		defer cancel()
 		err := db.MigrateUp(ctx, dbstr)
@@ -25,21 +38,234 @@ the current schema for the application.`,
 			shutdown(nil, nil)
 			os.Exit(3)
 		}
+		if len(status.Pending) == 0 {
+			fmt.Printf("The database is already at the current schema (version %d)\n", status.Version)
+			return
+		}
 		fmt.Println("Migrated the database successfully")
 	},
 	PostRun: shutdown,
 }
 
+// migrateStatusCmd represents the migrate status command
+var migrateStatusCmd = &cobra.Command{
+	Use:    "status",
+	Short:  "Show the schema version and any pending migrations",
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		status := getMigrationStatus()
+		fmt.Printf("Current version: %d\n", status.Version)
+		fmt.Printf("Latest version:  %d\n", status.Latest)
+		if status.Dirty {
+			fmt.Printf("The database is DIRTY: migration %d failed partway through.\n", status.Version)
+			fmt.Println("Fix the database by hand, then use `migrate force <version>` to set the version.")
+		}
+		if status.Version > status.Latest {
+			fmt.Println("The database is newer than this version of the application.")
+		}
+		if len(status.Pending) == 0 {
+			fmt.Println("There are no pending migrations")
+			return
+		}
+		fmt.Printf("Pending migrations:\n")
+		for _, m := range status.Pending {
+			fmt.Printf("	%06d  %s\n", m.Version, m.Name)
+		}
+	},
+	PostRun: shutdown,
+}
+
+// migrateToCmd represents the migrate to command
+var migrateToCmd = &cobra.Command{
+	Use:    "to <version>",
+	Short:  "Migrate the database up or down to a specific version",
+	Args:   cobra.ExactArgs(1),
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		version, err := strconv.ParseUint(args[0], 10, 0)
+		if err != nil {
+			fmt.Printf("%s is not a valid migration version.\n", args[0])
+			shutdown(nil, nil)
+			os.Exit(20)
+		}
+		migrateToVersion(uint(version))
+	},
+	PostRun: shutdown,
+}
+
+// migrateUpCmd represents the migrate up command
+var migrateUpCmd = &cobra.Command{
+	Use:    "up [n]",
+	Short:  "Apply the next n migrations (or all pending migrations)",
+	Args:   cobra.MaximumNArgs(1),
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			migrateToVersion(getMigrationStatus().Latest)
+			return
+		}
+		migrateSteps(parseSteps(args[0]))
+	},
+	PostRun: shutdown,
+}
+
+// migrateDownCmd represents the migrate down command
+var migrateDownCmd = &cobra.Command{
+	Use:   "down <n>",
+	Short: "Revert the last n migrations",
+	Long: `Reverts the last n migrations. This may result in data loss. To revert all
+the migrations, use the reset command instead.
+`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		migrateSteps(-parseSteps(args[0]))
+	},
+	PostRun: shutdown,
+}
+
+// migrateForceCmd represents the migrate force command
+var migrateForceCmd = &cobra.Command{
+	Use:   "force <version>",
+	Short: "Set the schema version and clear the dirty flag",
+	Long: `Sets the schema version and clears the dirty flag without running any
+migrations. When a migration fails, the database is left dirty at that version.
+After fixing the database by hand, use this command to record which version the
+database is actually at.
+`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < 0 {
+			fmt.Printf("%s is not a valid migration version.\n", args[0])
+			shutdown(nil, nil)
+			os.Exit(20)
+		}
+		if !force {
+			fmt.Printf("This will set the schema version to %d without running any migrations.\n", version)
+			getConfirmation()
+		}
+
+		ctx, cancel := timeout()
+		defer cancel()
+		err = db.ForceVersion(ctx, dbstr, version)
+		if err != nil {
+			fmt.Println("Failed to force the schema version with this error:")
+			fmt.Printf("	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(3)
+		}
+		fmt.Printf("Set the schema version to %d successfully\n", version)
+	},
+	PostRun: shutdown,
+}
+
 func init() {
 	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateStatusCmd, migrateToCmd, migrateUpCmd, migrateDownCmd, migrateForceCmd)
+	migrateCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "list the migrations that would be run without running them")
+}
 
-	// Here you will define your flags and configuration settings.
+// getMigrationStatus gets the status of the migrations or dies trying
+func getMigrationStatus() *db.MigrationStatus {
+	ctx, cancel := timeout()
+	defer cancel()
+	status, err := db.GetMigrationStatus(ctx, dbstr)
+	if err != nil {
+		fmt.Println("Failed to get the status of the migrations with this error:")
+		fmt.Printf("	%s\n", err)
+		shutdown(nil, nil)
+		os.Exit(3)
+	}
+	return status
+}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// migrateCmd.PersistentFlags().String("foo", "", "A help for foo")
+// migrateToVersion migrates up or down to a version, getting confirmation
+// before reverting any migrations
+func migrateToVersion(version uint) {
+	status := getMigrationStatus()
+	if status.Dirty {
+		fmt.Printf("The database is dirty at version %d. Fix it and use `migrate force` first.\n", status.Version)
+		shutdown(nil, nil)
+		os.Exit(21)
+	}
+	all, err := db.Migrations()
+	if err != nil {
+		fmt.Printf("Failed to read the migrations with this error:\n	%s\n", err)
+		shutdown(nil, nil)
+		os.Exit(3)
+	}
+
+	plan := db.PlanMigration(all, status.Version, version)
+	down := version < status.Version
+	verb := "applied"
+	if down {
+		verb = "reverted"
+	}
+	if dryRun {
+		printPlan(plan, verb)
+		return
+	}
+	if len(plan) == 0 {
+		fmt.Printf("The database is already at version %d\n", status.Version)
+		return
+	}
+	if down && !force {
+		printPlan(plan, verb)
+		fmt.Println("Reverting migrations may delete data.")
+		getConfirmation()
+	}
+
+	ctx, cancel := timeout()
+	defer cancel()
+	err = db.MigrateTo(ctx, dbstr, version)
+	if err != nil {
+		fmt.Println("Failed to run migrations with this error:")
+		fmt.Printf("	%s\n", err)
+		shutdown(nil, nil)
+		os.Exit(3)
+	}
+	fmt.Printf("Migrated the database to version %d successfully\n", version)
+}
+
+// migrateSteps migrates up (positive) or down (negative) by n steps
+func migrateSteps(n int) {
+	status := getMigrationStatus()
+	all, err := db.Migrations()
+	if err != nil {
+		fmt.Printf("Failed to read the migrations with this error:\n	%s\n", err)
+		shutdown(nil, nil)
+		os.Exit(3)
+	}
+	target, err := db.StepTarget(all, status.Version, n)
+	if err != nil {
+		fmt.Println(err)
+		shutdown(nil, nil)
+		os.Exit(20)
+	}
+	migrateToVersion(target)
+}
+
+// parseSteps parses a positive number of steps or dies trying
+func parseSteps(arg string) int {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		fmt.Printf("%s is not a valid number of steps; use a positive whole number.\n", arg)
+		shutdown(nil, nil)
+		os.Exit(20)
+	}
+	return n
+}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// migrateCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+// printPlan lists the migrations which would be applied or reverted
+func printPlan(plan []db.Migration, verb string) {
+	if len(plan) == 0 {
+		fmt.Println("No migrations would be " + verb)
+		return
+	}
+	fmt.Printf("%d migrations would be %s:\n", len(plan), verb)
+	for _, m := range plan {
+		fmt.Printf("	%06d  %s\n", m.Version, m.Name)
+	}
 }
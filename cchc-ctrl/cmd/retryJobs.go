@@ -6,6 +6,9 @@ import (
 	"os"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/lmullen/cchc/common/results"
 	"github.com/spf13/cobra"
 )
 
@@ -14,7 +17,8 @@ var retryJobsCmd = &cobra.Command{
 	Use:   "retry-jobs",
 	Short: "Retry skipped and failed jobs",
 	Long: `Jobs run which fail or which are skipped are recorded in the database.
-This command deletes those jobs so that they can be retried.
+This command deletes those jobs, along with any results they saved, so that they
+can be retried.
 `,
 	PreRun: connectDB,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -23,7 +27,6 @@ This command deletes those jobs so that they can be retried.
 			getConfirmation()
 		}
 
-		query := `DELETE FROM jobs.fulltext WHERE status = 'skipped' OR status = 'failed';`
 		fmt.Println("Deleting jobs might take a long time ...")
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 		defer cancel()
@@ -34,7 +37,7 @@ This command deletes those jobs so that they can be retried.
 
 		}
 		defer tx.Rollback(context.TODO())
-		_, err = tx.Exec(ctx, query)
+		err = deleteRetriedJobs(ctx, tx)
 		if err != nil {
 			fmt.Printf("Failed to retry skipped/failed jobs with error:\n	%s\n", err)
 			tx.Rollback(context.TODO())
@@ -55,6 +58,33 @@ This command deletes those jobs so that they can be retried.
 	PostRun: shutdown,
 }
 
+// deleteRetriedJobs deletes the skipped and failed jobs. Failed jobs might have
+// saved some results, which have to be deleted along with the jobs.
+func deleteRetriedJobs(ctx context.Context, tx pgx.Tx) error {
+	query := `SELECT id FROM jobs.fulltext WHERE status = 'skipped' OR status = 'failed' FOR UPDATE;`
+
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	return results.DeleteJobs(ctx, tx, ids)
+}
+
 func init() {
 	rootCmd.AddCommand(retryJobsCmd)
 }
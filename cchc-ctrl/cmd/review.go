@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/lmullen/cchc/common/results"
+	"github.com/spf13/cobra"
+)
+
+var reviewBands []float64
+var reviewPerBand int
+var reviewReference string
+var reviewReviewer string
+
+// reviewLabels are the answers a reviewer can give for a quotation
+var reviewLabels = map[string]string{
+	"q": results.LabelQuotation,
+	"f": results.LabelFalsePositive,
+	"a": results.LabelAllusion,
+}
+
+// reviewCmd represents the review command
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Review predicted quotations and estimate their precision",
+	Long: `These commands record human judgments of predicted quotations in the
+results.quotation_labels table. Each quotation can be labeled as a true
+quotation, a false positive, or an allusion.
+
+Quotations are sampled for review by bands of probability, so that the
+precision of the model can be estimated in each band. The labels are tied to
+the item and verse rather than to the job, so they are kept when jobs are rerun,
+and they can be exported as training data for the next model.
+`,
+}
+
+// reviewSampleCmd represents the review sample command
+var reviewSampleCmd = &cobra.Command{
+	Use:   "sample",
+	Short: "Label a random sample of quotations from each probability band",
+	Long: `Samples quotations which have not been reviewed from each band of
+probabilities and asks for a label for each one, showing the passage which
+matched the verse. Answer q for a quotation, f for a false positive, or a for an
+allusion, optionally followed by a note. Answer s to skip a quotation or x to
+stop reviewing. Labels are saved as they are given.
+`,
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		repo := results.NewRepo(database)
+
+		bands, err := results.NewReviewBands(reviewBands)
+		if err != nil {
+			fmt.Printf("The --bands flag is not valid: %s.\n", err)
+			shutdown(nil, nil)
+			os.Exit(24)
+		}
+		if reviewReviewer == "" {
+			fmt.Println("Set the name of the reviewer with --reviewer.")
+			shutdown(nil, nil)
+			os.Exit(24)
+		}
+
+		input := bufio.NewScanner(os.Stdin)
+		var labeled int
+		for _, band := range bands {
+			sample, err := repo.SampleForReview(ctx, band, reviewPerBand, reviewReference)
+			if err != nil {
+				fmt.Printf("Failed to sample quotations with error:\n	%s\n", err)
+				shutdown(nil, nil)
+				os.Exit(24)
+			}
+			fmt.Printf("\nReviewing %d quotations with probabilities of %s\n", len(sample), band)
+
+			for i, q := range sample {
+				showQuotation(os.Stdout, i+1, len(sample), q)
+				label, note, ok := askLabel(input)
+				if !ok {
+					fmt.Printf("Stopped reviewing after saving %d labels\n", labeled)
+					return
+				}
+				if label == "" {
+					continue
+				}
+				err = repo.SaveLabel(ctx, results.NewQuotationLabel(q, reviewReviewer, label, note))
+				if err != nil {
+					fmt.Printf("Failed to save the label with error:\n	%s\n", err)
+					shutdown(nil, nil)
+					os.Exit(24)
+				}
+				labeled++
+			}
+		}
+		fmt.Printf("Saved %d labels successfully\n", labeled)
+	},
+	PostRun: shutdown,
+}
+
+// reviewPrecisionCmd represents the review precision command
+var reviewPrecisionCmd = &cobra.Command{
+	Use:   "precision",
+	Short: "Estimate the precision of the model in each probability band",
+	Long: `Estimates the precision of the model in each band of probabilities from
+the labels given by reviewers: that is, the share of reviewed quotations which
+really are quotations, with a 95% confidence interval. Precision is reported
+both counting allusions as false positives and counting them as quotations.
+When more than one reviewer has labeled a quotation, the most recent label is
+used.
+`,
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := timeout()
+		defer cancel()
+		repo := results.NewRepo(database)
+
+		bands, err := results.NewReviewBands(reviewBands)
+		if err != nil {
+			fmt.Printf("The --bands flag is not valid: %s.\n", err)
+			shutdown(nil, nil)
+			os.Exit(26)
+		}
+		err = repo.ReviewPrecision(ctx, bands)
+		if err != nil {
+			fmt.Printf("Failed to count the labels with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(26)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BAND\tREVIEWED\tQUOTATIONS\tALLUSIONS\tFALSE POSITIVES\tPRECISION\t95% INTERVAL\tWITH ALLUSIONS")
+		for _, b := range bands {
+			lo, hi := b.Interval(false)
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\t%s–%s\t%s\n", b, b.Reviewed(), b.Quotations,
+				b.Allusions, b.FalsePositives, formatShare(b.Precision(false)),
+				formatShare(lo), formatShare(hi), formatShare(b.Precision(true)))
+		}
+		w.Flush()
+	},
+	PostRun: shutdown,
+}
+
+// reviewExportCmd represents the review export command
+var reviewExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the labels as training data",
+	Long: `Exports the labels given by reviewers, along with the metadata for each
+item and the passage the reviewer saw, as CSV, JSON Lines, or Parquet. The
+probability is the one the quotation had when it was reviewed.
+`,
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		columns := append(exportItemColumns[:len(exportItemColumns):len(exportItemColumns)],
+			exportColumn{"reference_id", colText},
+			exportColumn{"verse_id", colText},
+			exportColumn{"label", colText},
+			exportColumn{"reviewer", colText},
+			exportColumn{"note", colText},
+			exportColumn{"probability", colFloat},
+			exportColumn{"page", colInt},
+			exportColumn{"start_char", colInt},
+			exportColumn{"end_char", colInt},
+			exportColumn{"passage", colText},
+			exportColumn{"context", colText},
+			exportColumn{"reviewed", colText},
+		)
+		query := `
+		SELECT ` + exportItemSelect + `,
+			l.reference_id,
+			l.verse_id,
+			l.label,
+			l.reviewer,
+			l.note,
+			l.probability::numeric::double precision,
+			l.page::bigint,
+			l.start_char::bigint,
+			l.end_char::bigint,
+			substr(l.context, l.start_char - l.context_start + 1, l.end_char - l.start_char),
+			l.context,
+			to_char(l.reviewed AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+		FROM results.quotation_labels l
+		JOIN items i ON l.item_id = i.id
+		ORDER BY l.reviewed;`
+
+		var out io.Writer = os.Stdout
+		if exportOutput != "" && exportOutput != "-" {
+			f, err := os.Create(exportOutput)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to create the output file with error:\n	%s\n", err)
+				shutdown(nil, nil)
+				os.Exit(25)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		w, err := newRowWriter(exportFormat, out, columns)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to export labels with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(25)
+		}
+		n, err := exportRows(context.Background(), query, nil, columns, w)
+		if err == nil {
+			err = w.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to export labels with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(25)
+		}
+
+		fmt.Fprintf(os.Stderr, "Exported %d labels successfully\n", n)
+	},
+	PostRun: shutdown,
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+	reviewCmd.AddCommand(reviewSampleCmd, reviewPrecisionCmd, reviewExportCmd)
+	for _, c := range []*cobra.Command{reviewSampleCmd, reviewPrecisionCmd} {
+		c.Flags().Float64SliceVar(&reviewBands, "bands", []float64{0.57, 0.7, 0.8, 0.9, 1}, "boundaries of the probability bands (comma separated)")
+	}
+	reviewSampleCmd.Flags().IntVarP(&reviewPerBand, "per-band", "n", 10, "number of quotations to sample from each band")
+	reviewSampleCmd.Flags().StringVar(&reviewReference, "reference", "", "only quotations of this verse, such as \"John 3:16\"")
+	reviewSampleCmd.Flags().StringVar(&reviewReviewer, "reviewer", os.Getenv("USER"), "name of the reviewer")
+	reviewExportCmd.Flags().StringVar(&exportFormat, "format", "csv", "output format: csv, jsonl, or parquet")
+	reviewExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "file to write to (default is standard output)")
+}
+
+// showQuotation prints a quotation for review, with the passage that matched
+// the verse marked in its context.
+func showQuotation(w io.Writer, i, n int, q *results.Quotation) {
+	fmt.Fprintf(w, "\n[%d/%d] %s  probability %.3f\n", i, n, q.VerseID, q.Probability)
+	fmt.Fprintf(w, "Item: %s\n", q.ItemID)
+	p := q.Passage
+	if p == nil {
+		fmt.Fprintln(w, "The passage for this quotation was not recorded.")
+		return
+	}
+	runes := []rune(p.Context)
+	start, end := p.StartChar-p.ContextStart, p.EndChar-p.ContextStart
+	if start < 0 || end > len(runes) || start > end {
+		fmt.Fprintf(w, "Page %d:\n%s\n", p.Page, p.Context)
+		return
+	}
+	fmt.Fprintf(w, "Page %d, characters %d–%d:\n%s»%s«%s\n", p.Page, p.StartChar, p.EndChar,
+		string(runes[:start]), string(runes[start:end]), string(runes[end:]))
+}
+
+// askLabel reads a label and an optional note from the reviewer. The label is
+// empty if the reviewer skipped the quotation, and ok is false if the reviewer
+// wants to stop.
+func askLabel(input *bufio.Scanner) (label, note string, ok bool) {
+	for {
+		fmt.Print("[q]uotation, [f]alse positive, [a]llusion, [s]kip, or e[x]it (add a note after a space): ")
+		if !input.Scan() {
+			return "", "", false
+		}
+		answer := strings.TrimSpace(input.Text())
+		parts := strings.SplitN(answer, " ", 2)
+		key := strings.ToLower(parts[0])
+		if len(parts) == 2 {
+			note = strings.TrimSpace(parts[1])
+		}
+		switch key {
+		case "x":
+			return "", "", false
+		case "s":
+			return "", "", true
+		}
+		if label, valid := reviewLabels[key]; valid {
+			return label, note, true
+		}
+	}
+}
+
+// formatShare formats a proportion, which might not be defined
+func formatShare(p float64) string {
+	if math.IsNaN(p) {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", p)
+}
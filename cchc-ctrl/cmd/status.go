@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var statusOutput string
+var statusWatch bool
+var statusInterval time.Duration
+var statusCollections int
+
+// throughputWindows are the recent periods over which throughput is reported
+var throughputWindows = []struct {
+	Label    string
+	Duration time.Duration
+}{
+	{"last hour", time.Hour},
+	{"last day", 24 * time.Hour},
+	{"last week", 7 * 24 * time.Hour},
+}
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Report the progress of the pipeline",
+	Long: `Reports on the progress of the pipeline: how much of each collection has
+been crawled, how many items have had their metadata fetched, how many jobs
+there are for each destination and status, how many items and jobs have been
+processed recently, and the size of the tables in the database.
+
+The report can be printed as a table (the default) or as JSON. With the
+--watch flag, the report is refreshed until the command is interrupted.
+`,
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		if statusOutput != "table" && statusOutput != "json" {
+			fmt.Printf("The output format must be `table` or `json`, not `%s`.\n", statusOutput)
+			shutdown(nil, nil)
+			os.Exit(11)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		for {
+			status, err := getStatus(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				fmt.Printf("Failed to get the status of the pipeline with error:\n	%s\n", err)
+				shutdown(nil, nil)
+				os.Exit(12)
+			}
+
+			if statusWatch && statusOutput == "table" {
+				// Clear the terminal before redrawing the report
+				fmt.Print("\033[H\033[2J")
+			}
+			if statusOutput == "json" {
+				err = status.writeJSON(os.Stdout)
+			} else {
+				err = status.writeTable(os.Stdout)
+			}
+			if err != nil {
+				fmt.Printf("Failed to write the status of the pipeline with error:\n	%s\n", err)
+				shutdown(nil, nil)
+				os.Exit(13)
+			}
+
+			if !statusWatch {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(statusInterval):
+			}
+		}
+	},
+	PostRun: shutdown,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().StringVarP(&statusOutput, "output", "o", "table", "output format: table or json")
+	statusCmd.Flags().BoolVarP(&statusWatch, "watch", "w", false, "refresh the report until interrupted")
+	statusCmd.Flags().DurationVar(&statusInterval, "interval", 30*time.Second, "how often to refresh the report when watching")
+	statusCmd.Flags().IntVar(&statusCollections, "collections", 10, "number of collections to report crawl coverage for")
+}
+
+// pipelineStatus is a snapshot of the progress of the pipeline
+type pipelineStatus struct {
+	Generated   time.Time            `json:"generated"`
+	Crawl       crawlCoverage        `json:"crawl"`
+	Collections []collectionCoverage `json:"collections"`
+	Items       []itemCount          `json:"items"`
+	Jobs        []jobCount           `json:"jobs"`
+	Throughput  []throughput         `json:"throughput"`
+	Tables      []tableSize          `json:"tables"`
+}
+
+// crawlCoverage compares the number of items the loc.gov API reports for the
+// collections to the number of items the crawler has found
+type crawlCoverage struct {
+	Collections        int   `json:"collections"`
+	CollectionsCrawled int   `json:"collections_crawled"`
+	ItemsExpected      int64 `json:"items_expected"`
+	ItemsFound         int64 `json:"items_found"`
+}
+
+type collectionCoverage struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Items    int64  `json:"items"`
+	Expected int64  `json:"expected"`
+}
+
+type itemCount struct {
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+}
+
+type jobCount struct {
+	Destination string `json:"destination"`
+	Status      string `json:"status"`
+	Count       int64  `json:"count"`
+}
+
+type throughput struct {
+	Window      string `json:"window"`
+	Seconds     int64  `json:"seconds"`
+	Destination string `json:"destination"`
+	Count       int64  `json:"count"`
+}
+
+type tableSize struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Pretty string `json:"pretty_size"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// getStatus queries the database for the status of the pipeline. Counting
+// items in a large database can be slow, so this gets a generous timeout.
+func getStatus(parent context.Context) (*pipelineStatus, error) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Minute)
+	defer cancel()
+
+	s := &pipelineStatus{Generated: time.Now()}
+
+	coverageQuery := `
+	SELECT
+		COUNT(*),
+		COUNT(*) FILTER (WHERE EXISTS (
+			SELECT 1 FROM items_in_collections WHERE collection_id = collections.id)),
+		COALESCE(SUM(count), 0),
+		(SELECT COUNT(DISTINCT item_id) FROM items_in_collections)
+	FROM collections;
+	`
+	err := database.QueryRow(ctx, coverageQuery).Scan(&s.Crawl.Collections,
+		&s.Crawl.CollectionsCrawled, &s.Crawl.ItemsExpected, &s.Crawl.ItemsFound)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting crawl coverage: %w", err)
+	}
+
+	collectionsQuery := `
+	SELECT s.id, COALESCE(s.title, ''), s.n_items, COALESCE(c.count, 0)
+	FROM stats.items_per_collection s
+	JOIN collections c ON s.id = c.id
+	ORDER BY s.n_items DESC
+	LIMIT $1;
+	`
+	rows, err := database.Query(ctx, collectionsQuery, statusCollections)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting items per collection: %w", err)
+	}
+	for rows.Next() {
+		var c collectionCoverage
+		err = rows.Scan(&c.ID, &c.Title, &c.Items, &c.Expected)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.Collections = append(s.Collections, c)
+	}
+	rows.Close()
+
+	rows, err = database.Query(ctx, `SELECT type, n FROM stats.items_status;`)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting item status: %w", err)
+	}
+	for rows.Next() {
+		var i itemCount
+		err = rows.Scan(&i.Type, &i.Count)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.Items = append(s.Items, i)
+	}
+	rows.Close()
+
+	rows, err = database.Query(ctx, `SELECT destination, status::text, num_jobs FROM stats.job_status_ft;`)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting job status: %w", err)
+	}
+	for rows.Next() {
+		var j jobCount
+		err = rows.Scan(&j.Destination, &j.Status, &j.Count)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.Jobs = append(s.Jobs, j)
+	}
+	rows.Close()
+
+	// Item metadata fetched recently is reported alongside the job destinations
+	throughputQuery := `
+	SELECT 'itemmd' AS destination, COUNT(*)
+	FROM items
+	WHERE api IS NOT NULL AND updated >= NOW() - make_interval(secs => $1)
+	UNION ALL
+	SELECT destination, COUNT(*)
+	FROM jobs.fulltext
+	WHERE status = 'finished' AND finished >= NOW() - make_interval(secs => $1)
+	GROUP BY destination;
+	`
+	for _, w := range throughputWindows {
+		rows, err = database.Query(ctx, throughputQuery, w.Duration.Seconds())
+		if err != nil {
+			return nil, fmt.Errorf("Error getting throughput: %w", err)
+		}
+		for rows.Next() {
+			t := throughput{Window: w.Label, Seconds: int64(w.Duration.Seconds())}
+			err = rows.Scan(&t.Destination, &t.Count)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			s.Throughput = append(s.Throughput, t)
+		}
+		rows.Close()
+	}
+
+	tablesQuery := `
+	SELECT namespace, tablename, total_size, size
+	FROM stats.table_sizes
+	WHERE type::text IN ('r', 'm');
+	`
+	rows, err = database.Query(ctx, tablesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting table sizes: %w", err)
+	}
+	for rows.Next() {
+		var t tableSize
+		err = rows.Scan(&t.Schema, &t.Table, &t.Pretty, &t.Bytes)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.Tables = append(s.Tables, t)
+	}
+	rows.Close()
+
+	return s, rows.Err()
+}
+
+// writeJSON writes the status as a single JSON object
+func (s *pipelineStatus) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// writeTable writes the status as a series of aligned tables
+func (s *pipelineStatus) writeTable(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Pipeline status at %s\n\n", s.Generated.Format(time.RFC1123))
+
+	fmt.Fprintln(w, "CRAWL COVERAGE")
+	fmt.Fprintf(w, "Collections crawled\t%d of %d\n", s.Crawl.CollectionsCrawled, s.Crawl.Collections)
+	fmt.Fprintf(w, "Items found\t%d of %d (%s)\n", s.Crawl.ItemsFound, s.Crawl.ItemsExpected,
+		percent(s.Crawl.ItemsFound, s.Crawl.ItemsExpected))
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "COLLECTION\tITEMS\tEXPECTED\tCOVERAGE")
+	for _, c := range s.Collections {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", truncate(c.Title, 60), c.Items, c.Expected, percent(c.Items, c.Expected))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "ITEMS\tCOUNT")
+	for _, i := range s.Items {
+		fmt.Fprintf(w, "%s\t%d\n", i.Type, i.Count)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "DESTINATION\tSTATUS\tJOBS")
+	for _, j := range s.Jobs {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", j.Destination, j.Status, j.Count)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "WINDOW\tDESTINATION\tPROCESSED\tPER HOUR")
+	for _, t := range s.Throughput {
+		perHour := float64(t.Count) / (float64(t.Seconds) / 3600)
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\n", t.Window, t.Destination, t.Count, perHour)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "TABLE\tSIZE")
+	for _, t := range s.Tables {
+		fmt.Fprintf(w, "%s.%s\t%s\n", t.Schema, t.Table, t.Pretty)
+	}
+
+	return w.Flush()
+}
+
+// percent formats a ratio as a percentage, if it can be computed
+func percent(n, total int64) string {
+	if total <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
+
+// truncate shortens long strings such as collection titles for display
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
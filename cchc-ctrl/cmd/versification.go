@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/lmullen/cchc/common/results"
+	"github.com/spf13/cobra"
+)
+
+var versificationReplace bool
+var versificationDestination string
+
+// versificationCmd represents the versification command
+var versificationCmd = &cobra.Command{
+	Use:   "versification <mapping.csv>",
+	Short: "Load a mapping between the verse numbering of translations",
+	Args:  cobra.ExactArgs(1),
+	Long: `Loads a mapping from the verses in a translation to their canonical
+references into the results.versification table, so that quotations found in
+translations which number verses differently (such as the Psalms in the
+Douay-Rheims) or name books in other languages (such as the Luther Bible) can be
+aggregated with the quotations found in other translations.
+
+The mapping is a CSV file with a header and three columns: the ID of the
+translation (as in CCHC_BIBLES for the quotation detector), the reference in
+that translation, and the canonical reference. For example:
+
+  translation,reference_id,canonical_reference_id
+  douay,Psalms 22:1,Psalms 23:1
+  luther,Johannes 3:16,John 3:16
+
+A verse which is not in the mapping is its own canonical reference. Pass
+--replace to remove the existing mappings for the translations in the file
+first. Since quotations are counted by their canonical references, every
+probability threshold is recounted after the mapping is loaded.
+`,
+	PreRun: connectDB,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		repo := results.NewRepo(database)
+
+		// Look up the destination being counted before the counts are reset
+		destination, err := countsDestination(ctx, cmd, repo, versificationDestination)
+		if err != nil {
+			fmt.Printf("Failed to get the destination of the counted jobs with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(28)
+		}
+
+		mappings, err := readVersification(args[0])
+		if err != nil {
+			fmt.Printf("Failed to read the mapping with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(27)
+		}
+
+		err = repo.SaveVersification(ctx, mappings, versificationReplace)
+		if err != nil {
+			fmt.Printf("Failed to save the mapping with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(27)
+		}
+		fmt.Printf("Loaded %d verse mappings successfully\n", len(mappings))
+
+		// The counts were made with the old mapping, so recount every threshold
+		var thresholds []float64
+		rows, err := database.Query(ctx, `SELECT threshold::numeric::double precision FROM results.quotation_thresholds;`)
+		if err != nil {
+			fmt.Printf("Failed to get the thresholds with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(28)
+		}
+		for rows.Next() {
+			var t float64
+			err = rows.Scan(&t)
+			if err != nil {
+				fmt.Printf("Failed to get the thresholds with error:\n	%s\n", err)
+				shutdown(nil, nil)
+				os.Exit(28)
+			}
+			thresholds = append(thresholds, t)
+		}
+		rows.Close()
+		for _, t := range thresholds {
+			err := repo.ResetQuotationCounts(ctx, t)
+			if err != nil {
+				fmt.Printf("Failed to reset the counts at the threshold %v with error:\n	%s\n", t, err)
+				shutdown(nil, nil)
+				os.Exit(28)
+			}
+		}
+		fmt.Println("Recounting quotations might take a long time ...")
+		n, err := repo.RefreshQuotationCounts(ctx, destination)
+		if err != nil {
+			fmt.Printf("Failed to count quotations with error:\n	%s\n", err)
+			shutdown(nil, nil)
+			os.Exit(28)
+		}
+		fmt.Printf("Counted the quotations from %d jobs successfully\n", n)
+	},
+	PostRun: shutdown,
+}
+
+func init() {
+	rootCmd.AddCommand(versificationCmd)
+	versificationCmd.Flags().BoolVar(&versificationReplace, "replace", false, "remove the existing mappings for the translations in the file")
+	versificationCmd.Flags().StringVarP(&versificationDestination, "destination", "d", "quotations", "destination of the jobs which found the quotations, if not the one already counted")
+}
+
+// readVersification reads a CSV file of verse mappings, skipping the header.
+func readVersification(path string) ([]*results.VerseMapping, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 3
+	r.TrimLeadingSpace = true
+	_, err = r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the header: %w", err)
+	}
+
+	var mappings []*results.VerseMapping
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+			if row[i] == "" {
+				line, _ := r.FieldPos(i)
+				return nil, fmt.Errorf("empty value on line %d", line)
+			}
+		}
+		mappings = append(mappings, &results.VerseMapping{
+			Translation:          strings.ToLower(row[0]),
+			ReferenceID:          row[1],
+			CanonicalReferenceID: row[2],
+		})
+	}
+	return mappings, nil
+}
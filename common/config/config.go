@@ -0,0 +1,164 @@
+// Package config loads the configuration shared by the CCHC services.
+//
+// Settings are read from, in increasing order of precedence, the defaults, a
+// YAML or TOML configuration file, environment variables, and command-line
+// flags. The configuration file is set with the --config flag or the
+// CCHC_CONFIG environment variable.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lmullen/cchc/common/logging"
+	"github.com/lmullen/cchc/common/sources"
+	"go.uber.org/ratelimit"
+)
+
+// Config is the configuration for a service. The tags give the keys used in
+// configuration files; environment variables and flags are derived from the
+// names of the flags.
+type Config struct {
+	DBStr          string        `yaml:"dbstr" toml:"dbstr"`
+	LogLevel       string        `yaml:"loglevel" toml:"loglevel"`
+	LogFormat      string        `yaml:"logformat" toml:"logformat"` // Either text or json
+	AutoMigrate    bool          `yaml:"auto_migrate" toml:"auto_migrate"`
+	WaitTime       time.Duration `yaml:"waittime" toml:"waittime"`             // How long to wait before checking for more work
+	JobTimeout     time.Duration `yaml:"jobtimeout" toml:"jobtimeout"`         // How long a batch of jobs may take
+	DBTimeout      time.Duration `yaml:"dbtimeout" toml:"dbtimeout"`           // How long a single database call may take
+	APITimeout     time.Duration `yaml:"apitimeout" toml:"apitimeout"`         // How long a single API request may take
+	CrawlInterval  time.Duration `yaml:"crawl_interval" toml:"crawl_interval"` // How long to wait between crawls
+	ItemsPerBatch  int           `yaml:"items_per_batch" toml:"items_per_batch"`
+	PagesPerBatch  int           `yaml:"pages_per_batch" toml:"pages_per_batch"`
+	Sources        Sources       `yaml:"sources" toml:"sources"` // Where the documents for jobs come from
+	RateLimits     RateLimits    `yaml:"ratelimits" toml:"ratelimits"`
+	MetricsAddr    string        `yaml:"metrics_addr" toml:"metrics_addr"`       // Where to serve metrics and health checks, or empty for none
+	ProgressWindow time.Duration `yaml:"progress_window" toml:"progress_window"` // How long without progress before a service is unhealthy, or 0 to set automatically
+	Language       Language      `yaml:"language" toml:"language"`               // Settings for the language detector
+	Quotations     Quotations    `yaml:"quotations" toml:"quotations"`           // Settings for the quotation detector
+}
+
+// RateLimits are the limits on requests to each of the LOC.gov API endpoints.
+type RateLimits struct {
+	Items       RateLimit `yaml:"items" toml:"items"`
+	Collections RateLimit `yaml:"collections" toml:"collections"`
+	Newspapers  RateLimit `yaml:"newspapers" toml:"newspapers"`
+}
+
+// Default returns the default configuration. Services should change any
+// defaults that differ for them before calling Load.
+//
+// The default rate limits leave a buffer below the officially presented rate
+// limits: https://www.loc.gov/apis/json-and-yaml/
+func Default() *Config {
+	return &Config{
+		LogLevel:      "info",
+		LogFormat:     logging.FormatText,
+		WaitTime:      15 * time.Minute,
+		JobTimeout:    30 * time.Minute,
+		DBTimeout:     30 * time.Second,
+		APITimeout:    60 * time.Second,
+		CrawlInterval: 2 * 24 * time.Hour,
+		ItemsPerBatch: 20,
+		PagesPerBatch: 500,
+		Sources:       Sources{sources.Items},
+		MetricsAddr:   ":2112",
+		RateLimits: RateLimits{
+			Items:       RateLimit{Requests: 200 - 20, Per: time.Minute},    // 200 requests/minute
+			Collections: RateLimit{Requests: 80 - 20, Per: time.Minute},     // 80 requests/minute
+			Newspapers:  RateLimit{Requests: 20 - 4, Per: 10 * time.Second}, // 120 requests/minute
+		},
+		Language: Language{
+			Output:         LanguageOutputItems,
+			Backend:        "lingua",
+			SecondaryShare: 0.1,
+		},
+	}
+}
+
+// Validate checks that the configuration can be used.
+func (c *Config) Validate() error {
+	if c.DBStr == "" {
+		return errors.New("The database connection string must be set with CCHC_DBSTR, --dbstr, or in a configuration file")
+	}
+	switch c.LogLevel {
+	case "error", "warn", "info", "debug", "trace":
+	default:
+		return fmt.Errorf("The log level must be one of error, warn, info, debug, or trace, not %q", c.LogLevel)
+	}
+	switch c.LogFormat {
+	case logging.FormatText, logging.FormatJSON:
+	default:
+		return fmt.Errorf("The log format must be %s or %s, not %q", logging.FormatText, logging.FormatJSON, c.LogFormat)
+	}
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"waittime", c.WaitTime},
+		{"jobtimeout", c.JobTimeout},
+		{"dbtimeout", c.DBTimeout},
+		{"apitimeout", c.APITimeout},
+		{"crawl interval", c.CrawlInterval},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			return fmt.Errorf("The %s must be greater than zero, not %s", d.name, d.value)
+		}
+	}
+	if c.ProgressWindow < 0 {
+		return fmt.Errorf("The progress window must not be negative, not %s", c.ProgressWindow)
+	}
+	if c.ItemsPerBatch < 1 {
+		return fmt.Errorf("The items per batch must be at least 1, not %d", c.ItemsPerBatch)
+	}
+	if c.PagesPerBatch < 1 {
+		return fmt.Errorf("The pages per batch must be at least 1, not %d", c.PagesPerBatch)
+	}
+	if len(c.Sources) == 0 {
+		return errors.New("At least one source of documents must be set")
+	}
+	limits := []struct {
+		name  string
+		value RateLimit
+	}{
+		{"items", c.RateLimits.Items},
+		{"collections", c.RateLimits.Collections},
+		{"newspapers", c.RateLimits.Newspapers},
+	}
+	for _, l := range limits {
+		if l.value.Requests < 1 || l.value.Per <= 0 {
+			return fmt.Errorf("The rate limit for %s must be a positive number of requests per positive duration, not %s", l.name, l.value)
+		}
+	}
+	switch c.Language.Output {
+	case LanguageOutputItems, LanguageOutputSpans, LanguageOutputBoth:
+	default:
+		return fmt.Errorf("The language output must be one of %s, %s, or %s, not %q",
+			LanguageOutputItems, LanguageOutputSpans, LanguageOutputBoth, c.Language.Output)
+	}
+	if c.Language.Backend == "" {
+		return errors.New("The language backend must be set")
+	}
+	if c.Language.MinConfidence < 0 || c.Language.MinConfidence > 1 {
+		return fmt.Errorf("The minimum language confidence must be from 0 to 1, not %v", c.Language.MinConfidence)
+	}
+	if c.Language.MinLength < 0 {
+		return fmt.Errorf("The minimum sentence length must not be negative, not %d", c.Language.MinLength)
+	}
+	if c.Language.SecondaryShare <= 0 || c.Language.SecondaryShare > 1 {
+		return fmt.Errorf("The secondary language share must be greater than 0 and at most 1, not %v", c.Language.SecondaryShare)
+	}
+	return nil
+}
+
+// SetupLogging sets the level and format of the logger for a service.
+func (c *Config) SetupLogging(service string) error {
+	return logging.Setup(service, c.LogLevel, c.LogFormat)
+}
+
+// Limiter returns a rate limiter which allows this many requests.
+func (r RateLimit) Limiter() ratelimit.Limiter {
+	return ratelimit.New(r.Requests, ratelimit.Per(r.Per))
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"strings"
+)
+
+// Output modes for the language detector. Item-level output records the number
+// of sentences in each language for the whole item. Span output records runs of
+// sentences in the same language on each page.
+const (
+	LanguageOutputItems = "items"
+	LanguageOutputSpans = "spans"
+	LanguageOutputBoth  = "both"
+)
+
+// Language is the configuration for the language detector.
+type Language struct {
+	Output         string    `yaml:"output" toml:"output"`                   // Which results to record: items, spans, or both
+	Backend        string    `yaml:"backend" toml:"backend"`                 // The library used to identify languages
+	LowAccuracy    bool      `yaml:"low_accuracy" toml:"low_accuracy"`       // Whether to run lingua in its faster, low accuracy mode
+	Languages      Languages `yaml:"languages" toml:"languages"`             // The languages to consider, or empty for all of them
+	MinConfidence  float64   `yaml:"min_confidence" toml:"min_confidence"`   // Sentences identified with less confidence are undetermined
+	MinLength      int       `yaml:"min_length" toml:"min_length"`           // Shorter sentences are undetermined
+	SecondaryShare float64   `yaml:"secondary_share" toml:"secondary_share"` // The share of sentences a secondary language must have
+}
+
+// Languages are ISO 639-3 codes for languages. They are written as a
+// comma-separated list, such as eng,deu,spa.
+type Languages []string
+
+// String formats the languages as a comma-separated list.
+func (l Languages) String() string {
+	return strings.Join(l, ",")
+}
+
+// Set parses a comma-separated list of languages, replacing any already set.
+func (l *Languages) Set(value string) error {
+	var parsed Languages
+	for _, code := range strings.Split(value, ",") {
+		code = strings.TrimSpace(code)
+		if code != "" {
+			parsed = append(parsed, code)
+		}
+	}
+	*l = parsed
+	return nil
+}
+
+// Type is the name of the type used in flag help.
+func (l *Languages) Type() string {
+	return "languages"
+}
+
+// MarshalText lets languages be written to configuration files.
+func (l Languages) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText lets languages be read from configuration files.
+func (l *Languages) UnmarshalText(text []byte) error {
+	return l.Set(string(text))
+}
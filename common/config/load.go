@@ -0,0 +1,143 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads the configuration file, environment variables, and command-line
+// arguments on top of the configuration, which should already hold the
+// defaults, and then validates the result. The name of the program is used in
+// the help for the flags.
+func (c *Config) Load(name string, args []string) error {
+	var file string
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	fs.StringVar(&file, "config", os.Getenv("CCHC_CONFIG"), "path to a YAML or TOML configuration file")
+	c.bind(fs)
+
+	// Parse the flags first to find the configuration file, but remember what they
+	// were, since they should take precedence over the file and the environment.
+	defaults := *c
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	flags := make(map[string]string)
+	fs.Visit(func(f *pflag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+	*c = defaults
+
+	if file != "" {
+		err = c.readFile(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	var errs []string
+	fs.VisitAll(func(f *pflag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+		err := fs.Set(f.Name, value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", envName(f.Name), err))
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("Invalid environment variables: %s", strings.Join(errs, "; "))
+	}
+
+	for flag, value := range flags {
+		if flag == "config" {
+			continue
+		}
+		err = fs.Set(flag, value)
+		if err != nil {
+			return fmt.Errorf("Invalid value for --%s: %w", flag, err)
+		}
+	}
+
+	return c.Validate()
+}
+
+// bind registers a flag for each setting.
+func (c *Config) bind(fs *pflag.FlagSet) {
+	fs.StringVar(&c.DBStr, "dbstr", c.DBStr, "URL of the PostgreSQL database")
+	fs.StringVar(&c.LogLevel, "loglevel", c.LogLevel, "log level: error, warn, info, debug, or trace")
+	fs.StringVar(&c.LogFormat, "logformat", c.LogFormat, "log format: text or json")
+	fs.BoolVar(&c.AutoMigrate, "auto-migrate", c.AutoMigrate, "apply pending migrations if the database schema is behind")
+	fs.DurationVar(&c.WaitTime, "waittime", c.WaitTime, "how long to wait before checking for more work")
+	fs.DurationVar(&c.JobTimeout, "jobtimeout", c.JobTimeout, "how long a batch of jobs may take")
+	fs.DurationVar(&c.DBTimeout, "dbtimeout", c.DBTimeout, "how long a single database call may take")
+	fs.DurationVar(&c.APITimeout, "apitimeout", c.APITimeout, "how long a single API request may take")
+	fs.DurationVar(&c.CrawlInterval, "crawl-interval", c.CrawlInterval, "how long to wait between crawls of all collections")
+	fs.IntVar(&c.ItemsPerBatch, "items-per-batch", c.ItemsPerBatch, "maximum number of items in a batch")
+	fs.IntVar(&c.PagesPerBatch, "pages-per-batch", c.PagesPerBatch, "maximum number of pages in a batch")
+	fs.Var(&c.Sources, "sources", "sources of documents to create jobs for, in order: items, stacks")
+	fs.Var(&c.RateLimits.Items, "ratelimit-items", "rate limit for the items API, as requests/period")
+	fs.Var(&c.RateLimits.Collections, "ratelimit-collections", "rate limit for the collections API, as requests/period")
+	fs.Var(&c.RateLimits.Newspapers, "ratelimit-newspapers", "rate limit for the newspapers API, as requests/period")
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", c.MetricsAddr, "address to serve metrics and health checks, or empty to disable")
+	fs.DurationVar(&c.ProgressWindow, "progress-window", c.ProgressWindow, "how long without progress before the service is unhealthy (0 to set automatically)")
+	fs.StringVar(&c.Language.Output, "language-output", c.Language.Output, "language results to record: items, spans, or both")
+	fs.StringVar(&c.Language.Backend, "language-backend", c.Language.Backend, "library used to identify languages: lingua, whatlang, or combined")
+	fs.BoolVar(&c.Language.LowAccuracy, "language-low-accuracy", c.Language.LowAccuracy, "run lingua in its faster, low accuracy mode")
+	fs.Var(&c.Language.Languages, "languages", "ISO 639-3 codes of the languages to consider, separated by commas (all if empty)")
+	fs.Float64Var(&c.Language.MinConfidence, "language-min-confidence", c.Language.MinConfidence, "confidence below which a sentence's language is undetermined")
+	fs.IntVar(&c.Language.MinLength, "language-min-length", c.Language.MinLength, "length in characters below which a sentence's language is undetermined")
+	fs.Float64Var(&c.Language.SecondaryShare, "language-secondary-share", c.Language.SecondaryShare, "share of sentences a language needs to be a secondary language")
+	fs.StringVar(&c.Quotations.Corpus, "corpus", c.Quotations.Corpus, "ID of a reference corpus to look for quotations of instead of the Bible")
+	fs.Var(&c.Quotations.Bibles, "bibles", "reference texts to look for quotations in, as id=payload pairs separated by commas")
+}
+
+// envName is the environment variable for a flag, so --items-per-batch is
+// CCHC_ITEMS_PER_BATCH.
+func envName(flag string) string {
+	return "CCHC_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// readFile reads a YAML or TOML configuration file, depending on its extension.
+// Keys which are not settings are an error, since they are most likely typos.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading configuration file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("Error parsing configuration file %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("Error parsing configuration file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("Unknown settings in configuration file %s: %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("Configuration file %s must end in .yaml, .yml, or .toml", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// redacted replaces secrets when the configuration is printed
+const redacted = "xxxxx"
+
+// passwordSetting matches the password in a keyword/value connection string
+var passwordSetting = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// Fields returns the effective configuration, with secrets redacted, for
+// logging.
+func (c *Config) Fields() log.Fields {
+	return log.Fields{
+		"dbstr":                    redactDBStr(c.DBStr),
+		"loglevel":                 c.LogLevel,
+		"logformat":                c.LogFormat,
+		"auto_migrate":             c.AutoMigrate,
+		"waittime":                 c.WaitTime.String(),
+		"jobtimeout":               c.JobTimeout.String(),
+		"dbtimeout":                c.DBTimeout.String(),
+		"apitimeout":               c.APITimeout.String(),
+		"crawl_interval":           c.CrawlInterval.String(),
+		"items_per_batch":          c.ItemsPerBatch,
+		"pages_per_batch":          c.PagesPerBatch,
+		"sources":                  c.Sources.String(),
+		"ratelimit_items":          c.RateLimits.Items.String(),
+		"ratelimit_collections":    c.RateLimits.Collections.String(),
+		"ratelimit_newspapers":     c.RateLimits.Newspapers.String(),
+		"metrics_addr":             c.MetricsAddr,
+		"progress_window":          c.ProgressWindow.String(),
+		"language_output":          c.Language.Output,
+		"language_backend":         c.Language.Backend,
+		"language_low_accuracy":    c.Language.LowAccuracy,
+		"languages":                c.Language.Languages.String(),
+		"language_min_confidence":  c.Language.MinConfidence,
+		"language_min_length":      c.Language.MinLength,
+		"language_secondary_share": c.Language.SecondaryShare,
+		"corpus":                   c.Quotations.Corpus,
+		"bibles":                   c.Quotations.Bibles.String(),
+	}
+}
+
+// Log prints the effective configuration, with secrets redacted.
+func (c *Config) Log() {
+	log.WithFields(c.Fields()).Info("Loaded the configuration")
+}
+
+// redactDBStr hides the password in a database connection string, whether it
+// is written as a URL or as keyword/value pairs.
+func redactDBStr(dbstr string) string {
+	u, err := url.Parse(dbstr)
+	if err == nil && u.Scheme != "" {
+		if _, has := u.User.Password(); has {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		q := u.Query()
+		if q.Get("password") != "" {
+			q.Set("password", redacted)
+			u.RawQuery = q.Encode()
+		}
+		return u.String()
+	}
+	return passwordSetting.ReplaceAllString(dbstr, "${1}"+redacted)
+}
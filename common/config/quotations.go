@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Quotations is the configuration for the quotation detector.
+type Quotations struct {
+	Corpus string `yaml:"corpus" toml:"corpus"` // A reference corpus to look for instead of the Bible
+	Bibles Bibles `yaml:"bibles" toml:"bibles"` // The reference texts in which to look for biblical quotations
+}
+
+// Bible is a reference text in which to look for quotations, such as a
+// translation of the Bible, along with the path to the payload with its
+// vectorizer and document-term matrix for the prediction model. The ID is
+// recorded with each quotation found in the text.
+type Bible struct {
+	ID      string
+	Payload string
+}
+
+// Bibles are reference texts and their payloads. They are written as a
+// comma-separated list of id=payload pairs, such as
+// kjv=bible-payload.rda,douay=douay-payload.rda.
+type Bibles []Bible
+
+// String formats the reference texts as a comma-separated list.
+func (b Bibles) String() string {
+	pairs := make([]string, len(b))
+	for i, bible := range b {
+		pairs[i] = bible.ID + "=" + bible.Payload
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set parses a comma-separated list of reference texts, replacing any already
+// set. The IDs are lowercased, and each may only be given once.
+func (b *Bibles) Set(value string) error {
+	var parsed Bibles
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("each reference text must be given as id=payload, not %q", pair)
+		}
+		id := strings.ToLower(parts[0])
+		if seen[id] {
+			return fmt.Errorf("the reference text %s is given more than once", id)
+		}
+		seen[id] = true
+		parsed = append(parsed, Bible{ID: id, Payload: parts[1]})
+	}
+	*b = parsed
+	return nil
+}
+
+// Type is the name of the type used in flag help.
+func (b *Bibles) Type() string {
+	return "bibles"
+}
+
+// MarshalText lets reference texts be written to configuration files.
+func (b Bibles) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText lets reference texts be read from configuration files.
+func (b *Bibles) UnmarshalText(text []byte) error {
+	return b.Set(string(text))
+}
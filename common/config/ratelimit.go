@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit is a number of requests allowed per period of time. It is written
+// as requests/period, such as 180/1m.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// String formats the rate limit as requests/period.
+func (r RateLimit) String() string {
+	return fmt.Sprintf("%d/%s", r.Requests, r.Per)
+}
+
+// Set parses a rate limit written as requests/period.
+func (r *RateLimit) Set(s string) error {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 {
+		return fmt.Errorf("%q is not a rate limit written as requests/period, such as 180/1m", s)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return fmt.Errorf("%q does not have a valid number of requests: %w", s, err)
+	}
+	per, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return fmt.Errorf("%q does not have a valid period: %w", s, err)
+	}
+	r.Requests = requests
+	r.Per = per
+	return nil
+}
+
+// Type is the name of the type used in flag help.
+func (r *RateLimit) Type() string {
+	return "ratelimit"
+}
+
+// MarshalText lets rate limits be written to configuration files.
+func (r RateLimit) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText lets rate limits be read from configuration files.
+func (r *RateLimit) UnmarshalText(text []byte) error {
+	return r.Set(string(text))
+}
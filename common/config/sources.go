@@ -0,0 +1,41 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/lmullen/cchc/common/sources"
+)
+
+// Sources are the sources of documents for which a service creates jobs, in
+// order. They are written as a comma-separated list, such as items,stacks.
+type Sources []string
+
+// String formats the sources as a comma-separated list.
+func (s Sources) String() string {
+	return strings.Join(s, ",")
+}
+
+// Set parses a comma-separated list of sources, replacing any already set.
+func (s *Sources) Set(value string) error {
+	parsed, err := sources.Parse(value)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// Type is the name of the type used in flag help.
+func (s *Sources) Type() string {
+	return "sources"
+}
+
+// MarshalText lets sources be written to configuration files.
+func (s Sources) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText lets sources be read from configuration files.
+func (s *Sources) UnmarshalText(text []byte) error {
+	return s.Set(string(text))
+}
@@ -0,0 +1,100 @@
+package corpora_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+
+	"github.com/lmullen/cchc/common/corpora"
+	"github.com/lmullen/cchc/common/db"
+	"github.com/orlangure/gnomock"
+	"github.com/orlangure/gnomock/preset/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorporaDB(t *testing.T) {
+	t.Parallel()
+
+	user := "gnomock"
+	pass := "strong-passwords-are-the-best"
+	dbname := "cchc_gnomock_test_corpora"
+
+	p := postgres.Preset(
+		postgres.WithUser(user, pass),
+		postgres.WithDatabase(dbname),
+		postgres.WithVersion("14"),
+	)
+
+	container, err := gnomock.Start(p)
+	assert.NoError(t, err)
+	defer func() { assert.NoError(t, gnomock.Stop(container)) }()
+
+	connstr := fmt.Sprintf("postgres://%s:%s@%s:%v/%s?sslmode=disable",
+		user, pass, container.Host, container.DefaultPort(), dbname)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	db, _ := db.Connect(ctx, connstr, "corpora-test")
+	db.Ping(ctx)
+	m, _ := migrate.New("file://../../db/migrations", connstr)
+	m.Up()
+
+	var repo corpora.Repository
+	repo = corpora.NewCorporaRepo(db)
+
+	// A corpus that does not exist should return the expected error
+	_, err = repo.Get(ctx, "constitution")
+	assert.ErrorIs(t, err, corpora.ErrNotFound)
+
+	corpus := &corpora.Corpus{
+		ID:    "constitution",
+		Title: sql.NullString{String: "The Constitution of the United States", Valid: true},
+	}
+	passages := []*corpora.Passage{
+		{ID: "Preamble", Text: "We the People of the United States"},
+		{ID: "Amendment I", Text: "Congress shall make no law"},
+	}
+	err = repo.Save(ctx, corpus, passages, false)
+	require.NoError(t, err)
+
+	got, err := repo.Get(ctx, "constitution")
+	require.NoError(t, err)
+	assert.Equal(t, corpus.Title, got.Title)
+	assert.Equal(t, 2, got.Passages)
+
+	// Saving again updates the text and adds new passages, but only removes
+	// passages when replacing
+	passages = []*corpora.Passage{
+		{ID: "Preamble", Text: "We the People of the United States, in Order to form a more perfect Union"},
+		{ID: "Amendment II", Text: "A well regulated Militia"},
+	}
+	err = repo.Save(ctx, corpus, passages, false)
+	require.NoError(t, err)
+	saved, err := repo.Passages(ctx, "constitution")
+	require.NoError(t, err)
+	assert.Len(t, saved, 3)
+
+	err = repo.Save(ctx, corpus, passages, true)
+	require.NoError(t, err)
+	saved, err = repo.Passages(ctx, "constitution")
+	require.NoError(t, err)
+	require.Len(t, saved, 2)
+	assert.Equal(t, "Amendment II", saved[0].ID)
+	assert.Equal(t, passages[0].Text, saved[1].Text)
+
+	list, err := repo.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	// The Bible is not a reference corpus
+	err = repo.Save(ctx, &corpora.Corpus{ID: corpora.BibleID}, passages, false)
+	assert.ErrorIs(t, err, corpora.ErrReservedID)
+}
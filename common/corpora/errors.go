@@ -0,0 +1,11 @@
+package corpora
+
+import "errors"
+
+// ErrNotFound is returned when a corpus is not in the data store. This would be
+// an expected error.
+var ErrNotFound = errors.New("There is no reference corpus with that ID")
+
+// ErrReservedID is returned when saving a corpus with an ID which is used for
+// the biblical quotations.
+var ErrReservedID = errors.New("The ID bible is reserved for biblical quotations")
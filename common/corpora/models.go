@@ -0,0 +1,23 @@
+package corpora
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Corpus is a reference corpus, along with the number of its passages.
+type Corpus struct {
+	ID          string
+	Title       sql.NullString
+	Description sql.NullString
+	Passages    int
+	Updated     time.Time
+}
+
+// Passage is a passage in a reference corpus, such as a section of the
+// Constitution or a verse of a hymn. The ID is unique within the corpus.
+type Passage struct {
+	CorpusID string
+	ID       string
+	Text     string
+}
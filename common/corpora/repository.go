@@ -0,0 +1,18 @@
+// Package corpora allows for interactions with reference corpora, collections
+// of passages such as the Constitution or a hymnal in which to look for
+// quotations.
+//
+// It provides a Repository interface for generalized interactions storing and
+// retrieving corpora from a data store, as well as a concrete type that
+// implements that interface for a PostgreSQL database using the pgx package.
+package corpora
+
+import "context"
+
+// Repository is an interface describing a data store for reference corpora.
+type Repository interface {
+	Get(ctx context.Context, ID string) (*Corpus, error)
+	List(ctx context.Context) ([]*Corpus, error)
+	Save(ctx context.Context, corpus *Corpus, passages []*Passage, replace bool) error
+	Passages(ctx context.Context, corpusID string) ([]*Passage, error)
+}
@@ -0,0 +1,165 @@
+package corpora
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// BibleID is the ID of the Bible when quotations from every corpus are
+// combined. The Bible is not stored as a reference corpus.
+const BibleID = "bible"
+
+// Repo is a data store using PostgreSQL with the pgx native interface.
+type Repo struct {
+	db *pgxpool.Pool
+}
+
+// NewCorporaRepo returns a corpora repo using PostgreSQL with the pgx native
+// interface.
+func NewCorporaRepo(db *pgxpool.Pool) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+// Get fetches a corpus from the database by its ID.
+func (r *Repo) Get(ctx context.Context, ID string) (*Corpus, error) {
+	query := `
+	SELECT c.id, c.title, c.description, c.updated,
+		(SELECT COUNT(*) FROM reference_passages p WHERE p.corpus_id = c.id)
+	FROM reference_corpora c
+	WHERE c.id = $1;
+	`
+	c := Corpus{}
+	err := r.db.QueryRow(ctx, query, ID).Scan(&c.ID, &c.Title, &c.Description, &c.Updated, &c.Passages)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// List gets all the corpora in the database.
+func (r *Repo) List(ctx context.Context) ([]*Corpus, error) {
+	query := `
+	SELECT c.id, c.title, c.description, c.updated,
+		(SELECT COUNT(*) FROM reference_passages p WHERE p.corpus_id = c.id)
+	FROM reference_corpora c
+	ORDER BY c.id;
+	`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var corpora []*Corpus
+	for rows.Next() {
+		c := Corpus{}
+		err = rows.Scan(&c.ID, &c.Title, &c.Description, &c.Updated, &c.Passages)
+		if err != nil {
+			return nil, err
+		}
+		corpora = append(corpora, &c)
+	}
+	return corpora, rows.Err()
+}
+
+// Save serializes a corpus and its passages to the database. Passages which
+// already exist have their text updated. If replace is true, passages which
+// are not in the new passages are removed, which fails if they have already
+// been quoted.
+func (r *Repo) Save(ctx context.Context, corpus *Corpus, passages []*Passage, replace bool) error {
+	if corpus.ID == BibleID {
+		return ErrReservedID
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Roll back the transaction if something goes wrong
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO reference_corpora (id, title, description)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (id) DO UPDATE SET
+		title = EXCLUDED.title,
+		description = EXCLUDED.description,
+		updated = NOW();
+	`, corpus.ID, corpus.Title, corpus.Description)
+	if err != nil {
+		return err
+	}
+
+	// Copy into a temporary table first, since passages might already exist
+	_, err = tx.Exec(ctx, `
+	CREATE TEMPORARY TABLE new_passages
+	(LIKE reference_passages) ON COMMIT DROP;
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"new_passages"},
+		[]string{"corpus_id", "passage_id", "text"},
+		pgx.CopyFromSlice(len(passages), func(i int) ([]interface{}, error) {
+			return []interface{}{corpus.ID, passages[i].ID, passages[i].Text}, nil
+		}))
+	if err != nil {
+		return err
+	}
+
+	if replace {
+		_, err = tx.Exec(ctx, `
+		DELETE FROM reference_passages p
+		WHERE p.corpus_id = $1
+			AND NOT EXISTS (SELECT 1 FROM new_passages n WHERE n.passage_id = p.passage_id);
+		`, corpus.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO reference_passages (corpus_id, passage_id, text)
+	SELECT DISTINCT ON (passage_id) corpus_id, passage_id, text FROM new_passages
+	ON CONFLICT (corpus_id, passage_id) DO UPDATE SET text = EXCLUDED.text;
+	`)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Passages gets all the passages in a corpus.
+func (r *Repo) Passages(ctx context.Context, corpusID string) ([]*Passage, error) {
+	query := `
+	SELECT corpus_id, passage_id, text
+	FROM reference_passages
+	WHERE corpus_id = $1
+	ORDER BY passage_id;
+	`
+	rows, err := r.db.Query(ctx, query, corpusID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var passages []*Passage
+	for rows.Next() {
+		p := Passage{}
+		err = rows.Scan(&p.CorpusID, &p.ID, &p.Text)
+		if err != nil {
+			return nil, err
+		}
+		passages = append(passages, &p)
+	}
+	return passages, rows.Err()
+}
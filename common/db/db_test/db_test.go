@@ -88,10 +88,92 @@ func TestDBMigrations(t *testing.T) {
 	err = database.Ping(ctx)
 	require.NoError(t, err)
 
+	status, err := db.GetMigrationStatus(ctx, connstr)
+	require.NoError(t, err)
+	require.Equal(t, uint(0), status.Version)
+	require.NotEmpty(t, status.Pending)
+
+	err = db.CheckSchema(ctx, connstr, false)
+	require.ErrorIs(t, err, db.ErrSchemaBehind)
+
+	err = db.MigrateUp(ctx, connstr)
+	require.NoError(t, err)
+
+	// Migrating when there is nothing to do is not an error
 	err = db.MigrateUp(ctx, connstr)
 	require.NoError(t, err)
 
+	status, err = db.GetMigrationStatus(ctx, connstr)
+	require.NoError(t, err)
+	require.Equal(t, status.Latest, status.Version)
+	require.Equal(t, db.RequiredVersion, status.Version)
+	require.False(t, status.Dirty)
+	require.Empty(t, status.Pending)
+
+	err = db.CheckSchema(ctx, connstr, false)
+	require.NoError(t, err)
+
+	err = db.MigrateSteps(ctx, connstr, -2)
+	require.NoError(t, err)
+	status, err = db.GetMigrationStatus(ctx, connstr)
+	require.NoError(t, err)
+	require.Len(t, status.Pending, 2)
+
+	// The schema check can bring the database up to date
+	err = db.CheckSchema(ctx, connstr, true)
+	require.NoError(t, err)
+	status, err = db.GetMigrationStatus(ctx, connstr)
+	require.NoError(t, err)
+	require.Empty(t, status.Pending)
+
+	// But a database newer than the application is never migrated
+	err = db.ForceVersion(ctx, connstr, int(db.RequiredVersion)+1)
+	require.NoError(t, err)
+	err = db.CheckSchema(ctx, connstr, true)
+	require.ErrorIs(t, err, db.ErrSchemaAhead)
+	err = db.ForceVersion(ctx, connstr, int(db.RequiredVersion))
+	require.NoError(t, err)
+
 	err = db.MigrateDown(ctx, connstr)
 	require.NoError(t, err)
 
 }
+
+func TestMigrationPlan(t *testing.T) {
+	t.Parallel()
+
+	all, err := db.Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+	require.Equal(t, uint(1), all[0].Version)
+	require.Equal(t, "initialize", all[0].Name)
+
+	latest, err := db.LatestVersion()
+	require.NoError(t, err)
+	require.Equal(t, all[len(all)-1].Version, latest)
+
+	up := db.PlanMigration(all, 3, 5)
+	require.Equal(t, []uint{4, 5}, versions(up))
+	down := db.PlanMigration(all, 5, 3)
+	require.Equal(t, []uint{5, 4}, versions(down))
+	require.Empty(t, db.PlanMigration(all, 5, 5))
+
+	target, err := db.StepTarget(all, 5, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint(7), target)
+	target, err = db.StepTarget(all, 2, -2)
+	require.NoError(t, err)
+	require.Equal(t, uint(0), target)
+	_, err = db.StepTarget(all, 1, -2)
+	require.Error(t, err)
+	_, err = db.StepTarget(all, latest, 1)
+	require.Error(t, err)
+}
+
+func versions(migrations []db.Migration) []uint {
+	var v []uint
+	for _, m := range migrations {
+		v = append(v, m.Version)
+	}
+	return v
+}
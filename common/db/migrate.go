@@ -3,6 +3,9 @@ package db
 import (
 	"context"
 	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres" //Driver for migrations
@@ -12,39 +15,206 @@ import (
 //go:embed migrations/*.sql
 var migrations embed.FS
 
-// MigrateUp brings the database to the current schema
-func MigrateUp(ctx context.Context, connstr string) error {
+// Migration is a single migration embedded in the application.
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// MigrationStatus describes the state of the database schema. A version of 0
+// means that no migrations have been applied. If the database is dirty, then a
+// migration failed partway through and must be fixed by hand, after which the
+// version can be forced.
+type MigrationStatus struct {
+	Version uint
+	Dirty   bool
+	Latest  uint
+	Pending []Migration
+}
+
+// newMigrate returns a migrate instance using the embedded migrations.
+func newMigrate(connstr string) (*migrate.Migrate, error) {
 	d, err := iofs.New(migrations, "migrations")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	m, err := migrate.NewWithSourceInstance("iofs", d, connstr)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = m.Up()
+	return m, nil
+}
+
+// run runs a function against a migrate instance, then closes the instance.
+// Being asked to make no change is not an error.
+func run(connstr string, fn func(m *migrate.Migrate) error) error {
+	m, err := newMigrate(connstr)
 	if err != nil {
 		return err
 	}
+	defer m.Close()
+
+	err = fn(m)
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
 
 	return nil
 }
 
+// MigrateUp brings the database to the current schema
+func MigrateUp(ctx context.Context, connstr string) error {
+	return run(connstr, func(m *migrate.Migrate) error {
+		return m.Up()
+	})
+}
+
 // MigrateDown resets the database back to a blank state. USE WITH
 // CAUTION: THis will result in data loss.
 func MigrateDown(ctx context.Context, connstr string) error {
-	d, err := iofs.New(migrations, "migrations")
+	return run(connstr, func(m *migrate.Migrate) error {
+		return m.Down()
+	})
+}
+
+// MigrateTo migrates the database up or down to a specific version. Version 0
+// reverts every migration.
+func MigrateTo(ctx context.Context, connstr string, version uint) error {
+	return run(connstr, func(m *migrate.Migrate) error {
+		if version == 0 {
+			return m.Down()
+		}
+		return m.Migrate(version)
+	})
+}
+
+// MigrateSteps applies n migrations if n is positive, or reverts n migrations
+// if n is negative.
+func MigrateSteps(ctx context.Context, connstr string, n int) error {
+	return run(connstr, func(m *migrate.Migrate) error {
+		return m.Steps(n)
+	})
+}
+
+// ForceVersion sets the version of the database and clears the dirty flag
+// without running any migrations. Use this only after fixing a failed migration
+// by hand.
+func ForceVersion(ctx context.Context, connstr string, version int) error {
+	return run(connstr, func(m *migrate.Migrate) error {
+		return m.Force(version)
+	})
+}
+
+// GetMigrationStatus reports the version of the database schema, whether it is
+// dirty, and which migrations have not yet been applied.
+func GetMigrationStatus(ctx context.Context, connstr string) (*MigrationStatus, error) {
+	all, err := Migrations()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	m, err := migrate.NewWithSourceInstance("iofs", d, connstr)
+
+	status := &MigrationStatus{}
+	err = run(connstr, func(m *migrate.Migrate) error {
+		version, dirty, err := m.Version()
+		if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+			return err
+		}
+		status.Version = version
+		status.Dirty = dirty
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if len(all) > 0 {
+		status.Latest = all[len(all)-1].Version
 	}
-	err = m.Down()
+	status.Pending = PlanMigration(all, status.Version, status.Latest)
+
+	return status, nil
+}
+
+// Migrations lists the migrations embedded in the application, in order.
+func Migrations() ([]Migration, error) {
+	d, err := iofs.New(migrations, "migrations")
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer d.Close()
 
-	return nil
+	var list []Migration
+	version, err := d.First()
+	for err == nil {
+		r, name, errRead := d.ReadUp(version)
+		if errRead != nil {
+			return nil, errRead
+		}
+		r.Close()
+		list = append(list, Migration{Version: version, Name: name})
+		version, err = d.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// LatestVersion is the version of the newest migration embedded in the
+// application, which is the schema version the application expects.
+func LatestVersion() (uint, error) {
+	all, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(all) == 0 {
+		return 0, errors.New("There are no migrations embedded in the application")
+	}
+	return all[len(all)-1].Version, nil
+}
+
+// PlanMigration lists the migrations that would be run to move from the current
+// version to the target version. Migrations going up are listed in the order
+// they would be applied; migrations going down are listed in the order they
+// would be reverted.
+func PlanMigration(all []Migration, current, target uint) []Migration {
+	var plan []Migration
+	if target >= current {
+		for _, m := range all {
+			if m.Version > current && m.Version <= target {
+				plan = append(plan, m)
+			}
+		}
+		return plan
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].Version <= current && all[i].Version > target {
+			plan = append(plan, all[i])
+		}
+	}
+	return plan
+}
+
+// StepTarget returns the version that the database would be at after moving n
+// migrations up (if positive) or down (if negative) from the current version.
+func StepTarget(all []Migration, current uint, n int) (uint, error) {
+	pos := -1 // Index of the current version, or -1 if no migrations are applied
+	for i, m := range all {
+		if m.Version == current {
+			pos = i
+		}
+	}
+	if current != 0 && pos == -1 {
+		return 0, fmt.Errorf("The database is at version %d, which is not a known migration", current)
+	}
+
+	next := pos + n
+	if next < -1 || next >= len(all) {
+		return 0, fmt.Errorf("Cannot move %d steps from version %d: there are only %d migrations", n, current, len(all))
+	}
+	if next == -1 {
+		return 0, nil
+	}
+	return all[next].Version, nil
 }
@@ -0,0 +1,17 @@
+-- Create a table to hold runs of contiguous sentences in the same language
+CREATE TABLE IF NOT EXISTS results.language_spans (
+  job_id uuid REFERENCES jobs.fulltext (id) NOT NULL,
+  item_id text REFERENCES items (id) NOT NULL,
+  page integer NOT NULL,
+  start_char integer NOT NULL,
+  end_char integer NOT NULL,
+  sentences integer NOT NULL,
+  lang text NOT NULL,
+  confidence real
+);
+
+CREATE INDEX IF NOT EXISTS language_spans_job_id_idx ON results.language_spans (job_id);
+
+CREATE INDEX IF NOT EXISTS language_spans_item_id_idx ON results.language_spans (item_id);
+
+CREATE INDEX IF NOT EXISTS language_spans_lang_idx ON results.language_spans (lang);
@@ -0,0 +1,20 @@
+-- Create a table to hold the item-level classification derived from the
+-- language statistics
+CREATE TABLE IF NOT EXISTS results.language_classification (
+  job_id uuid REFERENCES jobs.fulltext (id) NOT NULL,
+  item_id text REFERENCES items (id) NOT NULL,
+  primary_lang text,
+  secondary_langs text[],
+  multilingual boolean NOT NULL,
+  diversity real NOT NULL,
+  sentences integer NOT NULL,
+  catalog_agreement text
+);
+
+CREATE INDEX IF NOT EXISTS language_classification_job_id_idx ON results.language_classification (job_id);
+
+CREATE INDEX IF NOT EXISTS language_classification_item_id_idx ON results.language_classification (item_id);
+
+CREATE INDEX IF NOT EXISTS language_classification_multilingual_idx ON results.language_classification (multilingual);
+
+CREATE INDEX IF NOT EXISTS language_classification_agreement_idx ON results.language_classification (catalog_agreement);
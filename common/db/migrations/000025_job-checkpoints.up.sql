@@ -0,0 +1,7 @@
+-- Record progress on long-running jobs so that they can be resumed
+CREATE TABLE IF NOT EXISTS jobs.checkpoints (
+  job_id uuid PRIMARY KEY REFERENCES jobs.fulltext (id) ON DELETE CASCADE,
+  progress integer NOT NULL,
+  state jsonb,
+  updated timestamp with time zone NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,33 @@
+-- Enum values cannot be dropped, so recreate the type without cancelled
+UPDATE jobs.fulltext SET status = 'skipped' WHERE status = 'cancelled';
+
+DROP VIEW IF EXISTS stats.job_status_ft;
+
+ALTER TYPE job_statuses RENAME TO job_statuses_old;
+
+CREATE TYPE job_statuses AS ENUM (
+  'ready',
+  'running',
+  'skipped',
+  'failed',
+  'finished'
+);
+
+ALTER TABLE jobs.fulltext
+  ALTER COLUMN status TYPE job_statuses USING status::text::job_statuses;
+
+DROP TYPE job_statuses_old;
+
+CREATE VIEW stats.job_status_ft AS
+SELECT
+  destination,
+  status,
+  COUNT(*) AS num_jobs
+FROM
+  jobs.fulltext
+GROUP BY
+  destination,
+  status
+ORDER BY
+  destination,
+  status;
@@ -0,0 +1,2 @@
+-- Allow jobs to be cancelled, so that they are not run but are not recreated
+ALTER TYPE job_statuses ADD VALUE IF NOT EXISTS 'cancelled';
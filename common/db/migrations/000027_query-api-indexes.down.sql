@@ -0,0 +1,7 @@
+-- Leave the indexes on job_id and item_id, which migration 22 was meant to
+-- create
+DROP INDEX IF EXISTS results.quotations_verse_id_idx;
+DROP INDEX IF EXISTS results.quotations_reference_id_idx;
+DROP INDEX IF EXISTS results.quotations_probability_idx;
+DROP INDEX IF EXISTS results.languages_lang_idx;
+DROP INDEX IF EXISTS items_in_collections_collection_id_idx;
@@ -0,0 +1,11 @@
+-- Indexes for the queries made by the API. Migration 22 was meant to create
+-- the indexes on item_id, so create them here if they don't exist.
+CREATE INDEX IF NOT EXISTS quotations_job_id_idx ON results.biblical_quotations (job_id);
+CREATE INDEX IF NOT EXISTS quotations_item_id_idx ON results.biblical_quotations (item_id);
+CREATE INDEX IF NOT EXISTS quotations_verse_id_idx ON results.biblical_quotations (verse_id);
+CREATE INDEX IF NOT EXISTS quotations_reference_id_idx ON results.biblical_quotations (reference_id);
+CREATE INDEX IF NOT EXISTS quotations_probability_idx ON results.biblical_quotations (probability DESC);
+CREATE INDEX IF NOT EXISTS languages_job_id_idx ON results.languages (job_id);
+CREATE INDEX IF NOT EXISTS languages_item_id_idx ON results.languages (item_id);
+CREATE INDEX IF NOT EXISTS languages_lang_idx ON results.languages (lang);
+CREATE INDEX IF NOT EXISTS items_in_collections_collection_id_idx ON items_in_collections (collection_id);
@@ -0,0 +1,6 @@
+DROP VIEW IF EXISTS results.quotation_rates_by_decade;
+DROP VIEW IF EXISTS results.quotation_rates;
+DROP TABLE IF EXISTS results.quotation_items_counted;
+DROP TABLE IF EXISTS results.quotation_counts;
+DROP TABLE IF EXISTS results.quotation_counted_jobs;
+DROP TABLE IF EXISTS results.quotation_thresholds;
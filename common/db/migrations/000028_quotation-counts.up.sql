@@ -0,0 +1,93 @@
+-- Aggregate the quotations by verse, year, and collection, so that questions
+-- like how often a verse was quoted per decade don't require scanning every
+-- quotation. The counts are kept for each probability threshold in
+-- results.quotation_thresholds, and the collection 'all' counts each item once
+-- regardless of its collections. Items without a year are not counted.
+CREATE TABLE IF NOT EXISTS results.quotation_thresholds (
+  threshold real PRIMARY KEY
+);
+-- Every recorded quotation is above the threshold used by the model
+INSERT INTO results.quotation_thresholds (threshold) VALUES (0.57);
+-- The jobs which have been counted at each threshold, so that the counts can
+-- be refreshed incrementally as new jobs finish
+CREATE TABLE IF NOT EXISTS results.quotation_counted_jobs (
+  job_id uuid REFERENCES jobs.fulltext (id) NOT NULL,
+  threshold real REFERENCES results.quotation_thresholds (threshold) ON DELETE CASCADE NOT NULL,
+  counted timestamp with time zone NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (job_id, threshold)
+);
+-- The number of items and pages quoting each verse
+CREATE TABLE IF NOT EXISTS results.quotation_counts (
+  threshold real REFERENCES results.quotation_thresholds (threshold) ON DELETE CASCADE NOT NULL,
+  reference_id text NOT NULL,
+  year integer NOT NULL,
+  collection_id text NOT NULL,
+  items integer NOT NULL,
+  pages integer NOT NULL,
+  PRIMARY KEY (threshold, reference_id, year, collection_id)
+);
+CREATE INDEX IF NOT EXISTS quotation_counts_reference_id_idx ON results.quotation_counts (reference_id);
+-- The number of items which have been checked for quotations, which is the
+-- denominator for normalizing the counts
+CREATE TABLE IF NOT EXISTS results.quotation_items_counted (
+  threshold real REFERENCES results.quotation_thresholds (threshold) ON DELETE CASCADE NOT NULL,
+  year integer NOT NULL,
+  collection_id text NOT NULL,
+  items integer NOT NULL,
+  PRIMARY KEY (threshold, year, collection_id)
+);
+CREATE VIEW results.quotation_rates AS
+SELECT
+  c.threshold,
+  c.reference_id,
+  c.year,
+  c.collection_id,
+  c.items,
+  c.pages,
+  n.items AS items_counted,
+  c.items::double precision / n.items AS items_share
+FROM
+  results.quotation_counts c
+  JOIN results.quotation_items_counted n USING (threshold, year, collection_id);
+CREATE VIEW results.quotation_rates_by_decade AS
+WITH counts AS (
+  SELECT
+    threshold,
+    reference_id,
+    year / 10 * 10 AS decade,
+    collection_id,
+    SUM(items) AS items,
+    SUM(pages) AS pages
+  FROM
+    results.quotation_counts
+  GROUP BY
+    threshold,
+    reference_id,
+    year / 10 * 10,
+    collection_id
+),
+counted AS (
+  SELECT
+    threshold,
+    year / 10 * 10 AS decade,
+    collection_id,
+    SUM(items) AS items
+  FROM
+    results.quotation_items_counted
+  GROUP BY
+    threshold,
+    year / 10 * 10,
+    collection_id
+)
+SELECT
+  c.threshold,
+  c.reference_id,
+  c.decade,
+  c.collection_id,
+  c.items,
+  c.pages,
+  n.items AS items_counted,
+  c.items::double precision / n.items AS items_share
+FROM
+  counts c
+  JOIN counted n USING (threshold, decade, collection_id);
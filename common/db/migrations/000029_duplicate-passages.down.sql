@@ -0,0 +1,6 @@
+DROP VIEW IF EXISTS results.quotation_reprints;
+DROP VIEW IF EXISTS results.biblical_quotations_deduplicated;
+DROP TABLE IF EXISTS results.item_clusters;
+DROP TABLE IF EXISTS results.duplicate_passages;
+DROP TABLE IF EXISTS results.passage_bands;
+DROP TABLE IF EXISTS results.passage_signatures;
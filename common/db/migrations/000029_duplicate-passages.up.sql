@@ -0,0 +1,77 @@
+-- Detect passages which are reprinted across items, such as a sermon reprinted
+-- in many newspapers. Each passage on a page has a MinHash signature, which is
+-- split into bands for locality-sensitive hashing: passages which share a band
+-- are candidates for being near-duplicates.
+CREATE TABLE IF NOT EXISTS results.passage_signatures (
+  job_id uuid REFERENCES jobs.fulltext (id) NOT NULL,
+  item_id text REFERENCES items (id) NOT NULL,
+  page integer NOT NULL,
+  passage integer NOT NULL,
+  start_char integer NOT NULL,
+  end_char integer NOT NULL,
+  minhash bigint[] NOT NULL,
+  PRIMARY KEY (job_id, page, passage)
+);
+CREATE INDEX IF NOT EXISTS passage_signatures_item_id_idx ON results.passage_signatures (item_id);
+CREATE TABLE IF NOT EXISTS results.passage_bands (
+  job_id uuid REFERENCES jobs.fulltext (id) NOT NULL,
+  page integer NOT NULL,
+  passage integer NOT NULL,
+  band smallint NOT NULL,
+  hash bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS passage_bands_hash_idx ON results.passage_bands (band, hash);
+CREATE INDEX IF NOT EXISTS passage_bands_job_id_idx ON results.passage_bands (job_id);
+-- Pairs of passages which are near-duplicates. The job is the one which found
+-- the pair, for the first item.
+CREATE TABLE IF NOT EXISTS results.duplicate_passages (
+  job_id uuid REFERENCES jobs.fulltext (id) NOT NULL,
+  item_id text REFERENCES items (id) NOT NULL,
+  page integer NOT NULL,
+  passage integer NOT NULL,
+  other_item_id text REFERENCES items (id) NOT NULL,
+  other_page integer NOT NULL,
+  other_passage integer NOT NULL,
+  similarity real NOT NULL
+);
+CREATE INDEX IF NOT EXISTS duplicate_passages_job_id_idx ON results.duplicate_passages (job_id);
+CREATE INDEX IF NOT EXISTS duplicate_passages_item_id_idx ON results.duplicate_passages (item_id);
+CREATE INDEX IF NOT EXISTS duplicate_passages_other_item_id_idx ON results.duplicate_passages (other_item_id);
+-- Items which share reprinted text are in the same cluster. The ID of a cluster
+-- is the ID of one of its items.
+CREATE TABLE IF NOT EXISTS results.item_clusters (
+  item_id text PRIMARY KEY REFERENCES items (id),
+  cluster_id text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS item_clusters_cluster_id_idx ON results.item_clusters (cluster_id);
+-- Quotations with reprints removed: only the most probable quotation of a verse
+-- in each cluster is kept. Items which have not been clustered are their own
+-- cluster.
+CREATE VIEW results.biblical_quotations_deduplicated AS
+SELECT DISTINCT ON (COALESCE(c.cluster_id, q.item_id), q.reference_id)
+  q.job_id,
+  q.item_id,
+  q.reference_id,
+  q.verse_id,
+  q.probability,
+  COALESCE(c.cluster_id, q.item_id) AS cluster_id
+FROM
+  results.biblical_quotations q
+  LEFT JOIN results.item_clusters c ON c.item_id = q.item_id
+ORDER BY
+  COALESCE(c.cluster_id, q.item_id),
+  q.reference_id,
+  q.probability DESC,
+  q.item_id;
+-- The number of items quoting each verse, both raw and counting each cluster of
+-- reprints once
+CREATE VIEW results.quotation_reprints AS
+SELECT
+  q.reference_id,
+  COUNT(DISTINCT q.item_id) AS items,
+  COUNT(DISTINCT COALESCE(c.cluster_id, q.item_id)) AS clusters
+FROM
+  results.biblical_quotations q
+  LEFT JOIN results.item_clusters c ON c.item_id = q.item_id
+GROUP BY
+  q.reference_id;
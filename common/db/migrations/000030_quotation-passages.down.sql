@@ -0,0 +1,23 @@
+DROP VIEW IF EXISTS results.biblical_quotations_deduplicated;
+CREATE VIEW results.biblical_quotations_deduplicated AS
+SELECT DISTINCT ON (COALESCE(c.cluster_id, q.item_id), q.reference_id)
+  q.job_id,
+  q.item_id,
+  q.reference_id,
+  q.verse_id,
+  q.probability,
+  COALESCE(c.cluster_id, q.item_id) AS cluster_id
+FROM
+  results.biblical_quotations q
+  LEFT JOIN results.item_clusters c ON c.item_id = q.item_id
+ORDER BY
+  COALESCE(c.cluster_id, q.item_id),
+  q.reference_id,
+  q.probability DESC,
+  q.item_id;
+ALTER TABLE results.biblical_quotations
+  DROP COLUMN IF EXISTS page,
+  DROP COLUMN IF EXISTS start_char,
+  DROP COLUMN IF EXISTS end_char,
+  DROP COLUMN IF EXISTS context,
+  DROP COLUMN IF EXISTS context_start;
@@ -0,0 +1,32 @@
+-- The passage matching each quotation, so that quotations can be reviewed and
+-- exported without reopening the full text. Offsets are counted in characters
+-- from the start of the page, and the context is the passage along with the
+-- text around it, starting at context_start. Quotations found before the
+-- passages were recorded have nulls.
+ALTER TABLE results.biblical_quotations
+  ADD COLUMN IF NOT EXISTS page integer,
+  ADD COLUMN IF NOT EXISTS start_char integer,
+  ADD COLUMN IF NOT EXISTS end_char integer,
+  ADD COLUMN IF NOT EXISTS context text,
+  ADD COLUMN IF NOT EXISTS context_start integer;
+CREATE OR REPLACE VIEW results.biblical_quotations_deduplicated AS
+SELECT DISTINCT ON (COALESCE(c.cluster_id, q.item_id), q.reference_id)
+  q.job_id,
+  q.item_id,
+  q.reference_id,
+  q.verse_id,
+  q.probability,
+  COALESCE(c.cluster_id, q.item_id) AS cluster_id,
+  q.page,
+  q.start_char,
+  q.end_char,
+  q.context,
+  q.context_start
+FROM
+  results.biblical_quotations q
+  LEFT JOIN results.item_clusters c ON c.item_id = q.item_id
+ORDER BY
+  COALESCE(c.cluster_id, q.item_id),
+  q.reference_id,
+  q.probability DESC,
+  q.item_id;
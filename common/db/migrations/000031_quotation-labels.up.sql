@@ -0,0 +1,20 @@
+-- Labels given by reviewers to predicted quotations. Labels are tied to the
+-- item and verse rather than to a job, so that they are kept when jobs are
+-- rerun, and they record the probability and passage that the reviewer saw.
+CREATE TABLE IF NOT EXISTS results.quotation_labels (
+  item_id text REFERENCES items (id) NOT NULL,
+  reference_id text NOT NULL,
+  verse_id text NOT NULL,
+  reviewer text NOT NULL,
+  label text NOT NULL CHECK (label IN ('quotation', 'false_positive', 'allusion')),
+  note text,
+  probability real NOT NULL,
+  page integer,
+  start_char integer,
+  end_char integer,
+  context text,
+  context_start integer,
+  reviewed timestamp with time zone NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (item_id, verse_id, reviewer)
+);
+CREATE INDEX IF NOT EXISTS quotation_labels_probability_idx ON results.quotation_labels (probability);
@@ -0,0 +1,26 @@
+DROP VIEW IF EXISTS results.biblical_quotations_deduplicated;
+CREATE VIEW results.biblical_quotations_deduplicated AS
+SELECT DISTINCT ON (COALESCE(c.cluster_id, q.item_id), q.reference_id)
+  q.job_id,
+  q.item_id,
+  q.reference_id,
+  q.verse_id,
+  q.probability,
+  COALESCE(c.cluster_id, q.item_id) AS cluster_id,
+  q.page,
+  q.start_char,
+  q.end_char,
+  q.context,
+  q.context_start
+FROM
+  results.biblical_quotations q
+  LEFT JOIN results.item_clusters c ON c.item_id = q.item_id
+ORDER BY
+  COALESCE(c.cluster_id, q.item_id),
+  q.reference_id,
+  q.probability DESC,
+  q.item_id;
+DROP VIEW IF EXISTS results.biblical_quotations_canonical;
+DROP TABLE IF EXISTS results.versification;
+DROP INDEX IF EXISTS results.quotations_translation_idx;
+ALTER TABLE results.biblical_quotations DROP COLUMN IF EXISTS translation;
@@ -0,0 +1,50 @@
+-- Quotations can be found in more than one reference text, such as the KJV,
+-- the Douay-Rheims, or the Luther Bible, so record which one matched. All the
+-- quotations found so far were found in the KJV payload.
+ALTER TABLE results.biblical_quotations
+  ADD COLUMN IF NOT EXISTS translation text NOT NULL DEFAULT 'kjv';
+ALTER TABLE results.biblical_quotations
+  ALTER COLUMN translation DROP DEFAULT;
+CREATE INDEX IF NOT EXISTS quotations_translation_idx ON results.biblical_quotations (translation);
+-- Translations number some verses differently (e.g., the Psalms in the
+-- Douay-Rheims) and name the books in their own languages. The mapping gives
+-- the canonical reference for a verse in a translation, so that quotations can
+-- be aggregated across translations. A verse which is not in the mapping is its
+-- own canonical reference.
+CREATE TABLE IF NOT EXISTS results.versification (
+  translation text NOT NULL,
+  reference_id text NOT NULL,
+  canonical_reference_id text NOT NULL,
+  PRIMARY KEY (translation, reference_id, canonical_reference_id)
+);
+CREATE OR REPLACE VIEW results.biblical_quotations_canonical AS
+SELECT
+  q.*,
+  COALESCE(v.canonical_reference_id, q.reference_id) AS canonical_reference_id
+FROM
+  results.biblical_quotations q
+  LEFT JOIN results.versification v ON v.translation = q.translation
+    AND v.reference_id = q.reference_id;
+CREATE OR REPLACE VIEW results.biblical_quotations_deduplicated AS
+SELECT DISTINCT ON (COALESCE(c.cluster_id, q.item_id), q.canonical_reference_id)
+  q.job_id,
+  q.item_id,
+  q.reference_id,
+  q.verse_id,
+  q.probability,
+  COALESCE(c.cluster_id, q.item_id) AS cluster_id,
+  q.page,
+  q.start_char,
+  q.end_char,
+  q.context,
+  q.context_start,
+  q.translation,
+  q.canonical_reference_id
+FROM
+  results.biblical_quotations_canonical q
+  LEFT JOIN results.item_clusters c ON c.item_id = q.item_id
+ORDER BY
+  COALESCE(c.cluster_id, q.item_id),
+  q.canonical_reference_id,
+  q.probability DESC,
+  q.item_id;
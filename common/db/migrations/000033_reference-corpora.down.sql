@@ -0,0 +1,4 @@
+DROP VIEW IF EXISTS results.all_quotations;
+DROP TABLE IF EXISTS results.quotations;
+DROP TABLE IF EXISTS reference_passages;
+DROP TABLE IF EXISTS reference_corpora;
@@ -0,0 +1,43 @@
+-- Reference corpora are collections of passages, such as the Constitution or a
+-- hymnal, in which the quotation detector can look for quotations. Each passage
+-- has an ID which is unique within its corpus, such as "Article I, Section 8".
+CREATE TABLE IF NOT EXISTS reference_corpora (
+  id text PRIMARY KEY,
+  title text,
+  description text,
+  updated timestamp with time zone NOT NULL DEFAULT NOW()
+);
+CREATE TABLE IF NOT EXISTS reference_passages (
+  corpus_id text REFERENCES reference_corpora (id) ON DELETE CASCADE NOT NULL,
+  passage_id text NOT NULL,
+  text text NOT NULL,
+  PRIMARY KEY (corpus_id, passage_id)
+);
+-- Quotations of the passages in a reference corpus. Biblical quotations are
+-- still kept in results.biblical_quotations.
+CREATE TABLE IF NOT EXISTS results.quotations (
+  job_id uuid REFERENCES jobs.fulltext (id) NOT NULL,
+  item_id text REFERENCES items (id) NOT NULL,
+  corpus_id text NOT NULL,
+  passage_id text NOT NULL,
+  probability real NOT NULL,
+  page integer,
+  start_char integer,
+  end_char integer,
+  context text,
+  context_start integer,
+  FOREIGN KEY (corpus_id, passage_id) REFERENCES reference_passages (corpus_id, passage_id)
+);
+CREATE INDEX IF NOT EXISTS corpus_quotations_job_id_idx ON results.quotations (job_id);
+CREATE INDEX IF NOT EXISTS corpus_quotations_item_id_idx ON results.quotations (item_id);
+CREATE INDEX IF NOT EXISTS corpus_quotations_passage_idx ON results.quotations (corpus_id, passage_id);
+-- Quotations from every corpus, with the Bible as the corpus 'bible' and the
+-- canonical reference as the passage
+CREATE OR REPLACE VIEW results.all_quotations AS
+SELECT job_id, item_id, 'bible' AS corpus_id, canonical_reference_id AS passage_id, probability,
+  page, start_char, end_char, context, context_start
+FROM results.biblical_quotations_canonical
+UNION ALL
+SELECT job_id, item_id, corpus_id, passage_id, probability,
+  page, start_char, end_char, context, context_start
+FROM results.quotations;
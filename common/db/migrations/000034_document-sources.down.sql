@@ -0,0 +1,32 @@
+-- Remove the jobs for books from the Stacks and their results, so that the
+-- foreign keys to the items table can be restored. Every table with a foreign
+-- key to the jobs has to be cleared first. The counts at any threshold which
+-- counted a book from the Stacks are removed, so that the next aggregation
+-- recounts that threshold without them.
+DELETE FROM results.quotation_counts WHERE threshold IN (SELECT c.threshold FROM results.quotation_counted_jobs c JOIN jobs.fulltext j ON j.id = c.job_id WHERE j.source <> 'items');
+DELETE FROM results.quotation_items_counted WHERE threshold IN (SELECT c.threshold FROM results.quotation_counted_jobs c JOIN jobs.fulltext j ON j.id = c.job_id WHERE j.source <> 'items');
+DELETE FROM results.quotation_counted_jobs WHERE threshold IN (SELECT c.threshold FROM results.quotation_counted_jobs c JOIN jobs.fulltext j ON j.id = c.job_id WHERE j.source <> 'items');
+DELETE FROM results.biblical_quotations WHERE job_id IN (SELECT id FROM jobs.fulltext WHERE source <> 'items');
+DELETE FROM results.quotations WHERE job_id IN (SELECT id FROM jobs.fulltext WHERE source <> 'items');
+DELETE FROM results.languages WHERE job_id IN (SELECT id FROM jobs.fulltext WHERE source <> 'items');
+DELETE FROM results.language_spans WHERE job_id IN (SELECT id FROM jobs.fulltext WHERE source <> 'items');
+DELETE FROM results.language_classification WHERE job_id IN (SELECT id FROM jobs.fulltext WHERE source <> 'items');
+DELETE FROM results.passage_bands WHERE job_id IN (SELECT id FROM jobs.fulltext WHERE source <> 'items');
+DELETE FROM results.passage_signatures WHERE job_id IN (SELECT id FROM jobs.fulltext WHERE source <> 'items');
+DELETE FROM results.duplicate_passages WHERE job_id IN (SELECT id FROM jobs.fulltext WHERE source <> 'items');
+DELETE FROM results.quotation_labels l WHERE NOT EXISTS (SELECT 1 FROM items i WHERE i.id = l.item_id);
+DELETE FROM jobs.checkpoints WHERE job_id IN (SELECT id FROM jobs.fulltext WHERE source <> 'items');
+DELETE FROM jobs.fulltext WHERE source <> 'items';
+
+ALTER TABLE results.language_classification ADD CONSTRAINT language_classification_item_id_fkey FOREIGN KEY (item_id) REFERENCES items (id);
+ALTER TABLE results.language_spans ADD CONSTRAINT language_spans_item_id_fkey FOREIGN KEY (item_id) REFERENCES items (id);
+ALTER TABLE results.languages ADD CONSTRAINT languages_item_id_fkey FOREIGN KEY (item_id) REFERENCES items (id);
+ALTER TABLE results.quotation_labels ADD CONSTRAINT quotation_labels_item_id_fkey FOREIGN KEY (item_id) REFERENCES items (id);
+ALTER TABLE results.quotations ADD CONSTRAINT quotations_item_id_fkey FOREIGN KEY (item_id) REFERENCES items (id);
+ALTER TABLE results.biblical_quotations ADD CONSTRAINT biblical_quotations_item_id_fkey FOREIGN KEY (item_id) REFERENCES items (id);
+ALTER TABLE jobs.fulltext ADD CONSTRAINT fulltext_item_id_fkey FOREIGN KEY (item_id) REFERENCES items (id);
+
+ALTER TABLE jobs.fulltext DROP CONSTRAINT IF EXISTS jobs_fulltext_unique;
+ALTER TABLE jobs.fulltext ADD CONSTRAINT jobs_fulltext_unique UNIQUE (item_id, destination);
+ALTER TABLE jobs.fulltext DROP CONSTRAINT IF EXISTS jobs_fulltext_source_check;
+ALTER TABLE jobs.fulltext DROP COLUMN IF EXISTS source;
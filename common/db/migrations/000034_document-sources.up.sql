@@ -0,0 +1,35 @@
+-- Jobs can process documents from more than one source: the loc.gov items in
+-- the items table, or the books imported from the Stacks in stacks_books. The
+-- item_id of a job and of its results is the ID of the document in its source,
+-- so it can no longer refer to the items table.
+ALTER TABLE jobs.fulltext ADD COLUMN IF NOT EXISTS source text NOT NULL DEFAULT 'items';
+ALTER TABLE jobs.fulltext ADD CONSTRAINT jobs_fulltext_source_check CHECK (source IN ('items', 'stacks'));
+ALTER TABLE jobs.fulltext DROP CONSTRAINT IF EXISTS jobs_fulltext_unique;
+ALTER TABLE jobs.fulltext ADD CONSTRAINT jobs_fulltext_unique UNIQUE (source, item_id, destination);
+
+-- Drop the foreign keys to the items table from the jobs and from the results
+-- of the destinations which can process books from the Stacks. The names of the
+-- constraints depend on when the tables were created or renamed, so look them up.
+DO $$
+DECLARE
+  r record;
+BEGIN
+  FOR r IN
+    SELECT c.conrelid::regclass AS tbl, c.conname
+    FROM pg_constraint c
+    JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY (c.conkey)
+    WHERE c.contype = 'f'
+      AND c.confrelid = 'public.items'::regclass
+      AND a.attname = 'item_id'
+      AND c.conrelid IN (
+        'jobs.fulltext'::regclass,
+        'results.biblical_quotations'::regclass,
+        'results.quotations'::regclass,
+        'results.quotation_labels'::regclass,
+        'results.languages'::regclass,
+        'results.language_spans'::regclass,
+        'results.language_classification'::regclass)
+  LOOP
+    EXECUTE format('ALTER TABLE %s DROP CONSTRAINT %I', r.tbl, r.conname);
+  END LOOP;
+END $$;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS stacks_import_files;
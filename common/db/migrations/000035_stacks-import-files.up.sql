@@ -0,0 +1,19 @@
+-- Checkpoints for importing files from the Stacks export, so that an import
+-- which is interrupted can resume each file where it left off. A file is
+-- identified by its path, and its size and modification time are recorded so
+-- that a file which has changed is imported from the start. The counts are the
+-- outcomes of the records read so far, for the validation report.
+CREATE TABLE IF NOT EXISTS stacks_import_files (
+  path text PRIMARY KEY,
+  size bigint NOT NULL,
+  modified timestamp with time zone NOT NULL,
+  records integer NOT NULL DEFAULT 0,
+  imported integer NOT NULL DEFAULT 0,
+  skipped integer NOT NULL DEFAULT 0,
+  duplicates integer NOT NULL DEFAULT 0,
+  invalid integer NOT NULL DEFAULT 0,
+  bad_dates integer NOT NULL DEFAULT 0,
+  missing_text integer NOT NULL DEFAULT 0,
+  finished boolean NOT NULL DEFAULT false,
+  updated timestamp with time zone NOT NULL DEFAULT NOW()
+);
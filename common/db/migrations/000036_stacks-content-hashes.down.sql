@@ -0,0 +1,4 @@
+ALTER TABLE stacks_import_files DROP COLUMN IF EXISTS text_changed;
+ALTER TABLE stacks_import_files DROP COLUMN IF EXISTS changed;
+ALTER TABLE stacks_books DROP COLUMN IF EXISTS text_hash;
+ALTER TABLE stacks_books DROP COLUMN IF EXISTS content_hash;
@@ -0,0 +1,16 @@
+-- Hashes of the books imported from the Stacks, so that an updated export can
+-- be compared with the books already in the database. The content hash covers
+-- the metadata and the text of a book, and the text hash covers only its text,
+-- so that the jobs and results for a book are only thrown away when its text
+-- changes. The content hashes are computed by the importer, so books imported
+-- earlier have none and are updated the next time they are imported with
+-- --update. The text hashes can be filled in now.
+ALTER TABLE stacks_books ADD COLUMN IF NOT EXISTS content_hash text;
+ALTER TABLE stacks_books ADD COLUMN IF NOT EXISTS text_hash text;
+UPDATE stacks_books SET text_hash = encode(sha256(convert_to(text, 'UTF8')), 'hex')
+WHERE text IS NOT NULL;
+
+-- The number of books in each file which were changed, and which had their text
+-- changed, when the file was imported with --update.
+ALTER TABLE stacks_import_files ADD COLUMN IF NOT EXISTS changed integer NOT NULL DEFAULT 0;
+ALTER TABLE stacks_import_files ADD COLUMN IF NOT EXISTS text_changed integer NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+-- Books from the Stacks can't be left out of the counts one by one, so remove
+-- the counts at any threshold which counted them. The next aggregation recounts
+-- those thresholds.
+DELETE FROM results.quotation_counts WHERE threshold IN (SELECT c.threshold FROM results.quotation_counted_jobs c JOIN jobs.fulltext j ON j.id = c.job_id WHERE j.source <> 'items');
+DELETE FROM results.quotation_items_counted WHERE threshold IN (SELECT c.threshold FROM results.quotation_counted_jobs c JOIN jobs.fulltext j ON j.id = c.job_id WHERE j.source <> 'items');
+DELETE FROM results.quotation_counted_jobs WHERE threshold IN (SELECT c.threshold FROM results.quotation_counted_jobs c JOIN jobs.fulltext j ON j.id = c.job_id WHERE j.source <> 'items');
+DROP VIEW IF EXISTS documents;
@@ -0,0 +1,11 @@
+-- The year of each document a job can process, whether it is a loc.gov item or
+-- a book from the Stacks, so that results can be dated whatever their source.
+-- Join a result to it on the source of its job and its item_id.
+CREATE VIEW documents AS
+SELECT 'items' AS source, id, year FROM items
+UNION ALL
+SELECT 'stacks' AS source, lccn AS id, year FROM stacks_books;
+-- Jobs for books from the Stacks were marked as counted but were left out of the
+-- quotation counts, so count them at the next refresh
+DELETE FROM results.quotation_counted_jobs c USING jobs.fulltext j
+WHERE j.id = c.job_id AND j.source <> 'items';
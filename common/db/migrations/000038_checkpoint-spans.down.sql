@@ -0,0 +1,4 @@
+-- Checkpoints whose spans were written out can't be resumed without them, so
+-- start those jobs over
+DELETE FROM jobs.checkpoints WHERE job_id IN (SELECT job_id FROM jobs.checkpoint_spans);
+DROP TABLE IF EXISTS jobs.checkpoint_spans;
@@ -0,0 +1,16 @@
+-- The language spans of a job which has not finished yet. They are written as
+-- the job is checkpointed, so that a long item's spans are not all kept in
+-- memory and in its checkpoint, and they are moved to results.language_spans
+-- when the job finishes. They are deleted along with the checkpoint.
+CREATE TABLE IF NOT EXISTS jobs.checkpoint_spans (
+  job_id uuid NOT NULL REFERENCES jobs.checkpoints (job_id) ON DELETE CASCADE,
+  item_id text NOT NULL,
+  page integer NOT NULL,
+  start_char integer NOT NULL,
+  end_char integer NOT NULL,
+  sentences integer NOT NULL,
+  lang text NOT NULL,
+  confidence real
+);
+
+CREATE INDEX IF NOT EXISTS checkpoint_spans_job_id_idx ON jobs.checkpoint_spans (job_id);
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// RequiredVersion is the schema version that this build of the application
+// requires. It is the version of the newest migration embedded at build time.
+var RequiredVersion = requiredVersion()
+
+// Errors returned when the database schema does not match the application.
+var (
+	ErrSchemaBehind = errors.New("The database schema is older than this application requires")
+	ErrSchemaAhead  = errors.New("The database schema is newer than this application supports")
+	ErrSchemaDirty  = errors.New("The database schema is dirty because a migration failed")
+)
+
+func requiredVersion() uint {
+	v, err := LatestVersion()
+	if err != nil {
+		panic(fmt.Sprintf("Cannot read the embedded migrations: %s", err))
+	}
+	return v
+}
+
+// CheckSchema checks that the database schema is at the version this build of
+// the application requires. If the database is behind and autoMigrate is true,
+// then the pending migrations are applied; otherwise an error wrapping
+// ErrSchemaBehind is returned. A database which is ahead of the application or
+// dirty is never migrated automatically.
+func CheckSchema(ctx context.Context, connstr string, autoMigrate bool) error {
+	status, err := GetMigrationStatus(ctx, connstr)
+	if err != nil {
+		return fmt.Errorf("Error checking the database schema version: %w", err)
+	}
+
+	switch {
+	case status.Dirty:
+		return fmt.Errorf("%w: fix migration %d by hand, then run `cchc-ctrl migrate force`", ErrSchemaDirty, status.Version)
+	case status.Version > RequiredVersion:
+		return fmt.Errorf("%w: the database is at version %d but this application expects version %d; upgrade the application",
+			ErrSchemaAhead, status.Version, RequiredVersion)
+	case status.Version < RequiredVersion && !autoMigrate:
+		return fmt.Errorf("%w: the database is at version %d but this application requires version %d; run `cchc-ctrl migrate`",
+			ErrSchemaBehind, status.Version, RequiredVersion)
+	case status.Version < RequiredVersion:
+		err = MigrateTo(ctx, connstr, RequiredVersion)
+		if err != nil {
+			return fmt.Errorf("Error migrating the database to version %d: %w", RequiredVersion, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package health reports whether a service is alive and ready, so that an
+// orchestrator can restart workers which have stopped making progress.
+//
+// A service reports progress each time its main loop does some work or checks
+// for more work. If it goes longer than the progress window without doing so,
+// it is considered wedged: both /healthz and /readyz fail.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// pingTimeout is how long the database has to respond to a health check
+const pingTimeout = 5 * time.Second
+
+// Checker tracks the health of a service.
+type Checker struct {
+	ping   func(context.Context) error
+	window time.Duration
+
+	mu           sync.Mutex
+	ready        bool
+	shuttingDown bool
+	lastProgress time.Time
+}
+
+// New creates a checker which uses the ping function to check that the database
+// is reachable. The window is how long the service may go without reporting
+// progress. If the window is zero, it is set to twice the longest time that the
+// main loop can legitimately wait between iterations, such as the time it waits
+// to check for more jobs. If both are zero, progress is not checked, which suits
+// services that only respond to requests.
+func New(ping func(context.Context) error, window time.Duration, longestWait time.Duration) *Checker {
+	if window <= 0 {
+		window = 2 * longestWait
+	}
+	return &Checker{
+		ping:         ping,
+		window:       window,
+		lastProgress: time.Now(),
+	}
+}
+
+// Progress records that the main loop has done some work or checked for work.
+func (c *Checker) Progress() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastProgress = time.Now()
+}
+
+// Ready records that the service has been initialized. Once the context is
+// done, the service is treated as shutting down.
+func (c *Checker) Ready(ctx context.Context) {
+	c.mu.Lock()
+	c.ready = true
+	c.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		c.ShuttingDown()
+	}()
+}
+
+// ShuttingDown records that the service is shutting down, so it is no longer
+// ready.
+func (c *Checker) ShuttingDown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shuttingDown = true
+}
+
+// Status is the response to a health check.
+type Status struct {
+	Status       string    `json:"status"`
+	Problems     []string  `json:"problems,omitempty"`
+	LastProgress time.Time `json:"last_progress"`
+	Window       string    `json:"window"`
+}
+
+// Live checks that the database is reachable and that the main loop has made
+// progress recently.
+func (c *Checker) Live(ctx context.Context) *Status {
+	s := c.status()
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if err := c.ping(ctx); err != nil {
+		s.Problems = append(s.Problems, "database is not reachable: "+err.Error())
+	}
+	return s.finish()
+}
+
+// Readiness checks that the service has been initialized, is not shutting down,
+// and that the main loop has made progress recently.
+func (c *Checker) Readiness() *Status {
+	s := c.status()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.ready {
+		s.Problems = append(s.Problems, "service is still starting")
+	}
+	if c.shuttingDown {
+		s.Problems = append(s.Problems, "service is shutting down")
+	}
+	return s.finish()
+}
+
+// status reports on the progress of the main loop.
+func (c *Checker) status() *Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := &Status{LastProgress: c.lastProgress, Window: c.window.String()}
+	if c.window > 0 && time.Since(c.lastProgress) > c.window {
+		s.Problems = append(s.Problems, "no progress within the window")
+	}
+	return s
+}
+
+// finish sets the overall status from the problems found.
+func (s *Status) finish() *Status {
+	s.Status = "ok"
+	if len(s.Problems) > 0 {
+		s.Status = "fail"
+	}
+	return s
+}
+
+// Register adds the /healthz and /readyz endpoints to a mux.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		write(w, c.Live(r.Context()))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		write(w, c.Readiness())
+	})
+}
+
+// write sends the status as JSON, with a 503 status code if the check failed.
+func write(w http.ResponseWriter, s *Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if s.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(s)
+}
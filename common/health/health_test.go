@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, mux *http.ServeMux, path string) (int, *Status) {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	s := &Status{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), s))
+	return rec.Code, s
+}
+
+func TestChecker(t *testing.T) {
+	var dbErr error
+	ping := func(ctx context.Context) error { return dbErr }
+	c := New(ping, 50*time.Millisecond, time.Hour)
+	mux := http.NewServeMux()
+	c.Register(mux)
+
+	// Alive but not yet ready
+	code, _ := get(t, mux, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	code, s := get(t, mux, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{"service is still starting"}, s.Problems)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Ready(ctx)
+	code, _ = get(t, mux, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	// The database goes away
+	dbErr = errors.New("connection refused")
+	code, s = get(t, mux, "/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "fail", s.Status)
+	dbErr = nil
+
+	// The main loop stops making progress
+	time.Sleep(60 * time.Millisecond)
+	code, _ = get(t, mux, "/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	code, _ = get(t, mux, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	c.Progress()
+	code, _ = get(t, mux, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	// Shutting down is no longer ready, but is still alive
+	cancel()
+	assert.Eventually(t, func() bool {
+		code, _ := get(t, mux, "/readyz")
+		return code == http.StatusServiceUnavailable
+	}, time.Second, 5*time.Millisecond)
+	code, _ = get(t, mux, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestDefaultWindow(t *testing.T) {
+	c := New(nil, 0, 15*time.Minute)
+	assert.Equal(t, 30*time.Minute, c.window)
+}
+
+func TestNoProgressWindow(t *testing.T) {
+	c := New(nil, 0, 0)
+	c.lastProgress = time.Now().Add(-24 * time.Hour)
+	assert.Empty(t, c.status().Problems)
+}
@@ -0,0 +1,7 @@
+package items
+
+import "errors"
+
+// ErrNotFound is returned when an item or collection is not in the data store.
+// This would be an expected error.
+var ErrNotFound = errors.New("There is no item or collection with that ID")
@@ -49,3 +49,8 @@ func (item *Item) FullText() (text []PlainText, has bool) {
 
 	return text, has
 }
+
+// CatalogLanguages returns the languages of the item in its catalog record.
+func (item *Item) CatalogLanguages() []string {
+	return item.Languages
+}
@@ -100,6 +100,10 @@ func TestItemsDB(t *testing.T) {
 
 	assert.Equal(t, itemSaved, itemSavedAndFetched)
 
+	// An item which isn't in the database is not found
+	_, err = itemsRepo.Get(ctx, "http://www.loc.gov/item/missing/")
+	assert.ErrorIs(t, err, items.ErrNotFound)
+
 }
 
 func TestUnfetched(t *testing.T) {
@@ -49,6 +49,18 @@ type ItemFile struct {
 	Use             sql.NullString
 }
 
+// Collection is a digital collection in the LOC digital collections, along with
+// the number of its items which have been crawled.
+type Collection struct {
+	ID          string
+	Title       sql.NullString
+	Description sql.NullString
+	URL         sql.NullString
+	Subjects    []string
+	Count       sql.NullInt32 // The number of items reported by the LOC.gov API
+	Items       int           // The number of items in the database
+}
+
 // PlainText is the cleaned up, plain text of part (or all) of an item.
 type PlainText struct {
 	Text string
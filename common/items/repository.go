@@ -12,4 +12,6 @@ type Repository interface {
 	Get(ctx context.Context, ID string) (*Item, error)
 	GetAllUnfetched(ctx context.Context) ([]string, error)
 	Save(ctx context.Context, item *Item) error
+	GetCollection(ctx context.Context, ID string) (*Collection, error)
+	ListCollections(ctx context.Context, limit, offset int) ([]*Collection, error)
 }
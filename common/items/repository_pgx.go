@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
@@ -42,6 +43,9 @@ func (r *Repo) Get(ctx context.Context, ID string) (*Item, error) {
 
 	err := r.db.QueryRow(ctx, itemQuery, ID).
 		Scan(&item.ID, &item.URL, &item.Title, &item.Year, &item.Date, &item.Subjects, &item.Languages, &item.API, &item.Updated)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -173,3 +177,57 @@ func (r *Repo) GetAllUnfetched(ctx context.Context) ([]string, error) {
 
 	return unfetched, nil
 }
+
+// GetCollection fetches a collection from the database by its ID, along with
+// the number of its items in the database.
+func (r *Repo) GetCollection(ctx context.Context, ID string) (*Collection, error) {
+	query := `
+	SELECT c.id, c.title, c.description, c.url, c.subjects, c.count,
+		(SELECT COUNT(*) FROM items_in_collections ic WHERE ic.collection_id = c.id)
+	FROM collections c
+	WHERE c.id = $1;
+	`
+
+	c := Collection{}
+	err := r.db.QueryRow(ctx, query, ID).
+		Scan(&c.ID, &c.Title, &c.Description, &c.URL, &c.Subjects, &c.Count, &c.Items)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// ListCollections gets a page of collections, along with the number of items
+// in each, ordered by ID.
+func (r *Repo) ListCollections(ctx context.Context, limit, offset int) ([]*Collection, error) {
+	query := `
+	SELECT c.id, c.title, c.description, c.url, c.subjects, c.count, COUNT(ic.item_id)
+	FROM collections c
+	LEFT JOIN items_in_collections ic ON ic.collection_id = c.id
+	GROUP BY c.id
+	ORDER BY c.id
+	LIMIT $1 OFFSET $2;
+	`
+
+	rows, err := r.db.Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []*Collection
+	for rows.Next() {
+		c := Collection{}
+		err = rows.Scan(&c.ID, &c.Title, &c.Description, &c.URL, &c.Subjects, &c.Count, &c.Items)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, &c)
+	}
+
+	return collections, rows.Err()
+}
@@ -9,3 +9,7 @@ var ErrAllQueued = errors.New("All items have been queued for this destination")
 // ErrNoJobs is returned when there are no ready jobs for a particular destination.
 // This would be an expected error.
 var ErrNoJobs = errors.New("There are no ready jobs for that destination")
+
+// ErrNoCheckpoint is returned when a job has not recorded any progress. This
+// would be an expected error.
+var ErrNoCheckpoint = errors.New("There is no checkpoint for that job")
@@ -18,6 +18,7 @@ import (
 	"github.com/lmullen/cchc/common/db"
 	"github.com/lmullen/cchc/common/items"
 	"github.com/lmullen/cchc/common/jobs"
+	"github.com/lmullen/cchc/common/sources"
 	"github.com/orlangure/gnomock"
 	"github.com/orlangure/gnomock/preset/postgres"
 	"github.com/stretchr/testify/assert"
@@ -220,6 +221,21 @@ func TestNoJobsNeedEnqueuing(t *testing.T) {
 	assert.Nil(t, job)
 	assert.ErrorIs(t, err, jobs.ErrAllQueued)
 
+	// A book from the Stacks gets a job only when the destination uses the Stacks
+	_, err = db.Exec(ctx, `INSERT INTO stacks_books (lccn, title, text) VALUES ('2001012345', 'A book', 'Some text');`)
+	require.NoError(t, err)
+	_, err = jobsRepo.CreateJobForUnqueued(ctx, "testing", sources.Items)
+	assert.ErrorIs(t, err, jobs.ErrAllQueued)
+	job, err = jobsRepo.CreateJobForUnqueued(ctx, "testing", sources.Items, sources.Stacks)
+	require.NoError(t, err)
+	assert.Equal(t, sources.Stacks, job.Source)
+	assert.Equal(t, "2001012345", job.ItemID)
+	saved, err := jobsRepo.GetFullText(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sources.Stacks, saved.Source)
+	_, err = jobsRepo.CreateJobForUnqueued(ctx, "testing", sources.Stacks)
+	assert.ErrorIs(t, err, jobs.ErrAllQueued)
+
 }
 
 func TestGettingJobsFromQueue(t *testing.T) {
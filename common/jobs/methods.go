@@ -1,10 +1,12 @@
 package jobs
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lmullen/cchc/common/sources"
 )
 
 func (job FullText) String() string {
@@ -16,8 +18,15 @@ func (job FullText) String() string {
 // to be done), and whether or not it has full text associated with it. (If it
 // does not have full text, presumably you will skip the job.)
 func NewFullText(itemID string, destination string) *FullText {
+	return NewFullTextFrom(sources.Items, itemID, destination)
+}
+
+// NewFullTextFrom creates a new, unstarted job for full text for a document
+// from a particular source, such as a book from the Stacks.
+func NewFullTextFrom(source string, itemID string, destination string) *FullText {
 	return &FullText{
 		ID:          uuid.New(),
+		Source:      source,
 		ItemID:      itemID,
 		Destination: destination,
 		Status:      "ready",
@@ -47,3 +56,10 @@ func (job *FullText) Fail() {
 	job.Finished.Scan(time.Now())
 	job.Status = "failed"
 }
+
+// Requeue clears the finished field and sets the job status back to ready, so
+// that the job will be picked up again.
+func (job *FullText) Requeue() {
+	job.Finished = sql.NullTime{}
+	job.Status = "ready"
+}
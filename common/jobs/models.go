@@ -2,6 +2,7 @@ package jobs
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -9,11 +10,26 @@ import (
 // FullText is a record of how jobs with the full text of items were passed on to
 // some particular destination. It can handle recording full text jobs at either
 // the item (i.e., resource) or file level, and it can handle multiple destinations.
+// The item is a document from one of the sources of full text, such as the
+// loc.gov items or the books from the Stacks, and the ItemID is its ID in that
+// source.
 type FullText struct {
 	ID          uuid.UUID
+	Source      string
 	ItemID      string
 	Destination string
 	Started     sql.NullTime
 	Finished    sql.NullTime
 	Status      string
 }
+
+// Checkpoint records how far a job has progressed, so that a long-running job
+// which is interrupted can be resumed rather than started over. Progress is the
+// number of units of work (e.g., pages) which have been completed. State is
+// whatever else the destination needs to resume the job, serialized as JSON.
+type Checkpoint struct {
+	JobID    uuid.UUID
+	Progress int
+	State    []byte
+	Updated  time.Time
+}
@@ -9,12 +9,17 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 )
 
 // Repository is an interface describing a data store for jobs.
 type Repository interface {
+	WithTx(tx pgx.Tx) Repository
 	GetFullText(ctx context.Context, id uuid.UUID) (*FullText, error)
 	SaveFullText(ctx context.Context, job *FullText) error
-	CreateJobForUnqueued(ctx context.Context, destination string) (*FullText, error)
+	CreateJobForUnqueued(ctx context.Context, destination string, from ...string) (*FullText, error)
 	GetReadyJob(ctx context.Context, destination string) (*FullText, error)
+	GetCheckpoint(ctx context.Context, jobID uuid.UUID) (*Checkpoint, error)
+	SaveCheckpoint(ctx context.Context, c *Checkpoint) error
+	DeleteCheckpoint(ctx context.Context, jobID uuid.UUID) error
 }
@@ -2,16 +2,19 @@ package jobs
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/lmullen/cchc/common/db"
+	"github.com/lmullen/cchc/common/sources"
 )
 
 // Repo is a data store using PostgreSQL with the pgx native interface.
 type Repo struct {
-	db *pgxpool.Pool
+	db db.Conn
 }
 
 // NewJobsRepo returns an item repo using PostgreSQL with the pgx native interface.
@@ -21,11 +24,19 @@ func NewJobsRepo(db *pgxpool.Pool) *Repo {
 	}
 }
 
+// WithTx returns a repo which does its work in the transaction, so that it is
+// committed or rolled back along with the rest of the transaction.
+func (r *Repo) WithTx(tx pgx.Tx) Repository {
+	return &Repo{
+		db: tx,
+	}
+}
+
 // GetFullText finds a full text job by ID from the repository
 func (r *Repo) GetFullText(ctx context.Context, id uuid.UUID) (*FullText, error) {
 	query := `
 	SELECT 
-		id, item_id, destination, started, finished, status
+		id, source, item_id, destination, started, finished, status
 	FROM
 		jobs.fulltext
 	WHERE id = $1;
@@ -33,7 +44,7 @@ func (r *Repo) GetFullText(ctx context.Context, id uuid.UUID) (*FullText, error)
 
 	job := FullText{}
 
-	err := r.db.QueryRow(ctx, query, id).Scan(&job.ID, &job.ItemID,
+	err := r.db.QueryRow(ctx, query, id).Scan(&job.ID, &job.Source, &job.ItemID,
 		&job.Destination, &job.Started, &job.Finished, &job.Status)
 	if err != nil {
 		return nil, err
@@ -47,18 +58,25 @@ func (r *Repo) GetFullText(ctx context.Context, id uuid.UUID) (*FullText, error)
 func (r *Repo) SaveFullText(ctx context.Context, job *FullText) error {
 	query := `
 	INSERT INTO jobs.fulltext
-	VALUES ($1, $2, $3, $4, $5, $6)
+		(id, item_id, destination, started, finished, status, source)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
 	ON CONFLICT (id) DO UPDATE
 	SET
 	item_id = $2,
 	destination = $3,
 	started = $4,
 	finished = $5,
-	status = $6;
+	status = $6,
+	source = $7;
 	`
 
+	source := job.Source
+	if source == "" {
+		source = sources.Items
+	}
+
 	_, err := r.db.Exec(ctx, query,
-		job.ID, job.ItemID, job.Destination, job.Started, job.Finished, job.Status)
+		job.ID, job.ItemID, job.Destination, job.Started, job.Finished, job.Status, source)
 	if err != nil {
 		return err
 	}
@@ -67,61 +85,68 @@ func (r *Repo) SaveFullText(ctx context.Context, job *FullText) error {
 
 }
 
-// CreateJobForUnqueued creates a job associated with a single item for a single
-// destination. It should be safe for multiple workers to use.
-//
-// A potential problem is that occasionally a duplicate item key might be selected
-// simultaneously by multiple workers. If that is the case, the database will still
-// prevent the creation of a duplicate job for the same item and the same destination.
-// This error could be safely ignored.
-func (r *Repo) CreateJobForUnqueued(ctx context.Context, destination string) (*FullText, error) {
-
-	// query := `
-	// SELECT id
-	// FROM   items i
-	// WHERE api IS NOT NULL AND NOT EXISTS (
-	// 	SELECT item_id, destination
-	// 	FROM   jobs.fulltext
-	// 	WHERE  item_id=i.id AND destination = $1
-	//  )
-	// FOR UPDATE OF i SKIP LOCKED
-	// LIMIT 1;
-	// `
-
-	// query := `
-	// SELECT i.id
-	// FROM items i
-	// LEFT JOIN
-	// 	(SELECT id, item_id, destination
-	// 	FROM jobs.fulltext
-	// 	WHERE destination = $1) j
-	// ON i.id = j.item_id
-	// WHERE j.item_id IS NULL AND i.api IS NOT NULL
-	// FOR UPDATE OF i SKIP LOCKED
-	// LIMIT 1;
-	// `
-
-	query := `
-	SELECT id
+// unqueuedQueries select a document from each source which does not yet have
+// a job for a destination. The %s is replaced with the columns to select.
+var unqueuedQueries = map[string]string{
+	sources.Items: `
+	SELECT %s
 	FROM   items i
 	WHERE api IS NOT NULL AND NOT EXISTS (
 		SELECT item_id, destination
 		FROM   jobs.fulltext
-		WHERE  item_id=i.id AND destination = $1
+		WHERE  source = 'items' AND item_id=i.id AND destination = $1
 	 ) FOR NO KEY UPDATE OF i SKIP LOCKED
 	LIMIT 1;
-	`
-
-	lockQuery := `
-	SELECT pg_advisory_xact_lock(10)
-	FROM   items i
-	WHERE api IS NOT NULL AND NOT EXISTS (
+	`,
+	sources.Stacks: `
+	SELECT %s
+	FROM   stacks_books s
+	WHERE text IS NOT NULL AND NOT EXISTS (
 		SELECT item_id, destination
 		FROM   jobs.fulltext
-		WHERE  item_id=i.id AND destination = $1
-	 ) FOR NO KEY UPDATE OF i SKIP LOCKED
+		WHERE  source = 'stacks' AND item_id=s.lccn AND destination = $1
+	 ) FOR NO KEY UPDATE OF s SKIP LOCKED
 	LIMIT 1;
-	`
+	`,
+}
+
+// unqueuedColumns are the ID of a document in each source
+var unqueuedColumns = map[string]string{
+	sources.Items:  "id",
+	sources.Stacks: "lccn",
+}
+
+// CreateJobForUnqueued creates a job associated with a single item for a single
+// destination. It should be safe for multiple workers to use. The job is for a
+// document from the first of the sources which has documents without a job for
+// the destination. If no sources are given, only the loc.gov items are used.
+//
+// A potential problem is that occasionally a duplicate item key might be selected
+// simultaneously by multiple workers. If that is the case, the database will still
+// prevent the creation of a duplicate job for the same item and the same destination.
+// This error could be safely ignored.
+func (r *Repo) CreateJobForUnqueued(ctx context.Context, destination string, from ...string) (*FullText, error) {
+	if len(from) == 0 {
+		from = []string{sources.Items}
+	}
+	for _, source := range from {
+		job, err := r.createJobFrom(ctx, source, destination)
+		if err == ErrAllQueued {
+			continue
+		}
+		return job, err
+	}
+	return nil, ErrAllQueued
+}
+
+// createJobFrom creates a job for a document from a single source.
+func (r *Repo) createJobFrom(ctx context.Context, source string, destination string) (*FullText, error) {
+	tmpl, ok := unqueuedQueries[source]
+	if !ok {
+		return nil, fmt.Errorf("unknown source of documents %q", source)
+	}
+	query := fmt.Sprintf(tmpl, unqueuedColumns[source])
+	lockQuery := fmt.Sprintf(tmpl, "pg_advisory_xact_lock(10)")
 
 	timeout, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
@@ -157,7 +182,7 @@ func (r *Repo) CreateJobForUnqueued(ctx context.Context, destination string) (*F
 	}
 
 	// Now we have the item ID, so create a job for that item and that destination.
-	job := NewFullText(itemID, destination)
+	job := NewFullTextFrom(source, itemID, destination)
 	err = r.SaveFullText(timeout, job)
 	if err != nil {
 		tx.Rollback(timeout)
@@ -182,7 +207,7 @@ func (r *Repo) GetReadyJob(ctx context.Context, destination string) (*FullText,
 	defer cancel()
 
 	getQuery := `
-	SELECT id, item_id, destination, started, finished, status
+	SELECT id, source, item_id, destination, started, finished, status
 	FROM jobs.fulltext
 	WHERE status = 'ready' AND destination = $1
 	FOR UPDATE SKIP LOCKED
@@ -204,6 +229,7 @@ func (r *Repo) GetReadyJob(ctx context.Context, destination string) (*FullText,
 
 	err = tx.QueryRow(timeout, getQuery, destination).Scan(
 		&job.ID,
+		&job.Source,
 		&job.ItemID,
 		&job.Destination,
 		&job.Started,
@@ -244,3 +270,58 @@ func (r *Repo) GetReadyJob(ctx context.Context, destination string) (*FullText,
 	return &job, nil
 
 }
+
+// GetCheckpoint gets the most recent checkpoint for a job. If the job has not
+// recorded any progress, it returns ErrNoCheckpoint.
+func (r *Repo) GetCheckpoint(ctx context.Context, jobID uuid.UUID) (*Checkpoint, error) {
+	query := `
+	SELECT job_id, progress, state, updated
+	FROM jobs.checkpoints
+	WHERE job_id = $1;
+	`
+
+	c := Checkpoint{}
+
+	err := r.db.QueryRow(ctx, query, jobID).Scan(&c.JobID, &c.Progress, &c.State, &c.Updated)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNoCheckpoint
+		}
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// SaveCheckpoint records the progress for a job, replacing any previous
+// checkpoint.
+func (r *Repo) SaveCheckpoint(ctx context.Context, c *Checkpoint) error {
+	query := `
+	INSERT INTO jobs.checkpoints (job_id, progress, state, updated)
+	VALUES ($1, $2, $3, NOW())
+	ON CONFLICT (job_id) DO UPDATE
+	SET
+	progress = $2,
+	state = $3,
+	updated = NOW();
+	`
+
+	_, err := r.db.Exec(ctx, query, c.JobID, c.Progress, c.State)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteCheckpoint removes the checkpoint for a job, if there is one.
+func (r *Repo) DeleteCheckpoint(ctx context.Context, jobID uuid.UUID) error {
+	query := `DELETE FROM jobs.checkpoints WHERE job_id = $1;`
+
+	_, err := r.db.Exec(ctx, query, jobID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
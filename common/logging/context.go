@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lmullen/cchc/common/jobs"
+	log "github.com/sirupsen/logrus"
+)
+
+// fieldsKey is the key for the log fields stored in a context
+type fieldsKey struct{}
+
+// WithFields returns a context which carries these log fields in addition to
+// any fields already in the context.
+func WithFields(ctx context.Context, fields log.Fields) context.Context {
+	existing, _ := ctx.Value(fieldsKey{}).(log.Fields)
+	merged := make(log.Fields, len(existing)+len(fields))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// WithField returns a context which carries this log field in addition to any
+// fields already in the context.
+func WithField(ctx context.Context, key string, value interface{}) context.Context {
+	return WithFields(ctx, log.Fields{key: value})
+}
+
+// NewCorrelationID returns a context with a new correlation ID, which should be
+// created at the start of each unit of work.
+func NewCorrelationID(ctx context.Context) context.Context {
+	return WithField(ctx, FieldCorrelation, uuid.NewString())
+}
+
+// NewBatch returns a context for a new batch of jobs, along with the batch ID.
+// The batch ID is also used as the correlation ID for the batch.
+func NewBatch(ctx context.Context) (context.Context, string) {
+	id := uuid.NewString()
+	return WithFields(ctx, log.Fields{FieldBatch: id, FieldCorrelation: id}), id
+}
+
+// CorrelationID returns the correlation ID in the context, if there is one.
+func CorrelationID(ctx context.Context) string {
+	fields, _ := ctx.Value(fieldsKey{}).(log.Fields)
+	id, _ := fields[FieldCorrelation].(string)
+	return id
+}
+
+// WithJob returns a context which carries the fields identifying a job.
+func WithJob(ctx context.Context, job *jobs.FullText) context.Context {
+	return WithFields(ctx, Job(job))
+}
+
+// Job returns the fields identifying a job. The status is not included, since
+// it changes as the job is processed.
+func Job(job *jobs.FullText) log.Fields {
+	return log.Fields{
+		FieldJob:         job.ID.String(),
+		FieldItem:        job.ItemID,
+		FieldSource:      job.Source,
+		FieldDestination: job.Destination,
+	}
+}
+
+// From returns a log entry with the fields carried in the context.
+func From(ctx context.Context) *log.Entry {
+	fields, _ := ctx.Value(fieldsKey{}).(log.Fields)
+	return log.WithContext(ctx).WithFields(fields)
+}
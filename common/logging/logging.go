@@ -0,0 +1,87 @@
+// Package logging sets up the structured logging which is shared by the CCHC
+// services, so that logs from every service can be collected and joined.
+//
+// Every entry records the service and worker which wrote it. Fields for the
+// unit of work, such as the job, item, and a correlation ID, are carried in a
+// context.Context and added to entries with From.
+package logging
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Field names which are used consistently across the services
+const (
+	FieldService     = "service"        // The name of the service, e.g., cchc-itemmd
+	FieldWorker      = "worker_id"      // The host and process running the service
+	FieldCorrelation = "correlation_id" // Shared by all entries for one unit of work
+	FieldBatch       = "batch_id"       // A batch of jobs processed together
+	FieldJob         = "job_id"
+	FieldItem        = "item_id"
+	FieldSource      = "source" // The source of the item, e.g., items or stacks
+	FieldCollection  = "collection_id"
+	FieldDestination = "destination"
+	FieldStatus      = "status"
+)
+
+// Formats for log output
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Setup sets the level and format of the standard logger, and makes every entry
+// record the service and worker which wrote it.
+func Setup(service, level, format string) error {
+	l, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(l)
+
+	switch format {
+	case FormatText, "":
+		log.SetFormatter(&log.TextFormatter{})
+	case FormatJSON:
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("The log format must be %s or %s, not %q", FormatText, FormatJSON, format)
+	}
+
+	log.AddHook(&serviceHook{fields: log.Fields{
+		FieldService: service,
+		FieldWorker:  WorkerID(),
+	}})
+	return nil
+}
+
+// WorkerID identifies this process, so that the logs from several copies of the
+// same service can be told apart.
+func WorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// serviceHook adds fields to every log entry.
+type serviceHook struct {
+	fields log.Fields
+}
+
+func (h *serviceHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *serviceHook) Fire(e *log.Entry) error {
+	for k, v := range h.fields {
+		if _, ok := e.Data[k]; !ok {
+			e.Data[k] = v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/lmullen/cchc/common/jobs"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextFields(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "", CorrelationID(ctx))
+
+	ctx = NewCorrelationID(ctx)
+	id := CorrelationID(ctx)
+	assert.NotEmpty(t, id)
+
+	job := jobs.NewFullText("http://www.loc.gov/item/test/", "language")
+	jobCtx := WithJob(ctx, job)
+	e := From(jobCtx)
+	assert.Equal(t, id, e.Data[FieldCorrelation])
+	assert.Equal(t, job.ID.String(), e.Data[FieldJob])
+	assert.Equal(t, job.ItemID, e.Data[FieldItem])
+	assert.Equal(t, "language", e.Data[FieldDestination])
+
+	// The parent context is not changed
+	assert.NotContains(t, From(ctx).Data, FieldJob)
+
+	batchCtx, batch := NewBatch(ctx)
+	assert.Equal(t, batch, CorrelationID(batchCtx))
+	assert.Equal(t, batch, From(batchCtx).Data[FieldBatch])
+}
+
+func TestSetupJSON(t *testing.T) {
+	defer log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	defer log.SetFormatter(&log.TextFormatter{})
+	defer log.SetOutput(log.StandardLogger().Out)
+
+	require.NoError(t, Setup("cchc-test", "debug", FormatJSON))
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+
+	ctx := WithField(NewCorrelationID(context.Background()), FieldItem, "test")
+	From(ctx).Debug("Testing")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "cchc-test", entry[FieldService])
+	assert.Equal(t, WorkerID(), entry[FieldWorker])
+	assert.Equal(t, "test", entry[FieldItem])
+	assert.Equal(t, CorrelationID(ctx), entry[FieldCorrelation])
+	assert.Equal(t, "Testing", entry["msg"])
+
+	assert.Error(t, Setup("cchc-test", "debug", "xml"))
+	assert.Error(t, Setup("cchc-test", "verbose", FormatText))
+}
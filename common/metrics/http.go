@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/ratelimit"
+)
+
+// transport records the status code of every response.
+type transport struct {
+	next http.RoundTripper
+}
+
+// InstrumentTransport wraps an HTTP transport so that the status code of every
+// response is counted. Wrap the transport underneath any client which retries
+// requests, so that each attempt is counted. If next is nil, the default
+// transport is used.
+func InstrumentTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	HTTPResponses.WithLabelValues(endpoint(req), code).Inc()
+	return resp, err
+}
+
+// endpoint is the first part of the path of a request to the LOC.gov API, such
+// as item or collections, which identifies the endpoint without giving every URL
+// its own label.
+func endpoint(req *http.Request) string {
+	path := strings.Trim(req.URL.Path, "/")
+	if path == "" {
+		return "root"
+	}
+	return strings.SplitN(path, "/", 2)[0]
+}
+
+// limiter records how long each call to Take waits.
+type limiter struct {
+	next     ratelimit.Limiter
+	endpoint string
+}
+
+// InstrumentLimiter wraps a rate limiter so that the time spent waiting for it
+// is recorded for the endpoint.
+func InstrumentLimiter(next ratelimit.Limiter, endpoint string) ratelimit.Limiter {
+	return &limiter{next: next, endpoint: endpoint}
+}
+
+func (l *limiter) Take() time.Time {
+	start := time.Now()
+	t := l.next.Take()
+	RateLimitWait.WithLabelValues(l.endpoint).Observe(time.Since(start).Seconds())
+	return t
+}
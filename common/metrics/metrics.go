@@ -0,0 +1,95 @@
+// Package metrics defines the Prometheus metrics reported by the CCHC services
+// and serves them at /metrics.
+//
+// The metrics are registered with the default registry, which also reports
+// metrics about the Go runtime and the process. Each service only updates the
+// metrics which apply to it.
+package metrics
+
+import (
+	"github.com/lmullen/cchc/common/jobs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "cchc"
+
+// Metrics for items discovered by the crawler and fetched by the item metadata
+// fetcher
+var (
+	ItemsDiscovered = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "items_discovered_total",
+		Help:      "Number of items discovered in collections and saved to the database.",
+	})
+	ItemsFetched = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "items_fetched_total",
+		Help:      "Number of items whose metadata was fetched, by result (success or failure).",
+	}, []string{"result"})
+)
+
+// Metrics for requests to the LOC.gov API
+var (
+	HTTPResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_responses_total",
+		Help:      "Number of responses from the LOC.gov API, by endpoint and status code. Requests without a response have the code \"error\".",
+	}, []string{"endpoint", "code"})
+	RateLimitWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ratelimit_wait_seconds",
+		Help:      "Time spent waiting on the rate limiter before a request to the LOC.gov API, by endpoint.",
+		Buckets:   []float64{0.001, 0.01, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"endpoint"})
+)
+
+// Metrics for jobs and batches of jobs
+var (
+	Jobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_total",
+		Help:      "Number of jobs claimed, finished, failed, skipped, or requeued, by destination.",
+	}, []string{"destination", "status"})
+	BatchItems = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_items",
+		Help:      "Number of items in each batch sent to the quotation detector.",
+		Buckets:   prometheus.LinearBuckets(0, 5, 11),
+	})
+	BatchPages = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_pages",
+		Help:      "Number of pages in each batch sent to the quotation detector.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 11),
+	})
+	RscriptDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rscript_duration_seconds",
+		Help:      "Time taken to run the prediction model in R for each batch, by result (success or failure).",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 14),
+	}, []string{"result"})
+)
+
+// JobClaimed counts a job which has been taken from the queue.
+func JobClaimed(job *jobs.FullText) {
+	Jobs.WithLabelValues(job.Destination, "claimed").Inc()
+}
+
+// JobDone counts a job by the status it was saved with: finished, failed,
+// skipped, or requeued if it was put back in the queue.
+func JobDone(job *jobs.FullText) {
+	status := job.Status
+	if status == "ready" {
+		status = "requeued"
+	}
+	Jobs.WithLabelValues(job.Destination, status).Inc()
+}
+
+// Result is the label for whether an operation succeeded.
+func Result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
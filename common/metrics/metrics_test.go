@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lmullen/cchc/common/jobs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/ratelimit"
+)
+
+func TestInstrumentTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/item/missing/" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: InstrumentTransport(nil)}
+	ok := HTTPResponses.WithLabelValues("item", "200")
+	missing := HTTPResponses.WithLabelValues("item", "404")
+	before := testutil.ToFloat64(ok)
+
+	for _, path := range []string{"/item/test/", "/item/test2/", "/item/missing/"} {
+		resp, err := client.Get(srv.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, before+2, testutil.ToFloat64(ok))
+	assert.Equal(t, float64(1), testutil.ToFloat64(missing))
+}
+
+func Test_endpoint(t *testing.T) {
+	req := httptest.NewRequest("GET", "https://www.loc.gov/collections/civil-war-maps/?fo=json", nil)
+	assert.Equal(t, "collections", endpoint(req))
+	req = httptest.NewRequest("GET", "https://www.loc.gov/", nil)
+	assert.Equal(t, "root", endpoint(req))
+}
+
+func TestInstrumentLimiter(t *testing.T) {
+	l := InstrumentLimiter(ratelimit.New(100, ratelimit.Per(time.Second)), "test")
+	l.Take()
+	l.Take()
+	assert.Equal(t, 1, testutil.CollectAndCount(RateLimitWait))
+}
+
+func TestJobs(t *testing.T) {
+	job := jobs.NewFullText("http://www.loc.gov/item/test/", "test")
+	JobClaimed(job)
+	job.Requeue()
+	JobDone(job)
+	job.Fail()
+	JobDone(job)
+	assert.Equal(t, float64(1), testutil.ToFloat64(Jobs.WithLabelValues("test", "claimed")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(Jobs.WithLabelValues("test", "requeued")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(Jobs.WithLabelValues("test", "failed")))
+}
+
+func TestResult(t *testing.T) {
+	assert.Equal(t, "success", Result(nil))
+	assert.Equal(t, "failure", Result(errors.New("failed")))
+}
+
+func TestServe(t *testing.T) {
+	srv, err := Serve("127.0.0.1:0", nil)
+	require.NoError(t, err)
+	defer srv.Close()
+	assert.NotEqual(t, "127.0.0.1:0", srv.Addr)
+
+	res, err := http.Get("http://" + srv.Addr + "/metrics")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	// A second copy of the service can't serve on the same port
+	_, err = Serve(srv.Addr, nil)
+	assert.Error(t, err)
+
+	srv, err = Serve("", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, srv)
+}
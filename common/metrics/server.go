@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// Serve starts an HTTP server in the background which serves the metrics at
+// /metrics, along with any other handlers already added to the mux, such as
+// health checks. If the mux is nil, a new one is created. If the address is
+// empty, no server is started and nil is returned. If the port is 0, a free
+// port is chosen, so that several copies of a service can run on one host. The
+// address is bound before Serve returns, so an address which is already in use
+// is an error. Close the server when the service shuts down.
+func Serve(addr string, mux *http.ServeMux) (*http.Server, error) {
+	if addr == "" {
+		return nil, nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Error serving metrics on %s: %w", addr, err)
+	}
+
+	if mux == nil {
+		mux = http.NewServeMux()
+	}
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{Addr: ln.Addr().String(), Handler: mux}
+
+	log.WithField("addr", srv.Addr).Info("Serving metrics and health checks")
+	go func() {
+		err := srv.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.WithError(err).Error("Error serving metrics")
+		}
+	}()
+
+	return srv, nil
+}
@@ -0,0 +1,321 @@
+package results
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// countsLock is the key for the advisory lock held while changing the quotation
+// counts, so that workers refreshing them at the same time don't count the same
+// jobs twice.
+const countsLock = 20220901
+
+// RefreshQuotationCounts adds the quotations from jobs for the destination
+// which have finished since the counts were last refreshed, at every threshold.
+// It returns the number of jobs which were counted. The counts are normalized by
+// the items checked for quotations, so they only include the jobs for one
+// destination: jobs for any other destination which were counted before are
+// removed from the counts first.
+func (r *Repo) RefreshQuotationCounts(ctx context.Context, destination string) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx) // Roll back the transaction if something goes wrong
+
+	_, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1);`, countsLock)
+	if err != nil {
+		return 0, err
+	}
+
+	err = createCountingJobs(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(ctx, `
+	INSERT INTO counting_jobs (job_id, source, item_id, threshold)
+	SELECT c.job_id, j.source, j.item_id, c.threshold
+	FROM results.quotation_counted_jobs c
+	JOIN jobs.fulltext j ON j.id = c.job_id
+	WHERE j.destination <> $1;
+	`, destination)
+	if err != nil {
+		return 0, err
+	}
+	err = updateCounts(ctx, tx, -1)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(ctx, `
+	DELETE FROM results.quotation_counted_jobs c
+	USING counting_jobs o
+	WHERE c.job_id = o.job_id AND c.threshold = o.threshold;
+	`)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(ctx, `TRUNCATE counting_jobs;`)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO counting_jobs (job_id, source, item_id, threshold)
+	SELECT j.id, j.source, j.item_id, t.threshold
+	FROM jobs.fulltext j
+	CROSS JOIN results.quotation_thresholds t
+	WHERE j.destination = $1 AND j.status = 'finished'
+		AND NOT EXISTS (
+			SELECT FROM results.quotation_counted_jobs c
+			WHERE c.job_id = j.id AND c.threshold = t.threshold);
+	`, destination)
+	if err != nil {
+		return 0, err
+	}
+
+	err = updateCounts(ctx, tx, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO results.quotation_counted_jobs (job_id, threshold)
+	SELECT job_id, threshold FROM counting_jobs;
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	err = tx.QueryRow(ctx, `SELECT COUNT(DISTINCT job_id) FROM counting_jobs;`).Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit(ctx)
+}
+
+// UncountJobs removes the quotations from the jobs from the counts, so that the
+// jobs can be rerun or deleted without their quotations being counted twice. It
+// must be called in the same transaction which deletes the quotations, before
+// they are deleted.
+func UncountJobs(ctx context.Context, tx pgx.Tx, jobIDs []uuid.UUID) error {
+	if len(jobIDs) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1);`, countsLock)
+	if err != nil {
+		return err
+	}
+
+	err = createCountingJobs(ctx, tx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+	INSERT INTO counting_jobs (job_id, source, item_id, threshold)
+	SELECT c.job_id, j.source, j.item_id, c.threshold
+	FROM results.quotation_counted_jobs c
+	JOIN jobs.fulltext j ON j.id = c.job_id
+	WHERE c.job_id = ANY($1);
+	`, jobIDs)
+	if err != nil {
+		return err
+	}
+
+	err = updateCounts(ctx, tx, -1)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM results.quotation_counted_jobs WHERE job_id = ANY($1);`, jobIDs)
+	return err
+}
+
+// createCountingJobs creates a temporary table to hold the jobs being added to
+// or removed from the counts.
+func createCountingJobs(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+	CREATE TEMPORARY TABLE counting_jobs (
+		job_id uuid NOT NULL,
+		source text NOT NULL,
+		item_id text NOT NULL,
+		threshold real NOT NULL
+	) ON COMMIT DROP;
+	`)
+	return err
+}
+
+// updateCounts adds (if the sign is 1) or subtracts (if the sign is -1) the
+// quotations from the jobs in the counting_jobs table to or from the counts. An
+// item is counted in each of its collections, and once in all collections. A
+// book from the Stacks is counted in the Stacks collection and in all
+// collections. A page is counted once however many translations quote the verse
+// on it, and quotations without a recorded passage count as a single page of
+// their item.
+func updateCounts(ctx context.Context, tx pgx.Tx, sign int) error {
+	queries := []string{`
+	CREATE TEMPORARY TABLE counting_items ON COMMIT DROP AS
+	SELECT j.job_id, j.threshold, d.id AS item_id, d.year, ic.collection_id
+	FROM counting_jobs j
+	JOIN documents d ON d.source = j.source AND d.id = j.item_id
+	JOIN items_in_collections ic ON ic.item_id = d.id
+	WHERE d.source = 'items' AND d.year IS NOT NULL
+	UNION ALL
+	SELECT j.job_id, j.threshold, d.id AS item_id, d.year, '` + StacksCollection + `'
+	FROM counting_jobs j
+	JOIN documents d ON d.source = j.source AND d.id = j.item_id
+	WHERE d.source = 'stacks' AND d.year IS NOT NULL
+	UNION ALL
+	SELECT j.job_id, j.threshold, d.id AS item_id, d.year, '` + AllCollections + `'
+	FROM counting_jobs j
+	JOIN documents d ON d.source = j.source AND d.id = j.item_id
+	WHERE d.year IS NOT NULL;
+	`, `
+	INSERT INTO results.quotation_items_counted (threshold, year, collection_id, items)
+	SELECT threshold, year, collection_id, $1::integer * COUNT(DISTINCT item_id)
+	FROM counting_items
+	GROUP BY threshold, year, collection_id
+	ON CONFLICT (threshold, year, collection_id) DO UPDATE
+	SET items = quotation_items_counted.items + EXCLUDED.items;
+	`, `
+	INSERT INTO results.quotation_counts (threshold, reference_id, year, collection_id, items, pages)
+	SELECT c.threshold, q.canonical_reference_id, c.year, c.collection_id,
+		$1::integer * COUNT(DISTINCT c.item_id), $1::integer * COUNT(DISTINCT (c.item_id, q.page))
+	FROM counting_items c
+	JOIN results.biblical_quotations_canonical q ON q.job_id = c.job_id AND q.probability >= c.threshold
+	GROUP BY c.threshold, q.canonical_reference_id, c.year, c.collection_id
+	ON CONFLICT (threshold, reference_id, year, collection_id) DO UPDATE
+	SET items = quotation_counts.items + EXCLUDED.items,
+		pages = quotation_counts.pages + EXCLUDED.pages;
+	`, `
+	DELETE FROM results.quotation_items_counted WHERE items <= 0;
+	`, `
+	DELETE FROM results.quotation_counts WHERE items <= 0;
+	`, `
+	DROP TABLE counting_items;
+	`}
+
+	for i, q := range queries {
+		var err error
+		if i == 1 || i == 2 {
+			_, err = tx.Exec(ctx, q, sign)
+		} else {
+			_, err = tx.Exec(ctx, q)
+		}
+		if err != nil {
+			return fmt.Errorf("Error updating quotation counts: %w", err)
+		}
+	}
+	return nil
+}
+
+// ResetQuotationCounts removes the counts at a threshold, adding the threshold
+// if it has not been counted before. The next refresh counts every job at that
+// threshold.
+func (r *Repo) ResetQuotationCounts(ctx context.Context, threshold float64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Roll back the transaction if something goes wrong
+
+	_, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1);`, countsLock)
+	if err != nil {
+		return err
+	}
+
+	queries := []string{
+		`INSERT INTO results.quotation_thresholds (threshold) VALUES ($1) ON CONFLICT DO NOTHING;`,
+		`DELETE FROM results.quotation_counted_jobs WHERE threshold = $1;`,
+		`DELETE FROM results.quotation_counts WHERE threshold = $1;`,
+		`DELETE FROM results.quotation_items_counted WHERE threshold = $1;`,
+	}
+	for _, q := range queries {
+		_, err = tx.Exec(ctx, q, threshold)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// CountedDestination returns the destination of the jobs whose quotations are
+// in the counts, or an empty string if no jobs have been counted.
+func (r *Repo) CountedDestination(ctx context.Context) (string, error) {
+	var destination string
+	err := r.db.QueryRow(ctx, `
+	SELECT j.destination
+	FROM results.quotation_counted_jobs c
+	JOIN jobs.fulltext j ON j.id = c.job_id
+	LIMIT 1;
+	`).Scan(&destination)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	return destination, err
+}
+
+// RemoveQuotationThreshold stops counting quotations at a threshold and removes
+// its counts.
+func (r *Repo) RemoveQuotationThreshold(ctx context.Context, threshold float64) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM results.quotation_thresholds WHERE threshold = $1;`, threshold)
+	return err
+}
+
+// QuotationTimeSeries gets the number of items and pages quoting a verse in
+// each year or decade for which any items have been counted, including those in
+// which the verse was not quoted.
+func (r *Repo) QuotationTimeSeries(ctx context.Context, f TimeSeriesFilter) ([]*QuotationCount, error) {
+	bucket := 1
+	if f.Decades {
+		bucket = 10
+	}
+	collection := f.CollectionID
+	if collection == "" {
+		collection = AllCollections
+	}
+	var threshold interface{}
+	if f.Threshold != 0 {
+		threshold = f.Threshold
+	}
+
+	query := `
+	WITH t AS (
+		SELECT COALESCE($2::real, (SELECT MIN(threshold) FROM results.quotation_thresholds)) AS threshold
+	)
+	SELECT n.year / $4 * $4 AS bucket, t.threshold::numeric::double precision,
+		COALESCE(SUM(c.items), 0), COALESCE(SUM(c.pages), 0), SUM(n.items)
+	FROM results.quotation_items_counted n
+	JOIN t ON t.threshold = n.threshold
+	LEFT JOIN results.quotation_counts c
+		ON c.threshold = n.threshold AND c.year = n.year
+		AND c.collection_id = n.collection_id AND c.reference_id = $1
+	WHERE n.collection_id = $3
+	GROUP BY bucket, t.threshold
+	ORDER BY bucket;
+	`
+
+	rows, err := r.db.Query(ctx, query, f.ReferenceID, threshold, collection, bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []*QuotationCount
+	for rows.Next() {
+		c := QuotationCount{ReferenceID: f.ReferenceID, CollectionID: collection}
+		err = rows.Scan(&c.Year, &c.Threshold, &c.Items, &c.Pages, &c.ItemsCounted)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, &c)
+	}
+
+	return counts, rows.Err()
+}
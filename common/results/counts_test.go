@@ -0,0 +1,14 @@
+package results
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotationCountShare(t *testing.T) {
+	c := &QuotationCount{Items: 5, ItemsCounted: 200}
+	assert.Equal(t, 0.025, c.Share())
+	c = &QuotationCount{}
+	assert.Equal(t, float64(0), c.Share())
+}
@@ -0,0 +1,326 @@
+package results
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// clustersLock is the key for the advisory lock held while merging clusters, so
+// that workers clustering items at the same time don't split a cluster.
+const clustersLock = 20220902
+
+// LockClusters takes the lock on the clusters for the rest of the transaction.
+// A worker which takes the lock before looking for duplicates sees the passages
+// saved by every job which finished before it, so no duplicates are missed
+// between jobs which run at the same time.
+func LockClusters(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1);`, clustersLock)
+	return err
+}
+
+// SavePassageSignatures serializes the signatures of passages, along with their
+// bands, to the database.
+func (r *Repo) SavePassageSignatures(ctx context.Context, sigs []*PassageSignature) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Roll back the transaction if something goes wrong
+
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"results", "passage_signatures"},
+		[]string{"job_id", "item_id", "page", "passage", "start_char", "end_char", "minhash"},
+		pgx.CopyFromSlice(len(sigs), func(i int) ([]interface{}, error) {
+			s := sigs[i]
+			return []interface{}{s.JobID, s.ItemID, s.Page, s.Passage, s.StartChar, s.EndChar, signed(s.MinHash)}, nil
+		}))
+	if err != nil {
+		return err
+	}
+
+	var bands [][]interface{}
+	for _, s := range sigs {
+		for b, h := range s.Bands {
+			bands = append(bands, []interface{}{s.JobID, s.Page, s.Passage, int16(b), int64(h)})
+		}
+	}
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"results", "passage_bands"},
+		[]string{"job_id", "page", "passage", "band", "hash"},
+		pgx.CopyFromRows(bands))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// PassageCandidates gets the passages in other items which share a band with
+// any of the passages saved for a job.
+func (r *Repo) PassageCandidates(ctx context.Context, jobID uuid.UUID, itemID string) ([]*PassageCandidate, error) {
+	query := `
+	SELECT DISTINCT mine.page, mine.passage,
+		s.job_id, s.item_id, s.page, s.passage, s.start_char, s.end_char, s.minhash
+	FROM results.passage_bands mine
+	JOIN results.passage_bands b
+		ON b.band = mine.band AND b.hash = mine.hash AND b.job_id <> mine.job_id
+	JOIN results.passage_signatures s
+		ON s.job_id = b.job_id AND s.page = b.page AND s.passage = b.passage
+	WHERE mine.job_id = $1 AND s.item_id <> $2;
+	`
+
+	rows, err := r.db.Query(ctx, query, jobID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []*PassageCandidate
+	for rows.Next() {
+		c := PassageCandidate{Other: &PassageSignature{}}
+		var minhash []int64
+		err = rows.Scan(&c.Page, &c.Passage, &c.Other.JobID, &c.Other.ItemID, &c.Other.Page,
+			&c.Other.Passage, &c.Other.StartChar, &c.Other.EndChar, &minhash)
+		if err != nil {
+			return nil, err
+		}
+		c.Other.MinHash = unsigned(minhash)
+		candidates = append(candidates, &c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// SaveDuplicatePassages serializes pairs of near-duplicate passages to the
+// database.
+func (r *Repo) SaveDuplicatePassages(ctx context.Context, dups []*DuplicatePassage) error {
+	insert := `
+	INSERT INTO results.duplicate_passages
+		(job_id, item_id, page, passage, other_item_id, other_page, other_passage, similarity)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+	`
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Roll back the transaction if something goes wrong
+
+	for _, d := range dups {
+		_, err := tx.Exec(ctx, insert, d.JobID, d.ItemID, d.Page, d.Passage,
+			d.OtherItemID, d.OtherPage, d.OtherPassage, d.Similarity)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ClusterItems puts an item in the same cluster as the items it shares reprinted
+// text with, merging their clusters if they are in different ones. An item with
+// no duplicates is put in a cluster by itself. It returns the ID of the cluster.
+func (r *Repo) ClusterItems(ctx context.Context, itemID string, duplicates []string) (string, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx) // Roll back the transaction if something goes wrong
+
+	err = LockClusters(ctx, tx)
+	if err != nil {
+		return "", err
+	}
+
+	// The merged cluster takes the lowest ID of any of the clusters or items, so
+	// that the ID doesn't depend on the order in which items are processed.
+	members := append([]string{itemID}, duplicates...)
+	var clusterID string
+	err = tx.QueryRow(ctx, `
+	SELECT MIN(id) FROM (
+		SELECT cluster_id AS id FROM results.item_clusters WHERE item_id = ANY($1)
+		UNION ALL
+		SELECT unnest($1::text[])
+	) ids;
+	`, members).Scan(&clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(ctx, `
+	UPDATE results.item_clusters SET cluster_id = $2
+	WHERE cluster_id IN (SELECT cluster_id FROM results.item_clusters WHERE item_id = ANY($1));
+	`, members, clusterID)
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(ctx, `
+	INSERT INTO results.item_clusters (item_id, cluster_id)
+	SELECT unnest($1::text[]), $2
+	ON CONFLICT (item_id) DO UPDATE SET cluster_id = EXCLUDED.cluster_id;
+	`, members, clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	return clusterID, tx.Commit(ctx)
+}
+
+// clusteredItems returns the items which were clustered by the jobs. It must be
+// called before the results of the jobs are deleted.
+func clusteredItems(ctx context.Context, tx pgx.Tx, jobIDs []uuid.UUID) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+	SELECT DISTINCT item_id FROM results.passage_signatures WHERE job_id = ANY($1);
+	`, jobIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// reclusterItems rebuilds the clusters which contain the items, after the
+// results of the jobs which clustered those items have been deleted. Clusters
+// are only ever merged, so the duplicates which held them together might be
+// gone. The items are left out of the clusters until they are processed again,
+// and the rest of the items in their clusters are grouped again by the
+// duplicates which remain.
+func reclusterItems(ctx context.Context, tx pgx.Tx, removed []string) error {
+	if len(removed) == 0 {
+		return nil
+	}
+
+	err := LockClusters(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `
+	DELETE FROM results.item_clusters
+	WHERE cluster_id IN (SELECT cluster_id FROM results.item_clusters WHERE item_id = ANY($1))
+	RETURNING item_id;
+	`, removed)
+	if err != nil {
+		return err
+	}
+	gone := make(map[string]bool, len(removed))
+	for _, id := range removed {
+		gone[id] = true
+	}
+	var members []string
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if !gone[id] {
+			members = append(members, id)
+		}
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	if len(members) == 0 {
+		return nil
+	}
+
+	rows, err = tx.Query(ctx, `
+	SELECT DISTINCT item_id, other_item_id FROM results.duplicate_passages
+	WHERE item_id = ANY($1) AND other_item_id = ANY($1);
+	`, members)
+	if err != nil {
+		return err
+	}
+	var edges [][2]string
+	for rows.Next() {
+		var e [2]string
+		err = rows.Scan(&e[0], &e[1])
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		edges = append(edges, e)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	clusters := components(members, edges)
+	ids := make([]string, len(members))
+	for i, m := range members {
+		ids[i] = clusters[m]
+	}
+	_, err = tx.Exec(ctx, `
+	INSERT INTO results.item_clusters (item_id, cluster_id)
+	SELECT unnest($1::text[]), unnest($2::text[]);
+	`, members, ids)
+	return err
+}
+
+// components groups items which are connected by duplicates, returning the ID
+// of the cluster of each item. As when clusters are merged, the ID of a cluster
+// is the lowest ID of its items.
+func components(items []string, edges [][2]string) map[string]string {
+	parent := make(map[string]string, len(items))
+	for _, id := range items {
+		parent[id] = id
+	}
+	var find func(string) string
+	find = func(id string) string {
+		if parent[id] != id {
+			parent[id] = find(parent[id])
+		}
+		return parent[id]
+	}
+	for _, e := range edges {
+		a, b := find(e[0]), find(e[1])
+		if a == b {
+			continue
+		}
+		// Keep the lowest ID as the root
+		if b < a {
+			a, b = b, a
+		}
+		parent[b] = a
+	}
+
+	clusters := make(map[string]string, len(items))
+	for _, id := range items {
+		clusters[id] = find(id)
+	}
+	return clusters
+}
+
+// signed converts hashes to the signed integers stored by PostgreSQL.
+func signed(h []uint64) []int64 {
+	s := make([]int64, len(h))
+	for i, v := range h {
+		s[i] = int64(v)
+	}
+	return s
+}
+
+// unsigned converts hashes stored by PostgreSQL back to unsigned integers.
+func unsigned(s []int64) []uint64 {
+	h := make([]uint64, len(s))
+	for i, v := range s {
+		h[i] = uint64(v)
+	}
+	return h
+}
@@ -0,0 +1,26 @@
+package results
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComponents(t *testing.T) {
+	items := []string{"e", "b", "a", "d", "c", "f"}
+	edges := [][2]string{{"e", "c"}, {"b", "d"}, {"d", "f"}}
+
+	// Each cluster takes the lowest ID of its items, and an item without any
+	// duplicates is in a cluster by itself
+	assert.Equal(t, map[string]string{
+		"a": "a",
+		"b": "b", "d": "b", "f": "b",
+		"c": "c", "e": "c",
+	}, components(items, edges))
+}
+
+func TestSignedHashes(t *testing.T) {
+	h := []uint64{0, 1, 1 << 63, ^uint64(0)}
+	assert.Equal(t, []int64{0, 1, -1 << 63, -1}, signed(h))
+	assert.Equal(t, h, unsigned(signed(h)))
+}
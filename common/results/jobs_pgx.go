@@ -0,0 +1,70 @@
+package results
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// JobTables are the tables which hold results for a job. The results have to be
+// removed before a job can be deleted, and they are removed when a job is
+// requeued so that rerunning the job does not duplicate them.
+var JobTables = []string{
+	"results.languages",
+	"results.language_spans",
+	"results.language_classification",
+	"results.biblical_quotations",
+	"results.quotations",
+	"results.passage_bands",
+	"results.passage_signatures",
+	"results.duplicate_passages",
+}
+
+// DeleteJobResults deletes the results of jobs, so that the jobs can be run
+// again or deleted. Any quotations from the jobs are first removed from the
+// quotation counts, and any clusters of items which the jobs added to are
+// rebuilt. It must be called in a transaction.
+func DeleteJobResults(ctx context.Context, tx pgx.Tx, jobIDs []uuid.UUID) error {
+	if len(jobIDs) == 0 {
+		return nil
+	}
+
+	err := UncountJobs(ctx, tx, jobIDs)
+	if err != nil {
+		return fmt.Errorf("Error removing jobs from the quotation counts: %w", err)
+	}
+	clustered, err := clusteredItems(ctx, tx, jobIDs)
+	if err != nil {
+		return fmt.Errorf("Error getting clustered items: %w", err)
+	}
+	for _, table := range JobTables {
+		_, err = tx.Exec(ctx, `DELETE FROM `+table+` WHERE job_id = ANY($1);`, jobIDs)
+		if err != nil {
+			return fmt.Errorf("Error deleting results from %s: %w", table, err)
+		}
+	}
+	err = reclusterItems(ctx, tx, clustered)
+	if err != nil {
+		return fmt.Errorf("Error rebuilding clusters: %w", err)
+	}
+	return nil
+}
+
+// DeleteJobs deletes jobs along with their results and checkpoints, so that the
+// services will create them again for documents which still need work. It must
+// be called in a transaction.
+func DeleteJobs(ctx context.Context, tx pgx.Tx, jobIDs []uuid.UUID) error {
+	if len(jobIDs) == 0 {
+		return nil
+	}
+
+	err := DeleteJobResults(ctx, tx, jobIDs)
+	if err != nil {
+		return err
+	}
+	// The checkpoints are deleted along with the jobs
+	_, err = tx.Exec(ctx, `DELETE FROM jobs.fulltext WHERE id = ANY($1);`, jobIDs)
+	return err
+}
@@ -11,16 +11,258 @@ type Quotation struct {
 	ReferenceID string
 	VerseID     string
 	Probability float64
+	Translation string         // The reference text the verse was found in, such as kjv
+	Passage     *QuotedPassage // Nil if the passage was not located
+}
+
+// QuotedPassage is the text in an item which matches a quoted verse, so that
+// the quotation can be checked without reopening the full text. Offsets are
+// counted in characters from the start of the page's plain text. The context
+// is the passage along with some of the text around it, starting at
+// ContextStart.
+type QuotedPassage struct {
+	Page         int
+	StartChar    int
+	EndChar      int
+	Context      string
+	ContextStart int
+}
+
+// Match returns the matching text within the context.
+func (p *QuotedPassage) Match() string {
+	runes := []rune(p.Context)
+	start, end := p.StartChar-p.ContextStart, p.EndChar-p.ContextStart
+	if start < 0 || end > len(runes) || start > end {
+		return ""
+	}
+	return string(runes[start:end])
 }
 
 // NewQuotation creates a new quotation object
-func NewQuotation(JobID uuid.UUID, ItemID, ReferenceID, VerseID string, Probability float64) *Quotation {
+func NewQuotation(JobID uuid.UUID, ItemID, ReferenceID, VerseID string, Probability float64, Translation string) *Quotation {
 	return &Quotation{
 		JobID:       JobID,
 		ItemID:      ItemID,
 		ReferenceID: ReferenceID,
 		VerseID:     VerseID,
 		Probability: Probability,
+		Translation: Translation,
+	}
+
+}
+
+// CorpusQuotation is a quotation of a passage in a reference corpus other than
+// the Bible, such as a section of the Constitution.
+type CorpusQuotation struct {
+	JobID       uuid.UUID
+	ItemID      string
+	CorpusID    string
+	PassageID   string
+	Probability float64
+	Passage     *QuotedPassage // Nil if the passage was not located
+}
+
+// LanguageSpan is a run of contiguous sentences on a single page of an item
+// which were all identified as the same language. Offsets are counted in
+// characters from the start of the page's plain text.
+type LanguageSpan struct {
+	JobID      uuid.UUID
+	ItemID     string
+	Page       int
+	StartChar  int
+	EndChar    int
+	Sentences  int
+	Language   string
+	Confidence float64 // Mean confidence of the sentences in the span
+}
+
+// Agreement between the languages detected in an item and the languages
+// recorded for the item in the catalog.
+const (
+	CatalogAgrees    = "agree"    // Every language detected is in the catalog
+	CatalogPartial   = "partial"  // The primary language is in the catalog, but not every secondary language
+	CatalogDisagrees = "disagree" // The primary language is not in the catalog
+)
+
+// LanguageClassification is an item-level summary of the languages detected in
+// an item. The catalog agreement is empty if the catalog does not record any
+// languages for the item, or if no language could be detected.
+type LanguageClassification struct {
+	JobID              uuid.UUID
+	ItemID             string
+	PrimaryLanguage    string
+	SecondaryLanguages []string
+	Multilingual       bool
+	Diversity          float64 // Gini-Simpson index of the detected languages
+	Sentences          int
+	CatalogAgreement   string
+}
+
+// QuotationFilter limits which quotations are returned. Fields which are left
+// at their zero value do not limit the results.
+type QuotationFilter struct {
+	ReferenceID    string // A verse in any version, such as John 3:16, using the canonical versification
+	VerseID        string // A verse in a particular version, such as John 3:16 (KJV)
+	Translation    string // The reference text the verse was found in, such as kjv
+	ItemID         string
+	Source         string // The source of the item, either items (from loc.gov) or stacks
+	YearFrom       int    // Year of the item, inclusive
+	YearTo         int    // Year of the item, inclusive
+	MinProbability float64
+	Deduplicate    bool // Only one quotation of a verse for each cluster of reprinted items
+	Limit          int
+	Offset         int
+}
+
+// LanguageStat is the number of sentences in a language in an item.
+type LanguageStat struct {
+	ItemID    string
+	Language  string
+	Sentences int
+}
+
+// LanguageFilter limits which language stats are returned. Fields which are
+// left at their zero value do not limit the results.
+type LanguageFilter struct {
+	ItemID      string
+	Language    string
+	Destination string // The destination of the jobs which found the languages, or LanguageDestination if empty
+	Limit       int
+	Offset      int
+}
+
+// LanguageDestination is the destination of the jobs run by the language
+// detector with its default settings. Each item is processed once for each
+// destination, so language results are only counted for one destination at a
+// time.
+const LanguageDestination = "language"
+
+// LanguageTotal is the number of items and sentences in a language across
+// every item.
+type LanguageTotal struct {
+	Language  string
+	Items     int
+	Sentences int
+}
+
+// QuotationCount is the number of items and pages quoting a verse in a year
+// (or decade), along with the number of items from that year which were
+// checked for quotations.
+type QuotationCount struct {
+	ReferenceID  string
+	Threshold    float64
+	CollectionID string
+	Year         int // The first year of the decade if counting by decade
+	Items        int
+	Pages        int
+	ItemsCounted int
+}
+
+// Share is the proportion of the items checked for quotations which quote the
+// verse.
+func (c *QuotationCount) Share() float64 {
+	if c.ItemsCounted == 0 {
+		return 0
 	}
+	return float64(c.Items) / float64(c.ItemsCounted)
+}
+
+// AllCollections is the collection ID used in the quotation counts for items in
+// any collection, counting each item once.
+const AllCollections = "all"
+
+// StacksCollection is the collection ID used in the quotation counts for books
+// from the Stacks, which don't belong to any loc.gov collection. They are also
+// counted in AllCollections.
+const StacksCollection = "stacks"
+
+// TimeSeriesFilter selects the quotation counts for a verse. If the threshold is
+// zero, the lowest threshold which has been counted is used. If the collection
+// is empty, items from every collection are counted.
+type TimeSeriesFilter struct {
+	ReferenceID  string
+	Threshold    float64
+	CollectionID string
+	Decades      bool // Count by decade rather than by year
+}
+
+// PassageSignature is the MinHash signature of a passage on a page of an item,
+// which is used to find passages reprinted in other items. The bands are hashes
+// of groups of values in the signature: passages which share a band are
+// candidates for being near-duplicates. Offsets are counted in characters from
+// the start of the page's plain text.
+type PassageSignature struct {
+	JobID     uuid.UUID
+	ItemID    string
+	Page      int
+	Passage   int
+	StartChar int
+	EndChar   int
+	MinHash   []uint64
+	Bands     []uint64
+}
+
+// PassageCandidate is a passage in another item which shares at least one band
+// with a passage in an item.
+type PassageCandidate struct {
+	Page    int
+	Passage int
+	Other   *PassageSignature
+}
+
+// DuplicatePassage is a pair of passages in different items which are
+// near-duplicates, such as a sermon reprinted in two newspapers.
+type DuplicatePassage struct {
+	JobID        uuid.UUID
+	ItemID       string
+	Page         int
+	Passage      int
+	OtherItemID  string
+	OtherPage    int
+	OtherPassage int
+	Similarity   float64
+}
+
+// Labels which reviewers can give to a predicted quotation.
+const (
+	LabelQuotation     = "quotation"
+	LabelFalsePositive = "false_positive"
+	LabelAllusion      = "allusion"
+)
+
+// QuotationLabel is a reviewer's judgment of whether a predicted quotation is
+// really a quotation of the verse. It records the probability and passage that
+// the reviewer saw, since the quotation may be predicted again by a new model.
+type QuotationLabel struct {
+	ItemID      string
+	ReferenceID string
+	VerseID     string
+	Reviewer    string
+	Label       string
+	Note        string
+	Probability float64
+	Passage     *QuotedPassage
+}
+
+// NewQuotationLabel creates a label for a quotation
+func NewQuotationLabel(q *Quotation, reviewer, label, note string) *QuotationLabel {
+	return &QuotationLabel{
+		ItemID:      q.ItemID,
+		ReferenceID: q.ReferenceID,
+		VerseID:     q.VerseID,
+		Reviewer:    reviewer,
+		Label:       label,
+		Note:        note,
+		Probability: q.Probability,
+		Passage:     q.Passage,
+	}
+}
 
+// VerseMapping maps a verse in a translation to the canonical reference for the
+// verse, for translations which number some verses differently or name books
+// in other languages.
+type VerseMapping struct {
+	Translation          string
+	ReferenceID          string
+	CanonicalReferenceID string
 }
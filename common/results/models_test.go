@@ -0,0 +1,21 @@
+package results
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotedPassageMatch(t *testing.T) {
+	p := &QuotedPassage{
+		StartChar:    16,
+		EndChar:      32,
+		Context:      "Prédication: For God so loved",
+		ContextStart: 3,
+	}
+	assert.Equal(t, "For God so loved", p.Match())
+
+	// Offsets which fall outside the context have no match
+	p.EndChar = 100
+	assert.Empty(t, p.Match())
+}
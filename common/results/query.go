@@ -0,0 +1,42 @@
+package results
+
+import (
+	"fmt"
+	"strings"
+)
+
+// conditions builds the WHERE clause of a query from optional filters,
+// numbering the query's parameters in the order the filters are added.
+type conditions struct {
+	clauses []string
+	args    []interface{}
+}
+
+// add adds a condition to the query. The ? in the clause is replaced with the
+// parameter for the argument.
+func (c *conditions) add(clause string, arg interface{}) {
+	c.args = append(c.args, arg)
+	c.clauses = append(c.clauses, strings.Replace(clause, "?", c.param(), 1))
+}
+
+// param returns the placeholder for the most recently added argument.
+func (c *conditions) param() string {
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+// where returns the WHERE clause, or an empty string if there are no conditions.
+func (c *conditions) where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(c.clauses, " AND ")
+}
+
+// page adds the limit and offset to the arguments and returns the LIMIT and
+// OFFSET clauses.
+func (c *conditions) page(limit, offset int) string {
+	c.args = append(c.args, limit)
+	l := c.param()
+	c.args = append(c.args, offset)
+	return fmt.Sprintf("LIMIT %s OFFSET %s", l, c.param())
+}
@@ -0,0 +1,20 @@
+package results
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditions(t *testing.T) {
+	c := &conditions{}
+	assert.Equal(t, "", c.where())
+	assert.Equal(t, "LIMIT $1 OFFSET $2", c.page(10, 20))
+
+	c = &conditions{}
+	c.add("q.verse_id = ?", "Genesis 1:1 (KJV)")
+	c.add("i.year >= ?", 1850)
+	assert.Equal(t, "WHERE q.verse_id = $1 AND i.year >= $2", c.where())
+	assert.Equal(t, "LIMIT $3 OFFSET $4", c.page(100, 0))
+	assert.Equal(t, []interface{}{"Genesis 1:1 (KJV)", 1850, 100, 0}, c.args)
+}
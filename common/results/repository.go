@@ -9,10 +9,33 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 )
 
 // Repository is an interface describing a data store
 type Repository interface {
+	WithTx(tx pgx.Tx) Repository
 	SaveQuotation(ctx context.Context, q *Quotation) error
+	SaveCorpusQuotation(ctx context.Context, q *CorpusQuotation) error
 	SaveLanguages(ctx context.Context, jobID uuid.UUID, itemID string, languages map[string]int) error
+	SaveLanguageSpans(ctx context.Context, spans []*LanguageSpan) error
+	SaveCheckpointSpans(ctx context.Context, spans []*LanguageSpan) error
+	MoveCheckpointSpans(ctx context.Context, jobID uuid.UUID) error
+	SaveLanguageClassification(ctx context.Context, c *LanguageClassification) error
+	Quotations(ctx context.Context, f QuotationFilter) ([]*Quotation, error)
+	LanguageStats(ctx context.Context, f LanguageFilter) ([]*LanguageStat, error)
+	LanguageTotals(ctx context.Context, destination string) ([]*LanguageTotal, error)
+	RefreshQuotationCounts(ctx context.Context, destination string) (int, error)
+	ResetQuotationCounts(ctx context.Context, threshold float64) error
+	CountedDestination(ctx context.Context) (string, error)
+	RemoveQuotationThreshold(ctx context.Context, threshold float64) error
+	QuotationTimeSeries(ctx context.Context, f TimeSeriesFilter) ([]*QuotationCount, error)
+	SavePassageSignatures(ctx context.Context, sigs []*PassageSignature) error
+	PassageCandidates(ctx context.Context, jobID uuid.UUID, itemID string) ([]*PassageCandidate, error)
+	SaveDuplicatePassages(ctx context.Context, dups []*DuplicatePassage) error
+	ClusterItems(ctx context.Context, itemID string, duplicates []string) (string, error)
+	SampleForReview(ctx context.Context, band *ReviewBand, n int, reference string) ([]*Quotation, error)
+	SaveLabel(ctx context.Context, l *QuotationLabel) error
+	ReviewPrecision(ctx context.Context, bands []*ReviewBand) error
+	SaveVersification(ctx context.Context, mappings []*VerseMapping, replace bool) error
 }
@@ -2,14 +2,18 @@ package results
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/lmullen/cchc/common/db"
 )
 
 // Repo is a data store using PostgreSQL with the pgx native interface.
 type Repo struct {
-	db *pgxpool.Pool
+	db db.Conn
 }
 
 // NewRepo returns an item repo using PostgreSQL with the pgx native interface.
@@ -19,14 +23,35 @@ func NewRepo(db *pgxpool.Pool) *Repo {
 	}
 }
 
+// WithTx returns a repo which does its work in the transaction, so that it is
+// committed or rolled back along with the rest of the transaction.
+func (r *Repo) WithTx(tx pgx.Tx) Repository {
+	return &Repo{
+		db: tx,
+	}
+}
+
 // SaveQuotation serializes a job to the database
 func (r *Repo) SaveQuotation(ctx context.Context, q *Quotation) error {
 	query := `
-	INSERT INTO results.biblical_quotations (job_id, item_id, reference_id, verse_id, probability)
-	VALUES ($1, $2, $3, $4, $5);
+	INSERT INTO results.biblical_quotations
+		(job_id, item_id, reference_id, verse_id, probability, translation,
+		 page, start_char, end_char, context, context_start)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);
 	`
 
-	_, err := r.db.Exec(ctx, query, q.JobID, q.ItemID, q.ReferenceID, q.VerseID, q.Probability)
+	var page, start, end, contextStart sql.NullInt32
+	var text sql.NullString
+	if p := q.Passage; p != nil {
+		page = sql.NullInt32{Int32: int32(p.Page), Valid: true}
+		start = sql.NullInt32{Int32: int32(p.StartChar), Valid: true}
+		end = sql.NullInt32{Int32: int32(p.EndChar), Valid: true}
+		text = sql.NullString{String: p.Context, Valid: true}
+		contextStart = sql.NullInt32{Int32: int32(p.ContextStart), Valid: true}
+	}
+
+	_, err := r.db.Exec(ctx, query, q.JobID, q.ItemID, q.ReferenceID, q.VerseID, q.Probability,
+		q.Translation, page, start, end, text, contextStart)
 	if err != nil {
 		return err
 	}
@@ -35,6 +60,31 @@ func (r *Repo) SaveQuotation(ctx context.Context, q *Quotation) error {
 
 }
 
+// SaveCorpusQuotation serializes a quotation of a passage in a reference corpus
+// to the database
+func (r *Repo) SaveCorpusQuotation(ctx context.Context, q *CorpusQuotation) error {
+	query := `
+	INSERT INTO results.quotations
+		(job_id, item_id, corpus_id, passage_id, probability,
+		 page, start_char, end_char, context, context_start)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+	`
+
+	var page, start, end, contextStart sql.NullInt32
+	var text sql.NullString
+	if p := q.Passage; p != nil {
+		page = sql.NullInt32{Int32: int32(p.Page), Valid: true}
+		start = sql.NullInt32{Int32: int32(p.StartChar), Valid: true}
+		end = sql.NullInt32{Int32: int32(p.EndChar), Valid: true}
+		text = sql.NullString{String: p.Context, Valid: true}
+		contextStart = sql.NullInt32{Int32: int32(p.ContextStart), Valid: true}
+	}
+
+	_, err := r.db.Exec(ctx, query, q.JobID, q.ItemID, q.CorpusID, q.PassageID, q.Probability,
+		page, start, end, text, contextStart)
+	return err
+}
+
 // SaveLanguages serializes the results of calculating languages to the database.
 func (r *Repo) SaveLanguages(ctx context.Context, jobID uuid.UUID, itemID string, languages map[string]int) error {
 
@@ -63,3 +113,266 @@ func (r *Repo) SaveLanguages(ctx context.Context, jobID uuid.UUID, itemID string
 	return nil
 
 }
+
+// SaveLanguageSpans serializes the runs of sentences in a single language to
+// the database.
+func (r *Repo) SaveLanguageSpans(ctx context.Context, spans []*LanguageSpan) error {
+
+	insert := `
+			INSERT INTO results.language_spans 
+				(job_id, item_id, page, start_char, end_char, sentences, lang, confidence)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+		`
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Roll back the transaction if something goes wrong
+
+	for _, s := range spans {
+		_, err := tx.Exec(ctx, insert, s.JobID, s.ItemID, s.Page, s.StartChar,
+			s.EndChar, s.Sentences, s.Language, s.Confidence)
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+
+}
+
+// SaveCheckpointSpans saves language spans from a job which has not finished
+// along with its checkpoint. They are not results until the job finishes and
+// they are moved with MoveCheckpointSpans, and they are deleted with the
+// checkpoint.
+func (r *Repo) SaveCheckpointSpans(ctx context.Context, spans []*LanguageSpan) error {
+	insert := `
+	INSERT INTO jobs.checkpoint_spans
+		(job_id, item_id, page, start_char, end_char, sentences, lang, confidence)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+	`
+
+	for _, s := range spans {
+		_, err := r.db.Exec(ctx, insert, s.JobID, s.ItemID, s.Page, s.StartChar,
+			s.EndChar, s.Sentences, s.Language, s.Confidence)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MoveCheckpointSpans moves the language spans saved with a job's checkpoint
+// into the results. It should be called in the transaction which finishes the
+// job, before the checkpoint is deleted.
+func (r *Repo) MoveCheckpointSpans(ctx context.Context, jobID uuid.UUID) error {
+	query := `
+	WITH moved AS (
+		DELETE FROM jobs.checkpoint_spans WHERE job_id = $1
+		RETURNING job_id, item_id, page, start_char, end_char, sentences, lang, confidence
+	)
+	INSERT INTO results.language_spans
+		(job_id, item_id, page, start_char, end_char, sentences, lang, confidence)
+	SELECT * FROM moved;
+	`
+	_, err := r.db.Exec(ctx, query, jobID)
+	return err
+}
+
+// SaveLanguageClassification serializes the item-level classification of
+// languages to the database.
+func (r *Repo) SaveLanguageClassification(ctx context.Context, c *LanguageClassification) error {
+	query := `
+	INSERT INTO results.language_classification 
+		(job_id, item_id, primary_lang, secondary_langs, multilingual, diversity, 
+		 sentences, catalog_agreement)
+	VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, NULLIF($8, ''));
+	`
+
+	_, err := r.db.Exec(ctx, query, c.JobID, c.ItemID, c.PrimaryLanguage,
+		c.SecondaryLanguages, c.Multilingual, c.Diversity, c.Sentences, c.CatalogAgreement)
+	if err != nil {
+		return err
+	}
+
+	return nil
+
+}
+
+// Quotations gets a page of quotations which match the filter, with the most
+// probable quotations first. Quotations from books in the Stacks are included
+// unless the filter asks for a single source.
+func (r *Repo) Quotations(ctx context.Context, f QuotationFilter) ([]*Quotation, error) {
+	c := &conditions{}
+	if f.ReferenceID != "" {
+		c.add("q.canonical_reference_id = ?", f.ReferenceID)
+	}
+	if f.VerseID != "" {
+		c.add("q.verse_id = ?", f.VerseID)
+	}
+	if f.Translation != "" {
+		c.add("q.translation = ?", f.Translation)
+	}
+	if f.ItemID != "" {
+		c.add("q.item_id = ?", f.ItemID)
+	}
+	if f.Source != "" {
+		c.add("j.source = ?", f.Source)
+	}
+	if f.YearFrom != 0 {
+		c.add("d.year >= ?", f.YearFrom)
+	}
+	if f.YearTo != 0 {
+		c.add("d.year <= ?", f.YearTo)
+	}
+	if f.MinProbability != 0 {
+		c.add("q.probability >= ?", f.MinProbability)
+	}
+
+	table := "results.biblical_quotations_canonical"
+	if f.Deduplicate {
+		table = "results.biblical_quotations_deduplicated"
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s q
+	JOIN jobs.fulltext j ON j.id = q.job_id
+	LEFT JOIN documents d ON d.source = j.source AND d.id = q.item_id
+	%s
+	ORDER BY q.probability DESC, q.item_id, q.verse_id
+	%s;
+	`, quotationColumns, table, c.where(), c.page(f.Limit, f.Offset))
+
+	rows, err := r.db.Query(ctx, query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotations []*Quotation
+	for rows.Next() {
+		q, err := scanQuotation(rows)
+		if err != nil {
+			return nil, err
+		}
+		quotations = append(quotations, q)
+	}
+
+	return quotations, rows.Err()
+}
+
+// quotationColumns are the columns of a quotation in the order read by
+// scanQuotation, from a table aliased as q.
+const quotationColumns = `q.job_id, q.item_id, q.reference_id, q.verse_id, q.probability,
+		q.translation, q.page, q.start_char, q.end_char, q.context, q.context_start`
+
+// scanQuotation reads a quotation, along with its passage if it was recorded.
+func scanQuotation(rows pgx.Rows) (*Quotation, error) {
+	q := Quotation{}
+	var page, start, end, contextStart sql.NullInt32
+	var text sql.NullString
+	err := rows.Scan(&q.JobID, &q.ItemID, &q.ReferenceID, &q.VerseID, &q.Probability,
+		&q.Translation, &page, &start, &end, &text, &contextStart)
+	if err != nil {
+		return nil, err
+	}
+	if page.Valid {
+		q.Passage = &QuotedPassage{
+			Page:         int(page.Int32),
+			StartChar:    int(start.Int32),
+			EndChar:      int(end.Int32),
+			Context:      text.String,
+			ContextStart: int(contextStart.Int32),
+		}
+	}
+	return &q, nil
+}
+
+// LanguageStats gets a page of the number of sentences in each language in
+// each item which match the filter, with the largest counts first. Only the
+// results from jobs for one destination are returned.
+func (r *Repo) LanguageStats(ctx context.Context, f LanguageFilter) ([]*LanguageStat, error) {
+	c := &conditions{}
+	destination := f.Destination
+	if destination == "" {
+		destination = LanguageDestination
+	}
+	c.add("j.destination = ?", destination)
+	if f.ItemID != "" {
+		c.add("l.item_id = ?", f.ItemID)
+	}
+	if f.Language != "" {
+		c.add("l.lang = ?", f.Language)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT l.item_id, l.lang, COALESCE(l.sentences, 0)
+	FROM results.languages l
+	JOIN jobs.fulltext j ON j.id = l.job_id
+	%s
+	ORDER BY l.sentences DESC NULLS LAST, l.item_id, l.lang
+	%s;
+	`, c.where(), c.page(f.Limit, f.Offset))
+
+	rows, err := r.db.Query(ctx, query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*LanguageStat
+	for rows.Next() {
+		s := LanguageStat{}
+		err = rows.Scan(&s.ItemID, &s.Language, &s.Sentences)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, &s)
+	}
+
+	return stats, rows.Err()
+}
+
+// LanguageTotals gets the number of items and sentences in each language
+// across every item, with the most common languages first. Only the results
+// from jobs for the destination are counted, or for LanguageDestination if it
+// is empty, so that items processed with several settings aren't counted more
+// than once.
+func (r *Repo) LanguageTotals(ctx context.Context, destination string) ([]*LanguageTotal, error) {
+	if destination == "" {
+		destination = LanguageDestination
+	}
+	query := `
+	SELECT l.lang, COUNT(DISTINCT l.item_id), COALESCE(SUM(l.sentences), 0)
+	FROM results.languages l
+	JOIN jobs.fulltext j ON j.id = l.job_id
+	WHERE j.destination = $1
+	GROUP BY l.lang
+	ORDER BY 3 DESC, l.lang;
+	`
+
+	rows, err := r.db.Query(ctx, query, destination)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []*LanguageTotal
+	for rows.Next() {
+		t := LanguageTotal{}
+		err = rows.Scan(&t.Language, &t.Items, &t.Sentences)
+		if err != nil {
+			return nil, err
+		}
+		totals = append(totals, &t)
+	}
+
+	return totals, rows.Err()
+}
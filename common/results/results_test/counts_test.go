@@ -0,0 +1,152 @@
+package results_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+
+	"github.com/lmullen/cchc/common/db"
+	"github.com/lmullen/cchc/common/jobs"
+	"github.com/lmullen/cchc/common/results"
+	"github.com/orlangure/gnomock"
+	"github.com/orlangure/gnomock/preset/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotationCountsDB(t *testing.T) {
+	t.Parallel()
+
+	user := "gnomock"
+	pass := "strong-passwords-are-the-best"
+	dbname := "cchc_gnomock_test_results"
+
+	p := postgres.Preset(
+		postgres.WithUser(user, pass),
+		postgres.WithDatabase(dbname),
+		postgres.WithVersion("14"),
+	)
+
+	container, err := gnomock.Start(p)
+	assert.NoError(t, err)
+	defer func() { assert.NoError(t, gnomock.Stop(container)) }()
+
+	connstr := fmt.Sprintf("postgres://%s:%s@%s:%v/%s?sslmode=disable",
+		user, pass, container.Host, container.DefaultPort(), dbname)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	db, _ := db.Connect(ctx, connstr, "results-test")
+	db.Ping(ctx)
+	m, _ := migrate.New("file://../../db/migrations", connstr)
+	m.Up()
+
+	var repo results.Repository
+	repo = results.NewRepo(db)
+	jobsRepo := jobs.NewJobsRepo(db)
+
+	// An item from 1860 in one collection
+	item := "http://www.loc.gov/item/mal1285100/"
+	collection := "http://www.loc.gov/collections/abraham-lincoln-papers/"
+	_, err = db.Exec(ctx, `INSERT INTO items (id, year) VALUES ($1, 1860);`, item)
+	require.NoError(t, err)
+	_, err = db.Exec(ctx, `INSERT INTO collections (id) VALUES ($1);`, collection)
+	require.NoError(t, err)
+	_, err = db.Exec(ctx, `INSERT INTO items_in_collections (item_id, collection_id) VALUES ($1, $2);`, item, collection)
+	require.NoError(t, err)
+
+	job := jobs.NewFullText(item, "quotations")
+	job.Start()
+	job.Finish()
+	require.NoError(t, jobsRepo.SaveFullText(ctx, job))
+
+	// The verse is quoted on the first page in two translations, and again on
+	// the second page. The predictor keeps the best match for a verse on each
+	// page, so the item has a quotation of the verse for each page.
+	quotations := []*results.Quotation{
+		{Translation: "kjv", VerseID: "John 3:16 (KJV)", Passage: &results.QuotedPassage{Page: 0}},
+		{Translation: "douay", VerseID: "John 3:16 (Douay)", Passage: &results.QuotedPassage{Page: 0}},
+		{Translation: "kjv", VerseID: "John 3:16 (KJV)", Passage: &results.QuotedPassage{Page: 1}},
+	}
+	for _, q := range quotations {
+		q.JobID = job.ID
+		q.ItemID = item
+		q.ReferenceID = "John 3:16"
+		q.Probability = 0.9
+		require.NoError(t, repo.SaveQuotation(ctx, q))
+	}
+
+	kjv, err := repo.Quotations(ctx, results.QuotationFilter{ItemID: item, Translation: "kjv"})
+	require.NoError(t, err)
+	require.Len(t, kjv, 2)
+
+	counts := func() []*results.QuotationCount {
+		c, err := repo.QuotationTimeSeries(ctx, results.TimeSeriesFilter{ReferenceID: "John 3:16", CollectionID: collection})
+		require.NoError(t, err)
+		return c
+	}
+
+	n, err := repo.RefreshQuotationCounts(ctx, "quotations")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	first := counts()
+	require.Len(t, first, 1)
+	assert.Equal(t, 1860, first[0].Year)
+	assert.Equal(t, 1, first[0].Items)
+	assert.Equal(t, 2, first[0].Pages)
+	assert.Equal(t, 1, first[0].ItemsCounted)
+
+	// Refreshing again doesn't count the job twice
+	n, err = repo.RefreshQuotationCounts(ctx, "quotations")
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, first, counts())
+
+	// Removing the job from the counts and refreshing counts it again, with the
+	// same result
+	tx, err := db.Begin(ctx)
+	require.NoError(t, err)
+	require.NoError(t, results.UncountJobs(ctx, tx, []uuid.UUID{job.ID}))
+	require.NoError(t, tx.Commit(ctx))
+	assert.Empty(t, counts())
+
+	n, err = repo.RefreshQuotationCounts(ctx, "quotations")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, first, counts())
+
+	// Counting the jobs for other reference texts replaces the jobs for the old
+	// ones, so that the item is only counted once
+	other := jobs.NewFullText(item, "quotations-0a1b2c3d")
+	other.Start()
+	other.Finish()
+	require.NoError(t, jobsRepo.SaveFullText(ctx, other))
+	require.NoError(t, repo.SaveQuotation(ctx, &results.Quotation{
+		JobID: other.ID, ItemID: item, ReferenceID: "John 3:16", Translation: "luther",
+		VerseID: "Johannes 3:16 (Luther)", Probability: 0.9, Passage: &results.QuotedPassage{Page: 3},
+	}))
+
+	n, err = repo.RefreshQuotationCounts(ctx, "quotations-0a1b2c3d")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	replaced := counts()
+	require.Len(t, replaced, 1)
+	assert.Equal(t, 1, replaced[0].Items)
+	assert.Equal(t, 1, replaced[0].Pages)
+	assert.Equal(t, 1, replaced[0].ItemsCounted)
+	destination, err := repo.CountedDestination(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "quotations-0a1b2c3d", destination)
+
+	n, err = repo.RefreshQuotationCounts(ctx, "quotations")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, first, counts())
+}
@@ -0,0 +1,97 @@
+package results
+
+import (
+	"fmt"
+	"math"
+)
+
+// ReviewBand is a range of probabilities, along with the number of reviewed
+// quotations in the range with each label. The range includes the lower bound
+// but not the upper bound, except for the highest band, which includes both.
+type ReviewBand struct {
+	Lower          float64
+	Upper          float64
+	Quotations     int
+	Allusions      int
+	FalsePositives int
+	last           bool
+}
+
+// NewReviewBands divides probabilities into bands at the boundaries, which
+// must be increasing. For example, boundaries of 0.57, 0.7, and 1 make two
+// bands.
+func NewReviewBands(boundaries []float64) ([]*ReviewBand, error) {
+	if len(boundaries) < 2 {
+		return nil, fmt.Errorf("at least two boundaries are needed to make a band")
+	}
+	bands := make([]*ReviewBand, 0, len(boundaries)-1)
+	for i := 1; i < len(boundaries); i++ {
+		if boundaries[i] <= boundaries[i-1] {
+			return nil, fmt.Errorf("the boundaries of the bands must be increasing")
+		}
+		bands = append(bands, &ReviewBand{Lower: boundaries[i-1], Upper: boundaries[i]})
+	}
+	bands[len(bands)-1].last = true
+	return bands, nil
+}
+
+// Contains reports whether a probability is in the band.
+func (b *ReviewBand) Contains(p float64) bool {
+	return p >= b.Lower && (p < b.Upper || (b.last && p == b.Upper))
+}
+
+// Count adds a label to the band.
+func (b *ReviewBand) Count(label string) {
+	switch label {
+	case LabelQuotation:
+		b.Quotations++
+	case LabelAllusion:
+		b.Allusions++
+	case LabelFalsePositive:
+		b.FalsePositives++
+	}
+}
+
+// Reviewed is the number of quotations in the band which have been reviewed.
+func (b *ReviewBand) Reviewed() int {
+	return b.Quotations + b.Allusions + b.FalsePositives
+}
+
+// Precision is the share of reviewed quotations in the band which really are
+// quotations. If allusions is true, allusions count as quotations. It is NaN if
+// no quotations in the band have been reviewed.
+func (b *ReviewBand) Precision(allusions bool) float64 {
+	if b.Reviewed() == 0 {
+		return math.NaN()
+	}
+	return float64(b.hits(allusions)) / float64(b.Reviewed())
+}
+
+// Interval is the 95% Wilson score interval for the precision, which behaves
+// sensibly for the small samples and extreme proportions typical of review.
+func (b *ReviewBand) Interval(allusions bool) (float64, float64) {
+	n := float64(b.Reviewed())
+	if n == 0 {
+		return math.NaN(), math.NaN()
+	}
+	const z = 1.96
+	p := float64(b.hits(allusions)) / n
+	center := (p + z*z/(2*n)) / (1 + z*z/n)
+	margin := z / (1 + z*z/n) * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+func (b *ReviewBand) hits(allusions bool) int {
+	if allusions {
+		return b.Quotations + b.Allusions
+	}
+	return b.Quotations
+}
+
+// String describes the range of the band.
+func (b *ReviewBand) String() string {
+	if b.last {
+		return fmt.Sprintf("%v–%v", b.Lower, b.Upper)
+	}
+	return fmt.Sprintf("%v–<%v", b.Lower, b.Upper)
+}
@@ -0,0 +1,126 @@
+package results
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SampleForReview gets a random sample of up to n quotations in a band of
+// probabilities which no one has reviewed yet. If the reference is not empty,
+// only quotations of that verse are sampled.
+func (r *Repo) SampleForReview(ctx context.Context, band *ReviewBand, n int, reference string) ([]*Quotation, error) {
+	c := &conditions{}
+	c.add("q.probability::numeric >= ?", band.Lower)
+	if band.last {
+		c.add("q.probability::numeric <= ?", band.Upper)
+	} else {
+		c.add("q.probability::numeric < ?", band.Upper)
+	}
+	if reference != "" {
+		c.add("q.reference_id = ?", reference)
+	}
+	c.clauses = append(c.clauses, `NOT EXISTS (SELECT 1 FROM results.quotation_labels l
+		WHERE l.item_id = q.item_id AND l.verse_id = q.verse_id)`)
+
+	// An item may have the same quotation more than once, so keep only one
+	// before sampling
+	query := fmt.Sprintf(`
+	SELECT %s FROM (
+		SELECT DISTINCT ON (q.item_id, q.verse_id) q.*
+		FROM results.biblical_quotations q
+		%s
+		ORDER BY q.item_id, q.verse_id, q.page NULLS LAST
+	) q
+	ORDER BY random()
+	%s;
+	`, quotationColumns, c.where(), c.page(n, 0))
+
+	rows, err := r.db.Query(ctx, query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotations []*Quotation
+	for rows.Next() {
+		q, err := scanQuotation(rows)
+		if err != nil {
+			return nil, err
+		}
+		quotations = append(quotations, q)
+	}
+
+	return quotations, rows.Err()
+}
+
+// SaveLabel serializes a reviewer's label to the database. If the reviewer has
+// already labeled the quotation, the new label replaces the old one.
+func (r *Repo) SaveLabel(ctx context.Context, l *QuotationLabel) error {
+	query := `
+	INSERT INTO results.quotation_labels
+		(item_id, reference_id, verse_id, reviewer, label, note, probability,
+		 page, start_char, end_char, context, context_start)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	ON CONFLICT (item_id, verse_id, reviewer) DO UPDATE SET
+		label = EXCLUDED.label,
+		note = EXCLUDED.note,
+		probability = EXCLUDED.probability,
+		page = EXCLUDED.page,
+		start_char = EXCLUDED.start_char,
+		end_char = EXCLUDED.end_char,
+		context = EXCLUDED.context,
+		context_start = EXCLUDED.context_start,
+		reviewed = NOW();
+	`
+
+	var page, start, end, contextStart sql.NullInt32
+	var text, note sql.NullString
+	if p := l.Passage; p != nil {
+		page = sql.NullInt32{Int32: int32(p.Page), Valid: true}
+		start = sql.NullInt32{Int32: int32(p.StartChar), Valid: true}
+		end = sql.NullInt32{Int32: int32(p.EndChar), Valid: true}
+		text = sql.NullString{String: p.Context, Valid: true}
+		contextStart = sql.NullInt32{Int32: int32(p.ContextStart), Valid: true}
+	}
+	if l.Note != "" {
+		note = sql.NullString{String: l.Note, Valid: true}
+	}
+
+	_, err := r.db.Exec(ctx, query, l.ItemID, l.ReferenceID, l.VerseID, l.Reviewer, l.Label, note,
+		l.Probability, page, start, end, text, contextStart)
+	return err
+}
+
+// ReviewPrecision counts the labels in each band of probabilities. When more
+// than one reviewer has labeled a quotation, the most recent label is used.
+func (r *Repo) ReviewPrecision(ctx context.Context, bands []*ReviewBand) error {
+	query := `
+	SELECT DISTINCT ON (item_id, verse_id) probability::numeric::double precision, label
+	FROM results.quotation_labels
+	ORDER BY item_id, verse_id, reviewed DESC;
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p float64
+		var label string
+		err = rows.Scan(&p, &label)
+		if err != nil {
+			return err
+		}
+		for _, b := range bands {
+			if b.Contains(p) {
+				b.Count(label)
+				break
+			}
+		}
+	}
+
+	return rows.Err()
+}
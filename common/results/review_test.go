@@ -0,0 +1,48 @@
+package results
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviewBands(t *testing.T) {
+	bands, err := NewReviewBands([]float64{0.57, 0.7, 1})
+	require.NoError(t, err)
+	require.Len(t, bands, 2)
+
+	assert.True(t, bands[0].Contains(0.57))
+	assert.False(t, bands[0].Contains(0.7))
+	assert.True(t, bands[1].Contains(0.7))
+	assert.True(t, bands[1].Contains(1), "the highest band includes its upper bound")
+	assert.False(t, bands[0].Contains(0.5))
+
+	_, err = NewReviewBands([]float64{0.57})
+	assert.Error(t, err)
+	_, err = NewReviewBands([]float64{0.9, 0.7})
+	assert.Error(t, err)
+}
+
+func TestReviewBandPrecision(t *testing.T) {
+	b := &ReviewBand{Lower: 0.57, Upper: 0.7}
+	assert.True(t, math.IsNaN(b.Precision(false)))
+
+	for _, l := range []string{LabelQuotation, LabelQuotation, LabelQuotation, LabelAllusion, LabelFalsePositive} {
+		b.Count(l)
+	}
+	assert.Equal(t, 5, b.Reviewed())
+	assert.InDelta(t, 0.6, b.Precision(false), 1e-9)
+	assert.InDelta(t, 0.8, b.Precision(true), 1e-9)
+
+	lo, hi := b.Interval(false)
+	assert.InDelta(t, 0.231, lo, 0.001)
+	assert.InDelta(t, 0.882, hi, 0.001)
+
+	// The interval stays within 0 and 1 even when every label agrees
+	b = &ReviewBand{Quotations: 10}
+	lo, hi = b.Interval(false)
+	assert.Less(t, lo, 1.0)
+	assert.Equal(t, 1.0, hi)
+}
@@ -0,0 +1,63 @@
+package results
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// SaveVersification serializes verse mappings to the database. If replace is
+// true, the existing mappings for each translation in the new mappings are
+// removed first. Since quotations are counted by their canonical reference,
+// the quotation counts should be reset after the mappings change.
+func (r *Repo) SaveVersification(ctx context.Context, mappings []*VerseMapping, replace bool) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Roll back the transaction if something goes wrong
+
+	if replace {
+		translations := make(map[string]bool)
+		var ids []string
+		for _, m := range mappings {
+			if !translations[m.Translation] {
+				translations[m.Translation] = true
+				ids = append(ids, m.Translation)
+			}
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM results.versification WHERE translation = ANY($1);`, ids)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Mappings might already exist, so copy into a temporary table first
+	_, err = tx.Exec(ctx, `
+	CREATE TEMPORARY TABLE new_versification
+	(LIKE results.versification) ON COMMIT DROP;
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"new_versification"},
+		[]string{"translation", "reference_id", "canonical_reference_id"},
+		pgx.CopyFromSlice(len(mappings), func(i int) ([]interface{}, error) {
+			m := mappings[i]
+			return []interface{}{m.Translation, m.ReferenceID, m.CanonicalReferenceID}, nil
+		}))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+	INSERT INTO results.versification
+	SELECT DISTINCT * FROM new_versification
+	ON CONFLICT DO NOTHING;
+	`)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,98 @@
+// Package sources lets the destinations process documents from any of the
+// sources of full text: the loc.gov items, or the books imported from the
+// Stacks. A job records the source of its document, and the document's ID
+// within that source.
+package sources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/lmullen/cchc/common/items"
+	"github.com/lmullen/cchc/common/stacks"
+)
+
+// The sources of documents
+const (
+	Items  = "items"  // Items from the loc.gov API, in the items table
+	Stacks = "stacks" // Books imported from the Stacks, in the stacks_books table
+)
+
+// All are the sources of documents, in the order in which jobs are created
+// for them by default.
+var All = []string{Items, Stacks}
+
+// Document is a document with full text which a destination can process.
+type Document interface {
+	FullText() ([]items.PlainText, bool)
+	CatalogLanguages() []string
+}
+
+// Repository is an interface describing a data store for documents from any
+// of the sources.
+type Repository interface {
+	Get(ctx context.Context, source, ID string) (Document, error)
+}
+
+// Valid checks whether a source is one of the sources of documents.
+func Valid(source string) bool {
+	for _, s := range All {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse reads a comma-separated list of sources, such as "items,stacks".
+func Parse(s string) ([]string, error) {
+	var out []string
+	seen := make(map[string]bool)
+	for _, source := range strings.Split(s, ",") {
+		source = strings.ToLower(strings.TrimSpace(source))
+		if !Valid(source) {
+			return nil, fmt.Errorf("the source must be one of %s, not %q", strings.Join(All, ", "), source)
+		}
+		if !seen[source] {
+			out = append(out, source)
+			seen[source] = true
+		}
+	}
+	return out, nil
+}
+
+// Repo fetches documents from each of the sources.
+type Repo struct {
+	items  items.Repository
+	stacks stacks.Repository
+}
+
+// NewSourcesRepo returns a repo which fetches documents from each of the
+// sources using PostgreSQL with the pgx native interface.
+func NewSourcesRepo(db *pgxpool.Pool) *Repo {
+	return &Repo{
+		items:  items.NewItemRepo(db),
+		stacks: stacks.NewStacksRepo(db),
+	}
+}
+
+// Get fetches a document by its source and its ID within that source.
+func (r *Repo) Get(ctx context.Context, source, ID string) (Document, error) {
+	switch source {
+	case Items:
+		item, err := r.items.Get(ctx, ID)
+		if err != nil {
+			return nil, err
+		}
+		return item, nil
+	case Stacks:
+		book, err := r.stacks.Get(ctx, ID)
+		if err != nil {
+			return nil, err
+		}
+		return book, nil
+	}
+	return nil, fmt.Errorf("unknown source of documents %q", source)
+}
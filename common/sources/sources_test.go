@@ -0,0 +1,24 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	got, err := Parse("items")
+	require.NoError(t, err)
+	assert.Equal(t, []string{Items}, got)
+
+	got, err = Parse(" Stacks, items,stacks ")
+	require.NoError(t, err)
+	assert.Equal(t, []string{Stacks, Items}, got)
+
+	_, err = Parse("items,newspapers")
+	assert.Error(t, err)
+
+	_, err = Parse("")
+	assert.Error(t, err)
+}
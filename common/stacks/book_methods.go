@@ -0,0 +1,25 @@
+package stacks
+
+import (
+	"strings"
+
+	"github.com/lmullen/cchc/common/items"
+)
+
+func (b Book) String() string {
+	return b.LCCN
+}
+
+// FullText returns the full text of a book. The Stacks export has the text of a
+// book as a single string, so the book is a single page.
+func (b *Book) FullText() (text []items.PlainText, has bool) {
+	if !b.Text.Valid || strings.TrimSpace(b.Text.String) == "" {
+		return nil, false
+	}
+	return []items.PlainText{{Text: b.Text.String}}, true
+}
+
+// CatalogLanguages returns the languages of the book in its catalog record.
+func (b *Book) CatalogLanguages() []string {
+	return b.Languages
+}
@@ -0,0 +1,6 @@
+package stacks
+
+import "errors"
+
+// ErrNotFound is returned when a book is not in the data store.
+var ErrNotFound = errors.New("There is no book from the Stacks with that LCCN")
@@ -0,0 +1,22 @@
+package stacks
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Book is a book imported from the Stacks, with its metadata and full text.
+type Book struct {
+	LCCN        string
+	ISBN        []string
+	Title       sql.NullString
+	Publisher   sql.NullString
+	Year        sql.NullInt32
+	SubjectFull []string
+	Subjects    []string
+	Person      []string
+	Languages   []string
+	Text        sql.NullString
+	Created     time.Time
+	Updated     time.Time
+}
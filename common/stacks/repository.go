@@ -0,0 +1,14 @@
+// Package stacks allows for interactions with the books imported from the
+// Library of Congress's Stacks e-book collection.
+//
+// It provides a Repository interface for generalized interactions retrieving
+// books from a data store, as well as a concrete type that implements that
+// interface for a PostgreSQL database using the pgx package.
+package stacks
+
+import "context"
+
+// Repository is an interface describing a data store for books from the Stacks.
+type Repository interface {
+	Get(ctx context.Context, LCCN string) (*Book, error)
+}
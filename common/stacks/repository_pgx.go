@@ -0,0 +1,51 @@
+package stacks
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Repo is a data store using PostgreSQL with the pgx native interface.
+type Repo struct {
+	db *pgxpool.Pool
+}
+
+// NewStacksRepo returns a stacks repo using PostgreSQL with the pgx native
+// interface.
+func NewStacksRepo(db *pgxpool.Pool) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+// Get fetches a book from the database by its LCCN.
+func (r *Repo) Get(ctx context.Context, LCCN string) (*Book, error) {
+	query := `
+	SELECT lccn, isbn, title, publisher, year, subject_full, subject, person,
+		lang, text, created, updated
+	FROM stacks_books
+	WHERE lccn = $1;
+	`
+	b := Book{}
+	var created, updated *time.Time
+	err := r.db.QueryRow(ctx, query, LCCN).Scan(&b.LCCN, &b.ISBN, &b.Title,
+		&b.Publisher, &b.Year, &b.SubjectFull, &b.Subjects, &b.Person,
+		&b.Languages, &b.Text, &created, &updated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if created != nil {
+		b.Created = *created
+	}
+	if updated != nil {
+		b.Updated = *updated
+	}
+	return &b, nil
+}
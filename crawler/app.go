@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
+	"github.com/lmullen/cchc/common/config"
+	cchcdb "github.com/lmullen/cchc/common/db"
+	"github.com/lmullen/cchc/common/health"
+	"github.com/lmullen/cchc/common/metrics"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/hashicorp/go-retryablehttp"
@@ -16,17 +20,13 @@ import (
 	"go.uber.org/ratelimit"
 )
 
-// The Config type stores configuration which is read from environment variables.
-type Config struct {
-	dbstr    string
-	loglevel string
-}
-
 // The App type shares access to the database and other resources.
 type App struct {
 	DB       *sql.DB
-	Config   *Config
+	Config   *config.Config
 	Client   *http.Client
+	Metrics  *http.Server
+	Health   *health.Checker
 	Limiters struct {
 		Newspapers  ratelimit.Limiter
 		Items       ratelimit.Limiter
@@ -36,36 +36,18 @@ type App struct {
 
 // Init creates a new App and connects to the database or returns an error
 func (app *App) Init() error {
-	log.Info("Starting the LOC.gov API crawler")
-
-	app.Config = &Config{}
-
-	// Read the configuration from environment variables.
-	dbstr, ok := os.LookupEnv("CCHC_DBSTR")
-	if !ok {
-		return errors.New("CCHC_DBSTR environment variable is not set")
-	}
-	app.Config.dbstr = dbstr
-
-	ll, ok := os.LookupEnv("CCHC_LOGLEVEL")
-	if !ok {
-		return errors.New("CCHC_LOGLEVEL environment variable is not set")
+	// Read the configuration from a file, environment variables, and flags
+	app.Config = config.Default()
+	err := app.Config.Load("cchc-crawler", os.Args[1:])
+	if err != nil {
+		return err
 	}
-	app.Config.loglevel = ll
-
-	// Set the logging level
-	switch app.Config.loglevel {
-	case "error":
-		log.SetLevel(log.ErrorLevel)
-	case "warn":
-		log.SetLevel(log.WarnLevel)
-	case "info":
-		log.SetLevel(log.InfoLevel)
-	case "debug":
-		log.SetLevel(log.DebugLevel)
-	case "trace":
-		log.SetLevel(log.TraceLevel)
+	err = app.Config.SetupLogging("cchc-crawler")
+	if err != nil {
+		return err
 	}
+	log.Info("Starting the LOC.gov API crawler")
+	app.Config.Log()
 
 	// Set a policy for backoffs
 	policy := backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 10)
@@ -73,7 +55,7 @@ func (app *App) Init() error {
 	// Connect to the database and initialize it.
 	var db *sql.DB
 	dbConnect := func() error {
-		d, err := sql.Open("pgx", app.Config.dbstr)
+		d, err := sql.Open("pgx", app.Config.DBStr)
 		if err != nil {
 			return fmt.Errorf("Failed to dial the database: %w", err)
 		}
@@ -84,7 +66,7 @@ func (app *App) Init() error {
 		return nil
 	}
 	log.Infof("Attempting to connect to the database")
-	err := backoff.Retry(dbConnect, policy)
+	err = backoff.Retry(dbConnect, policy)
 	if err != nil {
 		return fmt.Errorf("Failed to connect to the database: %w", err)
 	}
@@ -92,13 +74,23 @@ func (app *App) Init() error {
 	app.DB = db
 	log.Info("Connected to the database successfully")
 
+	// Make sure the database schema is the version this application expects
+	ctx, cancel := context.WithTimeout(context.Background(), app.Config.DBTimeout)
+	defer cancel()
+	err = cchcdb.CheckSchema(ctx, app.Config.DBStr, app.Config.AutoMigrate)
+	if err != nil {
+		return err
+	}
+	log.WithField("version", cchcdb.RequiredVersion).Info("The database schema is at the required version")
+
 	// Set up a client to use for all HTTP requests. It will automatically retry.
 	rc := retryablehttp.NewClient()
 	rc.RetryWaitMin = 10 * time.Second
 	rc.RetryWaitMax = 2 * time.Minute
 	rc.RetryMax = 6
-	rc.HTTPClient.Timeout = apiTimeout * time.Second
+	rc.HTTPClient.Timeout = app.Config.APITimeout
 	rc.Logger = nil
+	rc.HTTPClient.Transport = metrics.InstrumentTransport(rc.HTTPClient.Transport)
 	// This will log all HTTP requests made, which is not desirable.
 	// rc.RequestLogHook = func(_ retryablehttp.Logger, req *http.Request, attempt int) {
 	// 	log.WithFields(logrus.Fields{
@@ -110,22 +102,34 @@ func (app *App) Init() error {
 
 	// Create rate limiters for different endpoints. Rate limits documentation:
 	// https://www.loc.gov/apis/json-and-yaml/
-	// The subtractions here represent a buffer from the officially presented
-	// rate limits.
-	il := ratelimit.New(200-20, ratelimit.Per(60*time.Second)) // 200 requests/minute
-	app.Limiters.Items = il
-
-	cl := ratelimit.New(80-20, ratelimit.Per(60*time.Second)) // 80 requests/minute
-	app.Limiters.Collections = cl
-
-	nl := ratelimit.New(20-4, ratelimit.Per(10*time.Second)) // 120 requests/minute
-	app.Limiters.Newspapers = nl
+	app.Limiters.Items = metrics.InstrumentLimiter(app.Config.RateLimits.Items.Limiter(), "item")
+	app.Limiters.Collections = metrics.InstrumentLimiter(app.Config.RateLimits.Collections.Limiter(), "collections")
+	app.Limiters.Newspapers = metrics.InstrumentLimiter(app.Config.RateLimits.Newspapers.Limiter(), "newspapers")
+
+	// Serve metrics about the database and the API for Prometheus, along with
+	// health checks. The longest the crawler should wait is the interval
+	// between crawls.
+	err = metrics.RegisterDB(app.DB)
+	if err != nil {
+		return err
+	}
+	app.Health = health.New(app.DB.PingContext, app.Config.ProgressWindow, app.Config.CrawlInterval)
+	mux := http.NewServeMux()
+	app.Health.Register(mux)
+	app.Metrics, err = metrics.Serve(app.Config.MetricsAddr, mux)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
-// Shutdown closes the connection to the database.
+// Shutdown stops serving metrics and closes the connection to the database.
 func (app *App) Shutdown() {
+	app.Health.ShuttingDown()
+	if app.Metrics != nil {
+		app.Metrics.Close()
+	}
 	err := app.DB.Close()
 	if err != nil {
 		log.Error("Failed to close the connection to the database:", err)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/lmullen/cchc/common/logging"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -31,10 +33,12 @@ func collectionPageURL(itemsURL string, page int) string {
 	return u.String()
 }
 
-// FetchCollectionItems gets the items associated with each collection
-func (c Collection) FetchCollectionItems(page int, results chan<- CollectionAPIPage) {
+// FetchCollectionItems gets the items associated with each collection. The
+// context carries the fields for logging.
+func (c Collection) FetchCollectionItems(ctx context.Context, page int, results chan<- CollectionAPIPage) {
 
 	url := collectionPageURL(c.ItemsURL, page)
+	logger := logging.From(ctx).WithField(logging.FieldCollection, c.ID)
 
 	// Skip if it isn't a part of the LOC.gov API
 	if !hasAPI(url) {
@@ -46,15 +50,16 @@ func (c Collection) FetchCollectionItems(page int, results chan<- CollectionAPIP
 fetch:
 	// Limit the rate
 	app.Limiters.Collections.Take()
+	app.Health.Progress()
 
 	response, err := app.Client.Get(url)
 	if err != nil {
-		log.Warn(err)
+		logger.WithField("url", url).Warn(err)
 		return
 	}
 
 	if response.StatusCode != http.StatusOK {
-		log.WithFields(log.Fields{
+		logger.WithFields(log.Fields{
 			"http_error": response.Status,
 			"http_code":  response.StatusCode,
 			"url":        url,
@@ -65,10 +70,10 @@ fetch:
 
 	data, err := io.ReadAll(response.Body)
 	if err != nil {
-		log.WithField("url", url).WithError(err).Warn("Error reading HTTP response body")
+		logger.WithField("url", url).WithError(err).Warn("Error reading HTTP response body")
 		if attempt <= 10 {
 			attempt++
-			log.WithField("url", url).WithField("attempt", attempt).WithError(err).
+			logger.WithField("url", url).WithField("attempt", attempt).WithError(err).
 				Warn("Retrying this page of results because of error")
 			goto fetch
 		} else {
@@ -80,14 +85,14 @@ fetch:
 
 	err = json.Unmarshal(data, &result)
 	if err != nil {
-		log.WithFields(log.Fields{
+		logger.WithFields(log.Fields{
 			"url":           url,
 			"parsing_error": err,
 		}).Warn("Error parsing JSON")
 		return // Quit early in the hopes of not messing up other go routines
 	}
 
-	log.WithFields(log.Fields{
+	logger.WithFields(log.Fields{
 		"collection": result.Title,
 		"page":       result.Pagination.Current,
 		"url":        url,
@@ -100,7 +105,7 @@ fetch:
 
 	// If there is another page of results, go fetch it.
 	if result.Pagination.Next != "" {
-		c.FetchCollectionItems(result.Pagination.Current+1, results)
+		c.FetchCollectionItems(ctx, result.Pagination.Current+1, results)
 	}
 
 }
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"time"
 
+	"github.com/lmullen/cchc/common/logging"
+	"github.com/lmullen/cchc/common/metrics"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -12,10 +15,14 @@ import (
 // own goroutine.
 func StartFetchingCollections(cp chan CollectionAPIPage) {
 	for { // This will happen forever until the program is quit
-		log.Info("Starting a crawl of all collections")
+		app.Health.Progress()
+
+		// Each crawl gets its own correlation ID
+		ctx := logging.NewCorrelationID(context.Background())
+		logging.From(ctx).Info("Starting a crawl of all collections")
 		collections, err := FetchAllCollections()
 		if err != nil {
-			log.Error("Error fetching all digital collections:", err)
+			logging.From(ctx).WithError(err).Error("Error fetching all digital collections")
 			time.Sleep(1 * time.Hour) // Don't wait forever, but don't try again right away
 			break                     // Start over trying to fetch all collections
 		}
@@ -27,21 +34,21 @@ func StartFetchingCollections(cp chan CollectionAPIPage) {
 			// Save a collection's metadata to the database
 			err = c.Save()
 			if err != nil {
-				log.WithField("collection", c).Error("Error saving collection to database:", err)
+				logging.From(ctx).WithField(logging.FieldCollection, c.ID).WithError(err).Error("Error saving collection to database")
 			}
 
 			// Start fetching the items in that collection.
 			// Fetch the first page of the collection. As long as there are more pages,
 			// the function will continue to fetch those too and add them to the channel.
-			go c.FetchCollectionItems(1, cp)
+			go c.FetchCollectionItems(ctx, 1, cp)
 
 		}
 
 		// Goroutines have been started for fetching each collections items. We
 		// want to wait a decent interval, and then start the crawl over again
 		// from the beginning.
-		log.Infof("Waiting to start a crawl of all collections for %s", crawlInterval)
-		time.Sleep(crawlInterval)
+		log.Infof("Waiting to start a crawl of all collections for %s", app.Config.CrawlInterval)
+		time.Sleep(app.Config.CrawlInterval)
 		// Now the loop starts over again by fetching all the digital collections
 	}
 
@@ -57,16 +64,19 @@ func StartProcessingCollections(cp chan CollectionAPIPage) {
 	// channel.
 	for r := range cp {
 		// Start a new goroutine to deal with each page
+		app.Health.Progress()
 		go func(r CollectionAPIPage) {
 			for _, item := range r.Results {
 				item.CollectionID = r.CollectionID
 				err := item.Save()
 				if err != nil {
 					log.WithFields(log.Fields{
-						"item_id": item.ID,
-						"error":   err,
-					}).Error("Error saving item")
+						logging.FieldItem:       item.ID,
+						logging.FieldCollection: item.CollectionID,
+					}).WithError(err).Error("Error saving item")
+					continue
 				}
+				metrics.ItemsDiscovered.Inc()
 			}
 		}(r)
 	}
@@ -12,10 +12,10 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -24,12 +24,8 @@ import (
 const (
 	apiBase         = "https://www.loc.gov"
 	apiItemsPerPage = 1000
-	apiTimeout      = 60 // The timeout limit for API requests in seconds
 )
 
-// How long to wait between starting new crawls at the entry points
-var crawlInterval time.Duration = 2 * 24 * time.Hour
-
 var removeFromResponse = []string{
 	"aka", "breadcrumbs", "browse", "categories", "content", "content_is_post",
 	"expert_resources", "facet_trail", "facet_views", "facets", "featured_items",
@@ -49,6 +45,7 @@ func main() {
 		log.Fatal("Error initializing application: ", err)
 	}
 	defer app.Shutdown()
+	app.Health.Ready(context.Background())
 
 	// A channel to hold each page of the collection results
 	collectionPages := make(chan CollectionAPIPage, 1000)
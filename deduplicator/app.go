@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lmullen/cchc/common/config"
+	"github.com/lmullen/cchc/common/db"
+	"github.com/lmullen/cchc/common/health"
+	"github.com/lmullen/cchc/common/items"
+	"github.com/lmullen/cchc/common/jobs"
+	"github.com/lmullen/cchc/common/metrics"
+	"github.com/lmullen/cchc/common/results"
+	"github.com/lmullen/cchc/common/sources"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// The Config type stores the shared configuration, along with settings for the
+// deduplicator which are read from environment variables.
+type Config struct {
+	*config.Config
+	similarity float64
+}
+
+// The App type shares access to the database and other resources.
+type App struct {
+	DB          *pgxpool.Pool
+	Metrics     *http.Server
+	Health      *health.Checker
+	Config      *Config
+	ItemsRepo   items.Repository
+	JobsRepo    jobs.Repository
+	ResultsRepo results.Repository
+}
+
+// Init creates a new app and connects to the database or returns an error
+func (app *App) Init(ctx context.Context) error {
+	// Set a timeout for getting the application set up
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	// Read the shared configuration from a file, environment variables, and flags
+	app.Config = &Config{Config: config.Default()}
+	err := app.Config.Load("cchc-deduplicator", os.Args[1:])
+	if err != nil {
+		return err
+	}
+	err = app.Config.SetupLogging("cchc-deduplicator")
+	if err != nil {
+		return err
+	}
+	log.Info("Starting the deduplicator")
+	app.Config.Log()
+
+	// Set how similar two passages must be to count as duplicates
+	app.Config.similarity = 0.8
+	sim, ok := os.LookupEnv("CCHC_DEDUP_SIMILARITY")
+	if ok {
+		s, err := strconv.ParseFloat(sim, 64)
+		if err != nil || s <= 0 || s > 1 {
+			return fmt.Errorf("CCHC_DEDUP_SIMILARITY must be a number greater than 0 and at most 1, not %s", sim)
+		}
+		app.Config.similarity = s
+	}
+	log.WithField("similarity", app.Config.similarity).Info("Set the threshold for duplicate passages")
+
+	// Clusters of items are kept for the loc.gov items only
+	for _, s := range app.Config.Sources {
+		if s != sources.Items {
+			return fmt.Errorf("The deduplicator only processes documents from the %s source, not %s", sources.Items, s)
+		}
+	}
+
+	// Connect to the database and create the various repositories needed
+	pool, err := db.Connect(ctx, app.Config.DBStr, "cchc-deduplicator")
+	if err != nil {
+		return err
+	}
+	app.DB = pool
+	app.ItemsRepo = items.NewItemRepo(pool)
+	app.JobsRepo = jobs.NewJobsRepo(pool)
+	app.ResultsRepo = results.NewRepo(pool)
+	log.Info("Connected to the database successfully")
+
+	// Serve metrics about the database and jobs for Prometheus, along with
+	// health checks. The longest the main loop should wait is the time between
+	// checks for ready jobs.
+	err = metrics.RegisterPool(pool)
+	if err != nil {
+		return err
+	}
+	app.Health = health.New(pool.Ping, app.Config.ProgressWindow, app.Config.WaitTime)
+	mux := http.NewServeMux()
+	app.Health.Register(mux)
+	app.Metrics, err = metrics.Serve(app.Config.MetricsAddr, mux)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the database schema is the version this application expects
+	err = db.CheckSchema(ctx, app.Config.DBStr, app.Config.AutoMigrate)
+	if err != nil {
+		return err
+	}
+	log.WithField("version", db.RequiredVersion).Info("The database schema is at the required version")
+
+	return nil
+}
+
+// Shutdown stops serving metrics and closes the connection to the database.
+func (app *App) Shutdown() {
+	if app.Metrics != nil {
+		app.Metrics.Close()
+	}
+	log.Info("Closing the connection to the database")
+	app.DB.Close()
+	log.Info("Shutdown the deduplicator")
+}
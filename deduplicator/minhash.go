@@ -0,0 +1,138 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"unicode"
+)
+
+// Parameters for MinHash signatures and locality-sensitive hashing. Passages
+// are compared as sets of shingles, overlapping runs of words. Each signature
+// has one value per hash function, and the signature is divided into bands of
+// rows: two passages are candidates for comparison if every row in any band is
+// the same. With 16 bands of 4 rows, passages which are 80% similar are almost
+// certain to be compared, while passages which are 30% similar rarely are.
+const (
+	shingleSize = 5
+	numHashes   = 64
+	numBands    = 16
+	bandRows    = numHashes / numBands
+)
+
+// hashSeed fixes the hash functions, so that signatures computed by different
+// workers, or at different times, can be compared.
+const hashSeed = 1607
+
+// The hash functions are of the form a*x + b, with a odd so that each function
+// is a permutation of the 64-bit integers.
+var hashA, hashB = hashParams()
+
+func hashParams() ([]uint64, []uint64) {
+	r := rand.New(rand.NewSource(hashSeed))
+	a := make([]uint64, numHashes)
+	b := make([]uint64, numHashes)
+	for i := range a {
+		a[i] = r.Uint64() | 1
+		b[i] = r.Uint64()
+	}
+	return a, b
+}
+
+// A word is a run of letters and digits, lowercased, along with its offsets in
+// characters from the start of the text.
+type word struct {
+	text       string
+	start, end int
+}
+
+// tokenize splits a text into words, ignoring punctuation and case, since OCR
+// and typesetting differ between reprints of the same text.
+func tokenize(text string) []word {
+	var words []word
+	var current []rune
+	start, i := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if current == nil {
+				start = i
+			}
+			current = append(current, unicode.ToLower(r))
+		} else if current != nil {
+			words = append(words, word{string(current), start, i})
+			current = nil
+		}
+		i++
+	}
+	if current != nil {
+		words = append(words, word{string(current), start, i})
+	}
+	return words
+}
+
+// shingles hashes each run of words of the shingle size. A passage shorter than
+// a shingle is treated as a single shingle.
+func shingles(words []word) []uint64 {
+	n := len(words) - shingleSize + 1
+	if n < 1 {
+		n = 1
+	}
+	out := make([]uint64, 0, n)
+	for i := 0; i < n; i++ {
+		h := fnv.New64a()
+		for j := i; j < i+shingleSize && j < len(words); j++ {
+			h.Write([]byte(words[j].text))
+			h.Write([]byte{' '})
+		}
+		out = append(out, h.Sum64())
+	}
+	return out
+}
+
+// minHash computes the MinHash signature of a set of shingles.
+func minHash(shingles []uint64) []uint64 {
+	sig := make([]uint64, numHashes)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for _, s := range shingles {
+		for i := range sig {
+			h := hashA[i]*s + hashB[i]
+			if h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+// bands hashes each band of rows in a signature.
+func bands(sig []uint64) []uint64 {
+	out := make([]uint64, numBands)
+	buf := make([]byte, 8)
+	for b := range out {
+		h := fnv.New64a()
+		for _, v := range sig[b*bandRows : (b+1)*bandRows] {
+			for i := range buf {
+				buf[i] = byte(v >> (8 * i))
+			}
+			h.Write(buf)
+		}
+		out[b] = h.Sum64()
+	}
+	return out
+}
+
+// similarity estimates the Jaccard similarity of the shingles in two passages
+// as the share of their signatures which are the same.
+func similarity(a, b []uint64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
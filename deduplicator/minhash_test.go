@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sermon = "And it came to pass in those days, that there went out a decree from " +
+	"Caesar Augustus, that all the world should be taxed. And all went to be taxed, " +
+	"every one into his own city. And Joseph also went up from Galilee, out of the " +
+	"city of Nazareth, into Judaea, unto the city of David, which is called Bethlehem."
+
+func TestTokenize(t *testing.T) {
+	t.Parallel()
+
+	text := "“Fear not,” said the Angel; for, behold, I bring you good tidings."
+	words := tokenize(text)
+	require.Len(t, words, 12)
+	assert.Equal(t, "fear", words[0].text)
+	assert.Equal(t, "tidings", words[11].text)
+
+	// Offsets are in characters, not bytes, so they locate the word in the text
+	runes := []rune(text)
+	for _, w := range words {
+		assert.Equal(t, w.text, strings.ToLower(string(runes[w.start:w.end])))
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	t.Parallel()
+
+	sig := minHash(shingles(tokenize(sermon)))
+	assert.Len(t, sig, numHashes)
+	assert.Equal(t, 1.0, similarity(sig, sig))
+
+	// A reprint with different punctuation, capitalization, and a few OCR errors
+	// is nearly the same, and shares at least one band
+	reprint := strings.ToUpper(strings.NewReplacer(",", "", "Joseph", "Jofeph").Replace(sermon))
+	other := minHash(shingles(tokenize(reprint)))
+	assert.Greater(t, similarity(sig, other), 0.6)
+	assert.True(t, sharesBand(bands(sig), bands(other)))
+
+	// An unrelated text is not similar
+	unrelated := minHash(shingles(tokenize(strings.Repeat("the quick brown fox jumps over the lazy dog ", 8))))
+	assert.Less(t, similarity(sig, unrelated), 0.2)
+
+	// Signatures of different lengths can't be compared
+	assert.Equal(t, 0.0, similarity(sig, sig[:10]))
+}
+
+func sharesBand(a, b []uint64) bool {
+	for i := range a {
+		if a[i] == b[i] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package main
+
+// Passages are overlapping windows of words on a page, so that a reprinted
+// text is found even if it is only part of a page, or runs across columns
+// which the OCR has put in a different order.
+const (
+	passageWords  = 100
+	passageStride = 50
+	minWords      = 25 // Shorter passages are too likely to match by chance
+)
+
+// A passage is a window of words on a page, with its offsets in characters from
+// the start of the page.
+type passage struct {
+	index      int
+	start, end int
+	words      []word
+}
+
+// passages divides the words on a page into overlapping windows. The last
+// window always ends at the last word, so that the end of the page is covered.
+func passages(words []word) []passage {
+	if len(words) < minWords {
+		return nil
+	}
+	var out []passage
+	for i := 0; ; i += passageStride {
+		if i+passageWords >= len(words) {
+			start := len(words) - passageWords
+			if start < 0 {
+				start = 0
+			}
+			return append(out, newPassage(len(out), words[start:]))
+		}
+		out = append(out, newPassage(len(out), words[i:i+passageWords]))
+	}
+}
+
+func newPassage(index int, words []word) passage {
+	return passage{
+		index: index,
+		start: words[0].start,
+		end:   words[len(words)-1].end,
+		words: words,
+	}
+}
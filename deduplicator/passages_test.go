@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPassages(t *testing.T) {
+	t.Parallel()
+
+	page := func(n int) []word { return tokenize(strings.Repeat("word ", n)) }
+
+	assert.Empty(t, passages(page(minWords-1)))
+	assert.Len(t, passages(page(minWords)), 1)
+	assert.Len(t, passages(page(passageWords)), 1)
+
+	// Windows overlap by the stride, and the last window ends at the last word
+	ps := passages(page(220))
+	assert.Len(t, ps, 4)
+	for i, p := range ps {
+		assert.Equal(t, i, p.index)
+		assert.Len(t, p.words, passageWords)
+	}
+	assert.Equal(t, 0, ps[0].start)
+	assert.Equal(t, passageStride*5, ps[1].start) // Each word is five characters
+	assert.Equal(t, 220*5-1, ps[3].end)
+}
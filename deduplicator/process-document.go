@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"github.com/lmullen/cchc/common/items"
+	"github.com/lmullen/cchc/common/jobs"
+	"github.com/lmullen/cchc/common/logging"
+	"github.com/lmullen/cchc/common/metrics"
+	"github.com/lmullen/cchc/common/results"
+)
+
+func processDocument(ctx context.Context, job *jobs.FullText) error {
+
+	// We've received a job. We need to compute signatures for the passages in the
+	// item, find the passages in other items which are near-duplicates, and put
+	// the item in a cluster with the items it shares passages with.
+
+	// The job has the item we need, so get the item from the database.
+	timeout, cancel := context.WithTimeout(context.Background(), app.Config.DBTimeout)
+	item, err := app.ItemsRepo.Get(timeout, job.ItemID)
+	cancel()
+	if err != nil {
+		job.Fail()
+		saveJob(ctx, job)
+		return err
+	}
+
+	// Get the full text for the item
+	pages, has := item.FullText()
+
+	// It's possible we don't have full text. If so, skip the job.
+	if !has {
+		job.Skip()
+		saveJob(ctx, job)
+		return nil
+	}
+
+	sigs := signatures(job, pages)
+
+	cluster, err := finishJob(job, sigs)
+	if err != nil {
+		job.Fail()
+		saveJob(ctx, job)
+		return err
+	}
+	logging.From(ctx).WithField("cluster", cluster).Debug("Clustered item")
+
+	return nil
+
+}
+
+// finishJob saves the signatures for an item, finds and saves its duplicates,
+// clusters it, and marks the job as finished, all in one transaction, so that a
+// job only has results if it finished. The transaction holds the lock on the
+// clusters throughout, so that each job sees the passages saved by every job
+// which finished before it. It returns the ID of the item's cluster.
+func finishJob(job *jobs.FullText, sigs []*results.PassageSignature) (string, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), app.Config.DBTimeout)
+	defer cancel()
+
+	tx, err := app.DB.Begin(timeout)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(timeout) // Roll back the transaction if something goes wrong
+
+	err = results.LockClusters(timeout, tx)
+	if err != nil {
+		return "", err
+	}
+	res := app.ResultsRepo.WithTx(tx)
+
+	if len(sigs) > 0 {
+		err = res.SavePassageSignatures(timeout, sigs)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	candidates, err := res.PassageCandidates(timeout, job.ID, job.ItemID)
+	if err != nil {
+		return "", err
+	}
+	dups := duplicates(job, sigs, candidates, app.Config.similarity)
+
+	err = res.SaveDuplicatePassages(timeout, dups)
+	if err != nil {
+		return "", err
+	}
+
+	cluster, err := res.ClusterItems(timeout, job.ItemID, duplicateItems(dups))
+	if err != nil {
+		return "", err
+	}
+
+	// The job was successful
+	job.Finish()
+	err = app.JobsRepo.WithTx(tx).SaveFullText(timeout, job)
+	if err != nil {
+		return "", err
+	}
+	err = tx.Commit(timeout)
+	if err != nil {
+		return "", err
+	}
+	metrics.JobDone(job)
+
+	return cluster, nil
+}
+
+// signatures computes the signature of each passage on each page of an item.
+func signatures(job *jobs.FullText, pages []items.PlainText) []*results.PassageSignature {
+	var sigs []*results.PassageSignature
+	for i, page := range pages {
+		app.Health.Progress()
+		for _, p := range passages(tokenize(page.Text)) {
+			sig := minHash(shingles(p.words))
+			sigs = append(sigs, &results.PassageSignature{
+				JobID:     job.ID,
+				ItemID:    job.ItemID,
+				Page:      i,
+				Passage:   p.index,
+				StartChar: p.start,
+				EndChar:   p.end,
+				MinHash:   sig,
+				Bands:     bands(sig),
+			})
+		}
+	}
+	return sigs
+}
+
+// duplicates compares each passage in the item with the candidate passages in
+// other items, keeping the pairs which are at least as similar as the threshold.
+func duplicates(job *jobs.FullText, sigs []*results.PassageSignature,
+	candidates []*results.PassageCandidate, threshold float64) []*results.DuplicatePassage {
+
+	type key struct{ page, passage int }
+	mine := make(map[key]*results.PassageSignature, len(sigs))
+	for _, s := range sigs {
+		mine[key{s.Page, s.Passage}] = s
+	}
+
+	var dups []*results.DuplicatePassage
+	for _, c := range candidates {
+		s, ok := mine[key{c.Page, c.Passage}]
+		if !ok {
+			continue
+		}
+		sim := similarity(s.MinHash, c.Other.MinHash)
+		if sim < threshold {
+			continue
+		}
+		dups = append(dups, &results.DuplicatePassage{
+			JobID:        job.ID,
+			ItemID:       job.ItemID,
+			Page:         c.Page,
+			Passage:      c.Passage,
+			OtherItemID:  c.Other.ItemID,
+			OtherPage:    c.Other.Page,
+			OtherPassage: c.Other.Passage,
+			Similarity:   sim,
+		})
+	}
+	return dups
+}
+
+// duplicateItems returns the distinct items which share passages with an item.
+func duplicateItems(dups []*results.DuplicatePassage) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, d := range dups {
+		if !seen[d.OtherItemID] {
+			seen[d.OtherItemID] = true
+			ids = append(ids, d.OtherItemID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// saveJob saves the status of the job, logging rather than returning any error
+// since the job has already succeeded or failed. The context is only used for
+// logging: the status is saved with its own timeout even while shutting down.
+func saveJob(ctx context.Context, job *jobs.FullText) {
+	timeout, cancel := context.WithTimeout(context.Background(), app.Config.DBTimeout)
+	defer cancel()
+	err := app.JobsRepo.SaveFullText(timeout, job)
+	if err != nil {
+		logging.From(ctx).WithError(err).WithField(logging.FieldStatus, job.Status).Error("Error saving job status")
+		return
+	}
+	metrics.JobDone(job)
+}
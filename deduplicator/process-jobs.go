@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lmullen/cchc/common/jobs"
+	"github.com/lmullen/cchc/common/logging"
+	"github.com/lmullen/cchc/common/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+func processJobs(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	log.Info("Checking whether there are jobs to be processed")
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Stopped processing jobs")
+			return
+		default:
+			app.Health.Progress()
+			timeoutGet, cancelGet := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancelGet()
+			job, err := app.JobsRepo.GetReadyJob(timeoutGet, queue)
+			if err != nil {
+				if err == jobs.ErrNoJobs {
+					log.Infof("No ready jobs, so waiting %s to check again", app.Config.WaitTime)
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(app.Config.WaitTime):
+						log.Info("Checking again whether there are jobs to be processed")
+						continue
+					}
+				}
+				log.WithError(err).Error("Error getting a job that is ready")
+				continue
+			}
+
+			metrics.JobClaimed(job)
+
+			// Each job gets its own correlation ID, so that all the logs for the job
+			// can be joined
+			jobCtx := logging.WithJob(logging.NewCorrelationID(ctx), job)
+			err = processDocument(jobCtx, job)
+			if err != nil {
+				logging.From(jobCtx).WithError(err).Error("Error processing job")
+				continue
+			}
+			logging.From(jobCtx).Debug("Successfully processed job")
+
+		}
+	}
+}
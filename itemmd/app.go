@@ -2,13 +2,15 @@ package main
 
 import (
 	"context"
-	"errors"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/lmullen/cchc/common/config"
 	"github.com/lmullen/cchc/common/db"
+	"github.com/lmullen/cchc/common/health"
 	"github.com/lmullen/cchc/common/items"
+	"github.com/lmullen/cchc/common/metrics"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/hashicorp/go-retryablehttp"
@@ -16,24 +18,14 @@ import (
 	"go.uber.org/ratelimit"
 )
 
-// Configuration options that aren't worth exposing as environment variables
-const (
-	apiTimeout   = 10            // The timeout limit for API requests in seconds
-	waitInterval = 2 * time.Hour // How long to wait to check for more items
-)
-
-// The Config type stores configuration which is read from environment variables.
-type Config struct {
-	dbstr    string
-	loglevel string
-}
-
 // The App type shares access to the database and other resources.
 type App struct {
 	DB        *pgxpool.Pool
-	Config    *Config
+	Config    *config.Config
 	Client    *http.Client
 	ItemsRepo items.Repository
+	Metrics   *http.Server
+	Health    *health.Checker
 	Limiters  struct {
 		Newspapers  ratelimit.Limiter
 		Items       ratelimit.Limiter
@@ -44,58 +36,54 @@ type App struct {
 
 // Init creates a new app and connects to the database or returns an error
 func (app *App) Init(ctx context.Context) error {
-	log.Info("Starting the item metadata fetcher")
-
 	// Set a timeout for getting the application set up
 	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
-	app.Config = &Config{}
-
-	// Set the logging level
-	ll, ok := os.LookupEnv("CCHC_LOGLEVEL")
-	if !ok {
-		ll = "info"
+	// Read the configuration from a file, environment variables, and flags. This
+	// service calls the API more often and waits longer for new items than the
+	// others.
+	app.Config = config.Default()
+	app.Config.APITimeout = 10 * time.Second
+	app.Config.WaitTime = 2 * time.Hour
+	err := app.Config.Load("cchc-itemmd", os.Args[1:])
+	if err != nil {
+		return err
 	}
-	app.Config.loglevel = ll
-	switch app.Config.loglevel {
-	case "error":
-		log.SetLevel(log.ErrorLevel)
-	case "warn":
-		log.SetLevel(log.WarnLevel)
-	case "info":
-		log.SetLevel(log.InfoLevel)
-	case "debug":
-		log.SetLevel(log.DebugLevel)
-	case "trace":
-		log.SetLevel(log.TraceLevel)
+	err = app.Config.SetupLogging("cchc-itemmd")
+	if err != nil {
+		return err
 	}
+	log.Info("Starting the item metadata fetcher")
+	app.Config.Log()
 
 	// Record items that we failed to fetch and when so we don't get stuck fetching them
 	app.Failures = make(map[string]time.Time)
 
 	// Connect to the database and create the various repositories needed
-	dbstr, ok := os.LookupEnv("CCHC_DBSTR")
-	if !ok {
-		return errors.New("CCHC_DBSTR environment variable is not set")
+	pool, err := db.Connect(ctx, app.Config.DBStr, "cchc-itemmd")
+	if err != nil {
+		return err
 	}
-	app.Config.dbstr = dbstr
+	app.DB = pool
+	app.ItemsRepo = items.NewItemRepo(pool)
+	log.Info("Connected to the database successfully")
 
-	db, err := db.Connect(ctx, app.Config.dbstr, "cchc-itemmd")
+	// Make sure the database schema is the version this application expects
+	err = db.CheckSchema(ctx, app.Config.DBStr, app.Config.AutoMigrate)
 	if err != nil {
 		return err
 	}
-	app.DB = db
-	app.ItemsRepo = items.NewItemRepo(db)
-	log.Info("Connected to the database successfully")
+	log.WithField("version", db.RequiredVersion).Info("The database schema is at the required version")
 
 	// Set up a client to use for all HTTP requests. It will automatically retry.
 	rc := retryablehttp.NewClient()
 	rc.RetryWaitMin = 2 * time.Second
 	rc.RetryWaitMax = 5 * time.Second
 	rc.RetryMax = 3
-	rc.HTTPClient.Timeout = apiTimeout * time.Second
+	rc.HTTPClient.Timeout = app.Config.APITimeout
 	rc.Logger = nil
+	rc.HTTPClient.Transport = metrics.InstrumentTransport(rc.HTTPClient.Transport)
 	// This will log all HTTP requests made, which is not desirable.
 	// rc.RequestLogHook = func(_ retryablehttp.Logger, req *http.Request, attempt int) {
 	// 	log.WithFields(logrus.Fields{
@@ -107,22 +95,33 @@ func (app *App) Init(ctx context.Context) error {
 
 	// Create rate limiters for different endpoints. Rate limits documentation:
 	// https://www.loc.gov/apis/json-and-yaml/
-	// The subtractions here represent a buffer from the officially presented
-	// rate limits.
-	il := ratelimit.New(200-20, ratelimit.Per(60*time.Second)) // 200 requests/minute
-	app.Limiters.Items = il
-
-	cl := ratelimit.New(80-20, ratelimit.Per(60*time.Second)) // 80 requests/minute
-	app.Limiters.Collections = cl
-
-	nl := ratelimit.New(20-4, ratelimit.Per(10*time.Second)) // 120 requests/minute
-	app.Limiters.Newspapers = nl
+	app.Limiters.Items = metrics.InstrumentLimiter(app.Config.RateLimits.Items.Limiter(), "item")
+	app.Limiters.Collections = metrics.InstrumentLimiter(app.Config.RateLimits.Collections.Limiter(), "collections")
+	app.Limiters.Newspapers = metrics.InstrumentLimiter(app.Config.RateLimits.Newspapers.Limiter(), "newspapers")
+
+	// Serve metrics about the database and the API for Prometheus, along with
+	// health checks. The longest the main loop should wait is the time between
+	// checks for unfetched items.
+	err = metrics.RegisterPool(app.DB)
+	if err != nil {
+		return err
+	}
+	app.Health = health.New(pool.Ping, app.Config.ProgressWindow, app.Config.WaitTime)
+	mux := http.NewServeMux()
+	app.Health.Register(mux)
+	app.Metrics, err = metrics.Serve(app.Config.MetricsAddr, mux)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
-// Shutdown closes the connection to the database.
+// Shutdown stops serving metrics and closes the connection to the database.
 func (app *App) Shutdown() {
+	if app.Metrics != nil {
+		app.Metrics.Close()
+	}
 	log.Info("Closing the connection to the database")
 	app.DB.Close()
 	log.Info("Shutdown the item metadata fetcher")
@@ -38,6 +38,7 @@ func main() {
 		log.Fatal("Error initializing application: ", err)
 	}
 	defer app.Shutdown()
+	app.Health.Ready(ctx)
 
 	// Process items with unfetched metadata
 	wg := &sync.WaitGroup{}
@@ -5,6 +5,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/lmullen/cchc/common/logging"
+	"github.com/lmullen/cchc/common/metrics"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -17,6 +19,7 @@ checkForUnfetched:
 	for {
 
 		// First check if we have unfetched items
+		app.Health.Progress()
 		log.Info("Checking if there are any unfetched items in the database")
 		check, unfetched, err := getUnfetched(ctx)
 		if err != nil {
@@ -32,14 +35,17 @@ checkForUnfetched:
 				// Break out of the function if the context was canceled
 				log.WithContext(ctx).Info("Work canceled: stopping processing of unfetched items")
 				return
-			case <-time.After(waitInterval):
+			case <-time.After(app.Config.WaitTime):
 				log.Info("Resuming checking for unfetched items")
 				continue checkForUnfetched
 			}
 		}
 
-		log.WithField("unfetched", len(unfetched)).Info("Found unfetched items and starting to fetch them")
+		// Each pass through the unfetched items gets its own correlation ID
+		passCtx := logging.NewCorrelationID(ctx)
+		logging.From(passCtx).WithField("unfetched", len(unfetched)).Info("Found unfetched items and starting to fetch them")
 		for _, id := range unfetched {
+			logger := logging.From(passCtx).WithField(logging.FieldItem, id)
 
 			// Check for context cancellation before fetchign each item
 			select {
@@ -49,30 +55,32 @@ checkForUnfetched:
 				return
 			default:
 				// Do work on each item
+				app.Health.Progress()
 				// If an item previously failed less than an hour ago skip it
 				if !checkable(app.Failures, id) {
-					log.WithField("item_id", id).Debug("Skipping item because it failed to fetch less than an hour ago")
+					logger.Debug("Skipping item because it failed to fetch less than an hour ago")
 					continue
 				}
 
 				// Get the item from the database and fetch it, then save to repository
 				item, err := app.ItemsRepo.Get(ctx, id)
 				if err != nil {
-					log.WithError(err).WithField("item_id", id).Error("Error getting item to fetch from database")
+					logger.WithError(err).Error("Error getting item to fetch from database")
 					continue
 				}
 
 				if isResourceNotItem(item.URL.String) {
-					log.WithField("item_id", id).Trace("Skipping item because it is actually a resource")
+					logger.Trace("Skipping item because it is actually a resource")
 					continue
 				}
 
 				// Make sure to rate limit
 				app.Limiters.Items.Take()
-				log.WithField("item_id", item.ID).Debug("Fetching item from loc.gov API")
+				logger.Debug("Fetching item from loc.gov API")
 				err = item.Fetch(app.Client)
+				metrics.ItemsFetched.WithLabelValues(metrics.Result(err)).Inc()
 				if err != nil {
-					log.WithError(err).WithField("item_id", id).Error("Error fetching item from API")
+					logger.WithError(err).Error("Error fetching item from API")
 					// Record when the last failure happened
 					app.Failures[id] = time.Now()
 					continue
@@ -83,15 +91,15 @@ checkForUnfetched:
 
 				// Run the database saving in a separate goroutine so as not to slow down the rate limiter
 				wg.Add(1)
-				go func() {
+				go func(logger *log.Entry) {
 					defer wg.Done()
 					timeout, cancelTimeout := context.WithTimeout(context.Background(), 60*time.Second)
 					defer cancelTimeout()
-					err = app.ItemsRepo.Save(timeout, item)
+					err := app.ItemsRepo.Save(timeout, item)
 					if err != nil {
-						log.WithError(err).WithField("item_id", id).Error("Error saving item to database")
+						logger.WithError(err).Error("Error saving item to database")
 					}
-				}()
+				}(logger)
 
 			}
 		}
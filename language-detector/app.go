@@ -2,85 +2,131 @@ package main
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"net/http"
 	"os"
 	"time"
 
+	"github.com/lmullen/cchc/common/config"
 	"github.com/lmullen/cchc/common/db"
-	"github.com/lmullen/cchc/common/items"
+	"github.com/lmullen/cchc/common/health"
 	"github.com/lmullen/cchc/common/jobs"
+	"github.com/lmullen/cchc/common/metrics"
 	"github.com/lmullen/cchc/common/results"
+	"github.com/lmullen/cchc/common/sources"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
-// The Config type stores configuration which is read from environment variables.
+// Output modes for the language detector, which are set in the shared
+// configuration.
+const (
+	outputItems = config.LanguageOutputItems
+	outputSpans = config.LanguageOutputSpans
+	outputBoth  = config.LanguageOutputBoth
+)
+
+// The Config type stores the shared configuration, which includes the settings
+// for the language detector.
 type Config struct {
-	dbstr    string
-	loglevel string
+	*config.Config
 }
 
 // The App type shares access to the database and other resources.
 type App struct {
 	DB          *pgxpool.Pool
+	Metrics     *http.Server
+	Health      *health.Checker
 	Config      *Config
-	ItemsRepo   items.Repository
+	Documents   sources.Repository
 	JobsRepo    jobs.Repository
 	ResultsRepo results.Repository
 }
 
 // Init creates a new app and connects to the database or returns an error
 func (app *App) Init(ctx context.Context) error {
-	log.Info("Starting the language detector")
-
 	// Set a timeout for getting the application set up
 	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
-	app.Config = &Config{}
+	// Read the shared configuration from a file, environment variables, and flags
+	app.Config = &Config{Config: config.Default()}
+	err := app.Config.Load("cchc-language-detector", os.Args[1:])
+	if err != nil {
+		return err
+	}
+	err = app.Config.SetupLogging("cchc-language-detector")
+	if err != nil {
+		return err
+	}
+	log.Info("Starting the language detector")
+	app.Config.Log()
 
-	// Set the logging level
-	ll, ok := os.LookupEnv("CCHC_LOGLEVEL")
-	if !ok {
-		ll = "info"
+	// Set up the language detector with its backend, candidate languages, and
+	// thresholds
+	lang := app.Config.Language
+	identifier, err := newIdentifier(lang.Backend, lang.Languages, lang.LowAccuracy)
+	if err != nil {
+		return fmt.Errorf("Error creating language identification backend: %w", err)
 	}
-	app.Config.loglevel = ll
-	switch app.Config.loglevel {
-	case "error":
-		log.SetLevel(log.ErrorLevel)
-	case "warn":
-		log.SetLevel(log.WarnLevel)
-	case "info":
-		log.SetLevel(log.InfoLevel)
-	case "debug":
-		log.SetLevel(log.DebugLevel)
-	case "trace":
-		log.SetLevel(log.TraceLevel)
+	d, err := newDetector(identifier, lang.MinConfidence, lang.MinLength)
+	if err != nil {
+		return fmt.Errorf("Error creating language detector: %w", err)
 	}
+	ldetector = d
+	queue = destination(identifier, lang)
+	log.WithFields(log.Fields{
+		"backend":         identifier.Name(),
+		"destination":     queue,
+		"output":          lang.Output,
+		"languages":       lang.Languages.String(),
+		"min_confidence":  lang.MinConfidence,
+		"min_length":      lang.MinLength,
+		"secondary_share": lang.SecondaryShare,
+	}).Info("Set up the language detector")
 
 	// Connect to the database and create the various repositories needed
-	dbstr, ok := os.LookupEnv("CCHC_DBSTR")
-	if !ok {
-		return errors.New("CCHC_DBSTR environment variable is not set")
+	pool, err := db.Connect(ctx, app.Config.DBStr, "cchc-language-detector")
+	if err != nil {
+		return err
 	}
-	app.Config.dbstr = dbstr
+	app.DB = pool
+	app.Documents = sources.NewSourcesRepo(pool)
+	app.JobsRepo = jobs.NewJobsRepo(pool)
+	app.ResultsRepo = results.NewRepo(pool)
+	log.Info("Connected to the database successfully")
 
-	db, err := db.Connect(ctx, app.Config.dbstr, "cchc-language-detector")
+	// Serve metrics about the database and jobs for Prometheus, along with
+	// health checks. The longest the main loop should wait is the time between
+	// checks for ready jobs.
+	err = metrics.RegisterPool(pool)
 	if err != nil {
 		return err
 	}
-	app.DB = db
-	app.ItemsRepo = items.NewItemRepo(db)
-	app.JobsRepo = jobs.NewJobsRepo(db)
-	app.ResultsRepo = results.NewRepo(db)
-	log.Info("Connected to the database successfully")
+	app.Health = health.New(pool.Ping, app.Config.ProgressWindow, app.Config.WaitTime)
+	mux := http.NewServeMux()
+	app.Health.Register(mux)
+	app.Metrics, err = metrics.Serve(app.Config.MetricsAddr, mux)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the database schema is the version this application expects
+	err = db.CheckSchema(ctx, app.Config.DBStr, app.Config.AutoMigrate)
+	if err != nil {
+		return err
+	}
+	log.WithField("version", db.RequiredVersion).Info("The database schema is at the required version")
 
 	return nil
 }
 
-// Shutdown closes the connection to the database.
+// Shutdown stops serving metrics and closes the connection to the database.
 func (app *App) Shutdown() {
+	if app.Metrics != nil {
+		app.Metrics.Close()
+	}
 	log.Info("Closing the connection to the database")
 	app.DB.Close()
 	log.Info("Shutdown the language detector")
@@ -0,0 +1,134 @@
+package main
+
+import "strings"
+
+// catalogCodes maps the names of languages in the loc.gov catalog (the MARC
+// language names, in lower case) to the upper case ISO 639-3 codes which the
+// language identification backends might return for them. Some names cover a
+// macrolanguage, for which lingua and whatlang return different codes: lingua
+// returns ARA for Arabic where whatlang returns ARB, for example, and both tell
+// Bokmål and Nynorsk apart where the catalog usually records only Norwegian.
+var catalogCodes = map[string][]string{
+	"afrikaans":              {"AFR"},
+	"akan":                   {"AKA"},
+	"albanian":               {"SQI"},
+	"amharic":                {"AMH"},
+	"arabic":                 {"ARA", "ARB"},
+	"armenian":               {"HYE"},
+	"azerbaijani":            {"AZE", "AZJ"},
+	"basque":                 {"EUS"},
+	"belarusian":             {"BEL"},
+	"bengali":                {"BEN"},
+	"bhojpuri":               {"BHO"},
+	"bosnian":                {"BOS"},
+	"bulgarian":              {"BUL"},
+	"burmese":                {"MYA"},
+	"catalan":                {"CAT"},
+	"cebuano":                {"CEB"},
+	"chinese":                {"ZHO", "CMN"},
+	"croatian":               {"HRV"},
+	"czech":                  {"CES"},
+	"danish":                 {"DAN"},
+	"dutch":                  {"NLD"},
+	"english":                {"ENG"},
+	"esperanto":              {"EPO"},
+	"estonian":               {"EST"},
+	"filipino":               {"TGL"},
+	"finnish":                {"FIN"},
+	"flemish":                {"NLD"},
+	"french":                 {"FRA"},
+	"ganda":                  {"LUG"},
+	"georgian":               {"KAT"},
+	"german":                 {"DEU"},
+	"greek":                  {"ELL"},
+	"greek, modern (1453-)":  {"ELL"},
+	"greek, modern (1453- )": {"ELL"},
+	"gujarati":               {"GUJ"},
+	"haitian french creole":  {"HAT"},
+	"hausa":                  {"HAU"},
+	"hebrew":                 {"HEB"},
+	"hindi":                  {"HIN"},
+	"hungarian":              {"HUN"},
+	"icelandic":              {"ISL"},
+	"igbo":                   {"IBO"},
+	"iloko":                  {"ILO"},
+	"indonesian":             {"IND"},
+	"irish":                  {"GLE"},
+	"italian":                {"ITA"},
+	"japanese":               {"JPN"},
+	"javanese":               {"JAV"},
+	"kannada":                {"KAN"},
+	"kazakh":                 {"KAZ"},
+	"khmer":                  {"KHM"},
+	"kinyarwanda":            {"KIN"},
+	"korean":                 {"KOR"},
+	"kurdish":                {"KUR"},
+	"latin":                  {"LAT"},
+	"latvian":                {"LAV"},
+	"lithuanian":             {"LIT"},
+	"macedonian":             {"MKD"},
+	"maithili":               {"MAI"},
+	"malagasy":               {"MLG"},
+	"malay":                  {"MSA"},
+	"malayalam":              {"MAL"},
+	"maori":                  {"MRI"},
+	"marathi":                {"MAR"},
+	"mongolian":              {"MON"},
+	"nepali":                 {"NEP"},
+	"norwegian":              {"NOR", "NOB", "NNO"},
+	"norwegian (bokmål)":     {"NOB"},
+	"norwegian (nynorsk)":    {"NNO"},
+	"nyanja":                 {"NYA"},
+	"oriya":                  {"ORI"},
+	"oromo":                  {"ORM"},
+	"panjabi":                {"PAN"},
+	"persian":                {"FAS", "PES"},
+	"polish":                 {"POL"},
+	"portuguese":             {"POR"},
+	"romanian":               {"RON"},
+	"rundi":                  {"RUN"},
+	"russian":                {"RUS"},
+	"serbian":                {"SRP"},
+	"shona":                  {"SNA"},
+	"sinhalese":              {"SIN"},
+	"slovak":                 {"SLK"},
+	"slovenian":              {"SLV"},
+	"somali":                 {"SOM"},
+	"sotho":                  {"SOT"},
+	"spanish":                {"SPA"},
+	"swahili":                {"SWA"},
+	"swedish":                {"SWE"},
+	"tagalog":                {"TGL"},
+	"tamil":                  {"TAM"},
+	"telugu":                 {"TEL"},
+	"thai":                   {"THA"},
+	"tigrinya":               {"TIR"},
+	"tsonga":                 {"TSO"},
+	"tswana":                 {"TSN"},
+	"turkish":                {"TUR"},
+	"turkmen":                {"TUK"},
+	"uighur":                 {"UIG"},
+	"ukrainian":              {"UKR"},
+	"urdu":                   {"URD"},
+	"uzbek":                  {"UZB"},
+	"vietnamese":             {"VIE"},
+	"welsh":                  {"CYM"},
+	"xhosa":                  {"XHO"},
+	"yiddish":                {"YID", "YDD"},
+	"yoruba":                 {"YOR"},
+	"zulu":                   {"ZUL"},
+}
+
+// catalogLanguageCodes returns the set of ISO 639-3 codes for the languages
+// recorded in the catalog. Names which are not in catalogCodes, such as those of
+// languages which neither backend can identify, are left out.
+func catalogLanguageCodes(catalog []string) map[string]bool {
+	codes := make(map[string]bool)
+	for _, name := range catalog {
+		name = strings.Join(strings.Fields(strings.ToLower(name)), " ")
+		for _, code := range catalogCodes[name] {
+			codes[code] = true
+		}
+	}
+	return codes
+}
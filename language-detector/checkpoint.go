@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lmullen/cchc/common/jobs"
+	"github.com/lmullen/cchc/common/logging"
+)
+
+// errInterrupted is returned when a job was stopped partway through and put
+// back in the queue to be resumed later.
+var errInterrupted = errors.New("Job was interrupted and has been requeued")
+
+// jobState is the work done so far on a job, which is kept in its checkpoint.
+// The spans are not saved as results until the job has finished, so that a job
+// has no results until then. Instead, the spans which are complete are saved
+// with each checkpoint in jobs.checkpoint_spans, and only the span which the
+// next sentence might extend is kept in the state. A job can be interrupted
+// partway through a page, since a book from the Stacks is a single very long
+// page.
+type jobState struct {
+	Page  int           `json:"page"` // The page being processed
+	Char  int           `json:"char"` // Characters of the page already processed
+	Stats LanguageStats `json:"stats"`
+	Spans []Span        `json:"spans,omitempty"`
+}
+
+// takeSpans removes the spans which are complete from the state and returns
+// them. Only the last span can be extended by the next sentence, so it is kept.
+func (s *jobState) takeSpans() []Span {
+	if len(s.Spans) < 2 {
+		return nil
+	}
+	last := len(s.Spans) - 1
+	done := s.Spans[:last]
+	s.Spans = []Span{s.Spans[last]}
+	return done
+}
+
+// loadCheckpoint returns the work already done on a job. If the job has not
+// been started before, then it starts from the first page with empty stats.
+func loadCheckpoint(ctx context.Context, job *jobs.FullText) (*jobState, error) {
+	state := &jobState{}
+
+	timeout, cancel := context.WithTimeout(context.Background(), app.Config.DBTimeout)
+	defer cancel()
+
+	c, err := app.JobsRepo.GetCheckpoint(timeout, job.ID)
+	if err != nil {
+		if errors.Is(err, jobs.ErrNoCheckpoint) {
+			state.Stats = make(LanguageStats)
+			return state, nil
+		}
+		return nil, fmt.Errorf("Error getting checkpoint: %w", err)
+	}
+
+	err = json.Unmarshal(c.State, state)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading checkpoint: %w", err)
+	}
+	if state.Stats == nil {
+		// Older checkpoints held only the stats, and the spans for their pages
+		// were saved as results when the checkpoint was saved
+		err = json.Unmarshal(c.State, &state.Stats)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading checkpoint: %w", err)
+		}
+	}
+	state.Page = c.Progress
+
+	logging.From(ctx).WithField("page", state.Page).WithField("char", state.Char).
+		Info("Resuming job from checkpoint")
+	return state, nil
+}
+
+// saveCheckpoint records the work done so far on a job. The spans which are
+// complete are saved with the checkpoint and removed from the state.
+func saveCheckpoint(ctx context.Context, job *jobs.FullText, state *jobState) error {
+	timeout, cancel := context.WithTimeout(context.Background(), app.Config.DBTimeout)
+	defer cancel()
+
+	done := state.takeSpans()
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	err = func() error {
+		tx, err := app.DB.Begin(timeout)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(timeout) // Roll back the transaction if something goes wrong
+
+		err = app.JobsRepo.WithTx(tx).SaveCheckpoint(timeout, &jobs.Checkpoint{
+			JobID:    job.ID,
+			Progress: state.Page,
+			State:    data,
+		})
+		if err != nil {
+			return err
+		}
+		err = app.ResultsRepo.WithTx(tx).SaveCheckpointSpans(timeout, languageSpans(job, done))
+		if err != nil {
+			return err
+		}
+		return tx.Commit(timeout)
+	}()
+	if err != nil {
+		return fmt.Errorf("Error saving checkpoint: %w", err)
+	}
+
+	logging.From(ctx).WithField("page", state.Page).WithField("char", state.Char).
+		WithField("spans", len(done)).Debug("Saved checkpoint for job")
+	return nil
+}
+
+// interruptJob checkpoints a job which has been stopped partway through and
+// puts it back in the queue.
+func interruptJob(ctx context.Context, job *jobs.FullText, state *jobState) error {
+	err := saveCheckpoint(ctx, job, state)
+	if err != nil {
+		job.Fail()
+		saveJob(ctx, job)
+		return err
+	}
+	job.Requeue()
+	saveJob(ctx, job)
+	return errInterrupted
+}
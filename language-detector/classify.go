@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/lmullen/cchc/common/results"
+)
+
+// Classification is an item-level summary derived from the language stats. Only
+// sentences whose language was identified are counted.
+type Classification struct {
+	Primary          string
+	Secondary        []string
+	Multilingual     bool
+	Diversity        float64
+	Sentences        int
+	CatalogAgreement string
+}
+
+// Classify derives an item-level classification from the language stats for an
+// item. The primary language is the language with the most sentences. Secondary
+// languages are any other languages whose share of the identified sentences is
+// at least the share threshold. The diversity is the Gini-Simpson index, i.e.,
+// the probability that two sentences chosen at random are in different
+// languages. The catalog languages are the language names recorded for the item
+// in its catalog record, which are compared against the detected languages.
+func Classify(stats LanguageStats, catalog []string, share float64) Classification {
+	var c Classification
+
+	// Order the identified languages from most to fewest sentences
+	var langs []string
+	for lang, n := range stats {
+		if lang == "UND" || n <= 0 {
+			continue
+		}
+		langs = append(langs, lang)
+		c.Sentences += n
+	}
+	if c.Sentences == 0 {
+		return c
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if stats[langs[i]] == stats[langs[j]] {
+			return langs[i] < langs[j]
+		}
+		return stats[langs[i]] > stats[langs[j]]
+	})
+
+	c.Primary = langs[0]
+	sumSquares := 0.0
+	for _, lang := range langs {
+		p := float64(stats[lang]) / float64(c.Sentences)
+		sumSquares += p * p
+		if lang != c.Primary && p >= share {
+			c.Secondary = append(c.Secondary, lang)
+		}
+	}
+	c.Diversity = 1 - sumSquares
+	c.Multilingual = len(c.Secondary) > 0
+	c.CatalogAgreement = catalogAgreement(c.Primary, c.Secondary, catalog)
+
+	return c
+}
+
+// catalogAgreement compares the detected languages to the catalog languages by
+// their ISO 639-3 codes. If none of the catalog languages can be identified by
+// the backends, they can't be compared.
+func catalogAgreement(primary string, secondary []string, catalog []string) string {
+	inCatalog := catalogLanguageCodes(catalog)
+	if len(inCatalog) == 0 {
+		return ""
+	}
+
+	if !inCatalog[primary] {
+		return results.CatalogDisagrees
+	}
+	for _, lang := range secondary {
+		if !inCatalog[lang] {
+			return results.CatalogPartial
+		}
+	}
+	return results.CatalogAgrees
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lmullen/cchc/common/results"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	stats := LanguageStats{
+		"ENG": 80,
+		"DEU": 15,
+		"LAT": 5,
+		"UND": 100,
+	}
+
+	c := Classify(stats, []string{"english", "german"}, 0.1)
+	assert.Equal(t, "ENG", c.Primary)
+	assert.Equal(t, []string{"DEU"}, c.Secondary)
+	assert.True(t, c.Multilingual)
+	assert.Equal(t, 100, c.Sentences)
+	assert.InDelta(t, 1-(0.8*0.8+0.15*0.15+0.05*0.05), c.Diversity, 0.0001)
+	assert.Equal(t, results.CatalogAgrees, c.CatalogAgreement)
+
+	c = Classify(stats, []string{"english"}, 0.1)
+	assert.Equal(t, results.CatalogPartial, c.CatalogAgreement)
+
+	c = Classify(stats, []string{"french"}, 0.1)
+	assert.Equal(t, results.CatalogDisagrees, c.CatalogAgreement)
+
+	c = Classify(stats, nil, 0.01)
+	assert.Equal(t, []string{"DEU", "LAT"}, c.Secondary)
+	assert.Empty(t, c.CatalogAgreement)
+
+	c = Classify(LanguageStats{"ENG": 10}, []string{"english"}, 0.1)
+	assert.False(t, c.Multilingual)
+	assert.Equal(t, 0.0, c.Diversity)
+
+	c = Classify(LanguageStats{"UND": 10}, []string{"english"}, 0.1)
+	assert.Empty(t, c.Primary)
+	assert.False(t, c.Multilingual)
+	assert.Empty(t, c.CatalogAgreement)
+}
+
+func TestCatalogAgreement(t *testing.T) {
+	t.Parallel()
+
+	// The catalog records the names of languages, sometimes capitalized, and the
+	// backends return codes which can be more specific than those names
+	tests := []struct {
+		primary   string
+		secondary []string
+		catalog   []string
+		want      string
+	}{
+		{"ENG", []string{"DEU"}, []string{"english", "german"}, results.CatalogAgrees},
+		{"ENG", []string{"DEU"}, []string{"English", "German"}, results.CatalogAgrees},
+		{"NOB", nil, []string{"norwegian"}, results.CatalogAgrees},
+		{"NNO", []string{"ENG"}, []string{"norwegian", "english"}, results.CatalogAgrees},
+		{"NNO", nil, []string{"norwegian (bokmål)"}, results.CatalogDisagrees},
+		{"ARB", nil, []string{"arabic"}, results.CatalogAgrees},
+		{"CMN", nil, []string{"chinese"}, results.CatalogAgrees},
+		{"YDD", []string{"HEB"}, []string{"yiddish", "hebrew"}, results.CatalogAgrees},
+		{"ELL", nil, []string{"greek, modern (1453-)"}, results.CatalogAgrees},
+		{"ELL", nil, []string{"Greek, Modern (1453- )"}, results.CatalogAgrees},
+		{"PES", []string{"ENG"}, []string{"persian"}, results.CatalogPartial},
+		{"ENG", nil, []string{"spanish"}, results.CatalogDisagrees},
+		// Languages the backends can't identify can't be compared
+		{"ENG", nil, []string{"ojibwa"}, ""},
+		{"ENG", nil, []string{"ojibwa", "english"}, results.CatalogAgrees},
+		{"ENG", nil, nil, ""},
+	}
+	for _, tt := range tests {
+		got := catalogAgreement(tt.primary, tt.secondary, tt.catalog)
+		assert.Equal(t, tt.want, got, "%s %v in %v", tt.primary, tt.secondary, tt.catalog)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+// combinedIdentifier runs two backends and only accepts languages on which
+// they agree. This trades recall for precision.
+type combinedIdentifier struct {
+	first  LanguageIdentifier
+	second LanguageIdentifier
+}
+
+func newCombinedIdentifier(first, second LanguageIdentifier) *combinedIdentifier {
+	return &combinedIdentifier{first: first, second: second}
+}
+
+// Identify returns the language if both backends agree, with the mean of their
+// confidence values. Otherwise the language is `UND`.
+func (c *combinedIdentifier) Identify(s string) (string, float64) {
+	lang1, conf1 := c.first.Identify(s)
+	if lang1 == "UND" {
+		return "UND", 0
+	}
+	lang2, conf2 := c.second.Identify(s)
+	if lang1 != lang2 {
+		return "UND", 0
+	}
+	return lang1, (conf1 + conf2) / 2
+}
+
+// Name returns the name of the backend. The lingua settings are included, since
+// those change the results.
+func (c *combinedIdentifier) Name() string {
+	if l, ok := c.first.(*linguaIdentifier); ok && l.lowAccuracy {
+		return backendCombined + "-low"
+	}
+	return backendCombined
+}
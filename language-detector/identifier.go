@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lmullen/cchc/common/config"
+	"github.com/lmullen/cchc/common/results"
+)
+
+// LanguageIdentifier is a backend which identifies the language of a piece of
+// text. Identify returns the upper case ISO 639-3 code for the most likely
+// language and the backend's confidence in that language, between 0 and 1. If
+// no language can be identified, the language is `UND`. Name returns a short
+// name for the backend and its settings.
+type LanguageIdentifier interface {
+	Identify(text string) (string, float64)
+	Name() string
+}
+
+// The language identification backends which can be selected.
+const (
+	backendLingua   = "lingua"
+	backendWhatlang = "whatlang"
+	backendCombined = "combined"
+)
+
+// newIdentifier creates the language identification backend with the given
+// name. If any ISO 639-3 codes are passed in, only those languages will be
+// considered. Low accuracy mode only applies to lingua.
+func newIdentifier(backend string, codes []string, lowAccuracy bool) (LanguageIdentifier, error) {
+	switch backend {
+	case backendLingua:
+		return newLinguaIdentifier(codes, lowAccuracy)
+	case backendWhatlang:
+		return newWhatlangIdentifier(codes)
+	case backendCombined:
+		l, err := newLinguaIdentifier(codes, lowAccuracy)
+		if err != nil {
+			return nil, err
+		}
+		w, err := newWhatlangIdentifier(codes)
+		if err != nil {
+			return nil, err
+		}
+		return newCombinedIdentifier(l, w), nil
+	default:
+		return nil, fmt.Errorf("%s is not a language identification backend; use %s, %s, or %s",
+			backend, backendLingua, backendWhatlang, backendCombined)
+	}
+}
+
+// destination returns the job destination for a language identification
+// backend and the settings for the detector. Each backend gets its own jobs,
+// and therefore its own set of results, so that backends can be compared rather
+// than overwriting one another. The candidate languages and the thresholds
+// change the results too, so unless they are the defaults, a fingerprint of them
+// is added to the destination. The default backend with the default settings
+// keeps the original destination.
+func destination(identifier LanguageIdentifier, settings config.Language) string {
+	d := results.LanguageDestination
+	if identifier.Name() != backendLingua {
+		d += "-" + identifier.Name()
+	}
+	if f := fingerprint(settings); f != "" {
+		d += "-" + f
+	}
+	return d
+}
+
+// fingerprint returns a short hash of the settings which change the results of
+// the language detector, other than the backend, or an empty string if they are
+// the defaults. The order and case of the languages don't matter.
+func fingerprint(settings config.Language) string {
+	defaults := config.Default().Language
+	if len(settings.Languages) == 0 &&
+		settings.MinConfidence == defaults.MinConfidence &&
+		settings.MinLength == defaults.MinLength &&
+		settings.SecondaryShare == defaults.SecondaryShare {
+		return ""
+	}
+
+	langs := make([]string, len(settings.Languages))
+	for i, l := range settings.Languages {
+		langs[i] = strings.ToLower(l)
+	}
+	sort.Strings(langs)
+	s := fmt.Sprintf("languages=%s;min_confidence=%g;min_length=%d;secondary_share=%g",
+		strings.Join(langs, ","), settings.MinConfidence, settings.MinLength, settings.SecondaryShare)
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:4])
+}
+
+// ldetector is the detector used for every sentence. By default it uses lingua
+// with every language it knows about and accepts its most likely guess. The
+// application replaces it at startup with one built from the configuration.
+var ldetector = &detector{
+	identifier: &linguaIdentifier{detector: allLanguagesLingua},
+}
+
+// detector wraps a language identification backend with the thresholds for
+// accepting its guesses. Sentences shorter than minLength characters, or whose
+// most likely language has a confidence below minConfidence, are recorded as
+// `UND`. Very short OCR fragments are otherwise often identified as languages
+// such as Latin or Tagalog.
+type detector struct {
+	identifier    LanguageIdentifier
+	minConfidence float64
+	minLength     int
+}
+
+// newDetector creates a detector using a backend with the given thresholds.
+func newDetector(identifier LanguageIdentifier, minConfidence float64, minLength int) (*detector, error) {
+	if minConfidence < 0 || minConfidence > 1 {
+		return nil, fmt.Errorf("Minimum confidence must be between 0 and 1, not %v", minConfidence)
+	}
+	if minLength < 0 {
+		return nil, fmt.Errorf("Minimum sentence length cannot be negative, not %v", minLength)
+	}
+
+	d := &detector{
+		identifier:    identifier,
+		minConfidence: minConfidence,
+		minLength:     minLength,
+	}
+
+	return d, nil
+}
+
+// detect returns the ISO 639-3 code for the most likely language of a sentence
+// along with the backend's confidence in that language. If no language can be
+// detected, or the sentence does not meet the thresholds, the language is `UND`.
+func (d *detector) detect(s string) (string, float64) {
+	if utf8.RuneCountInString(strings.TrimSpace(s)) < d.minLength {
+		return "UND", 0
+	}
+
+	lang, confidence := d.identifier.Identify(s)
+	if lang == "UND" || confidence < d.minConfidence {
+		return "UND", 0
+	}
+
+	return lang, confidence
+}
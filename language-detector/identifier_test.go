@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lmullen/cchc/common/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIdentifier(t *testing.T) {
+	t.Parallel()
+
+	for _, backend := range []string{backendLingua, backendWhatlang, backendCombined} {
+		_, err := newIdentifier(backend, []string{"eng", "deu", "spa"}, false)
+		assert.NoError(t, err, backend)
+
+		_, err = newIdentifier(backend, []string{"eng", "xyz"}, false)
+		assert.Error(t, err, "unknown language codes are rejected by %s", backend)
+	}
+
+	_, err := newIdentifier(backendLingua, []string{"eng"}, false)
+	assert.Error(t, err, "a single candidate language is rejected")
+
+	_, err = newIdentifier("google", nil, false)
+	assert.Error(t, err, "unknown backends are rejected")
+}
+
+func TestIdentifiers(t *testing.T) {
+	t.Parallel()
+
+	sentence := "Ese reloj fue un regalo de mi mujer y lo llevo todos los días."
+
+	for _, backend := range []string{backendLingua, backendWhatlang, backendCombined} {
+		for _, low := range []bool{false, true} {
+			id, err := newIdentifier(backend, nil, low)
+			require.NoError(t, err)
+			lang, conf := id.Identify(sentence)
+			assert.Equal(t, "SPA", lang, id.Name())
+			assert.Greater(t, conf, 0.0, id.Name())
+			assert.LessOrEqual(t, conf, 1.0, id.Name())
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	t.Parallel()
+
+	defaults := config.Default().Language
+	l, _ := newIdentifier(backendLingua, nil, false)
+	assert.Equal(t, "language", destination(l, defaults))
+	l, _ = newIdentifier(backendLingua, nil, true)
+	assert.Equal(t, "language-lingua-low", destination(l, defaults))
+	w, _ := newIdentifier(backendWhatlang, nil, false)
+	assert.Equal(t, "language-whatlang", destination(w, defaults))
+	c, _ := newIdentifier(backendCombined, nil, true)
+	assert.Equal(t, "language-combined-low", destination(c, defaults))
+
+	// Other settings get their own destination, whatever the order of the
+	// languages
+	settings := defaults
+	settings.Languages = config.Languages{"eng", "deu"}
+	d := destination(w, settings)
+	assert.Regexp(t, `^language-whatlang-[0-9a-f]{8}$`, d)
+	settings.Languages = config.Languages{"DEU", "eng"}
+	assert.Equal(t, d, destination(w, settings))
+	settings.MinLength = 20
+	assert.NotEqual(t, d, destination(w, settings))
+	l, _ = newIdentifier(backendLingua, nil, false)
+	settings = defaults
+	settings.SecondaryShare = 0.2
+	assert.Regexp(t, `^language-[0-9a-f]{8}$`, destination(l, settings))
+}
+
+func TestNewDetector(t *testing.T) {
+	t.Parallel()
+
+	id, err := newIdentifier(backendLingua, nil, false)
+	require.NoError(t, err)
+
+	_, err = newDetector(id, 0.5, 20)
+	assert.NoError(t, err)
+
+	_, err = newDetector(id, 1.5, 20)
+	assert.Error(t, err, "confidence must be between 0 and 1")
+
+	_, err = newDetector(id, 0.5, -1)
+	assert.Error(t, err, "length cannot be negative")
+}
+
+func TestDetectorThresholds(t *testing.T) {
+	t.Parallel()
+
+	sentence := "Ese reloj fue un regalo de mi mujer."
+	id, err := newIdentifier(backendLingua, nil, false)
+	require.NoError(t, err)
+
+	d, err := newDetector(id, 0, 0)
+	require.NoError(t, err)
+	lang, conf := d.detect(sentence)
+	assert.Equal(t, "SPA", lang)
+	assert.Greater(t, conf, 0.0)
+
+	// Short fragments are not identified
+	d, err = newDetector(id, 0, 50)
+	require.NoError(t, err)
+	lang, _ = d.detect(sentence)
+	assert.Equal(t, "UND", lang)
+
+	// Guesses below the minimum confidence are not accepted
+	d, err = newDetector(id, 1, 0)
+	require.NoError(t, err)
+	lang, _ = d.detect("Tu es")
+	assert.Equal(t, "UND", lang)
+
+	// Restricting the candidate languages changes the guess
+	restricted, err := newIdentifier(backendLingua, []string{"eng", "deu"}, false)
+	require.NoError(t, err)
+	d, err = newDetector(restricted, 0, 0)
+	require.NoError(t, err)
+	lang, _ = d.detect(sentence)
+	assert.Contains(t, []string{"ENG", "DEU"}, lang)
+}
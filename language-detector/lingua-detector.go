@@ -1,5 +1,75 @@
 package main
 
-import "github.com/pemistahl/lingua-go"
+import (
+	"errors"
+	"fmt"
+	"strings"
 
-var ldetector = lingua.NewLanguageDetectorBuilder().FromAllLanguages().Build()
+	"github.com/pemistahl/lingua-go"
+)
+
+// allLanguagesLingua is the default lingua detector, which considers every
+// language that lingua knows about.
+var allLanguagesLingua = lingua.NewLanguageDetectorBuilder().FromAllLanguages().Build()
+
+// linguaIdentifier identifies languages using lingua. In low accuracy mode
+// lingua is considerably faster, but less accurate on short sentences.
+type linguaIdentifier struct {
+	detector    lingua.LanguageDetector
+	lowAccuracy bool
+}
+
+// newLinguaIdentifier builds a lingua backend. If any ISO 639-3 language codes
+// are passed in, then only those languages will be considered.
+func newLinguaIdentifier(codes []string, lowAccuracy bool) (*linguaIdentifier, error) {
+	l := &linguaIdentifier{lowAccuracy: lowAccuracy}
+
+	if len(codes) == 0 && !lowAccuracy {
+		l.detector = allLanguagesLingua
+		return l, nil
+	}
+
+	var builder lingua.LanguageDetectorBuilder
+	if len(codes) == 0 {
+		builder = lingua.NewLanguageDetectorBuilder().FromAllLanguages()
+	} else {
+		var languages []lingua.Language
+		for _, code := range codes {
+			iso := lingua.GetIsoCode639_3FromValue(strings.TrimSpace(code))
+			if iso == lingua.UnknownIsoCode639_3 {
+				return nil, fmt.Errorf("%s is not an ISO 639-3 code for a language that lingua can detect", code)
+			}
+			languages = append(languages, lingua.GetLanguageFromIsoCode639_3(iso))
+		}
+		if len(languages) < 2 {
+			return nil, errors.New("At least two languages must be provided to restrict the candidate languages")
+		}
+		builder = lingua.NewLanguageDetectorBuilder().FromLanguages(languages...)
+	}
+	if lowAccuracy {
+		builder = builder.WithLowAccuracyMode()
+	}
+	l.detector = builder.Build()
+
+	return l, nil
+}
+
+// Identify returns the most likely language and lingua's confidence in it.
+func (l *linguaIdentifier) Identify(s string) (string, float64) {
+	values := l.detector.ComputeLanguageConfidenceValues(s)
+	if len(values) == 0 || values[0].Value() == 0 {
+		return "UND", 0
+	}
+	if len(values) > 1 && values[0].Value() == values[1].Value() {
+		return "UND", 0
+	}
+	return values[0].Language().IsoCode639_3().String(), values[0].Value()
+}
+
+// Name returns the name of the backend.
+func (l *linguaIdentifier) Name() string {
+	if l.lowAccuracy {
+		return backendLingua + "-low"
+	}
+	return backendLingua
+}
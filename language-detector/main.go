@@ -14,9 +14,8 @@ import (
 
 var app App
 
-const queue = "language"
-const waittime = 15 * time.Minute
-const jobtimeout = 120 * time.Second
+// The destination for jobs, which depends on the language identification backend
+var queue = "language"
 
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -42,6 +41,7 @@ func main() {
 		log.Fatal("Error initializing application: ", err)
 	}
 	defer app.Shutdown()
+	app.Health.Ready(ctx)
 
 	wg := &sync.WaitGroup{}
 
@@ -2,77 +2,227 @@ package main
 
 import (
 	"context"
+	"time"
 
+	"github.com/lmullen/cchc/common/items"
 	"github.com/lmullen/cchc/common/jobs"
-	log "github.com/sirupsen/logrus"
+	"github.com/lmullen/cchc/common/logging"
+	"github.com/lmullen/cchc/common/metrics"
+	"github.com/lmullen/cchc/common/results"
 )
 
-func processDocument(job *jobs.FullText) error {
+// checkpointInterval is how often progress on a long-running job is saved, so
+// that the job can be resumed if the language detector is stopped.
+const checkpointInterval = 1 * time.Minute
+
+func processDocument(ctx context.Context, job *jobs.FullText) error {
 
 	// We've received a job. We need to calculate the language stats if possible,
 	// save the results to the database, and if not, then skip the job.
+	//
+	// There is no time limit on the job as a whole, because very large items can
+	// legitimately take a long time. Instead, each database call gets its own
+	// timeout, and progress is checkpointed so the job can be resumed.
 
-	// This is a job we are processing so fail if it takes too long.
-	ctx, cancel := context.WithTimeout(context.Background(), jobtimeout)
-	defer cancel()
-
-	// The job has the item we need, so get the item from the database.
-	item, err := app.ItemsRepo.Get(ctx, job.ItemID)
+	// The job has the document we need, so get it from its source.
+	timeout, cancel := context.WithTimeout(context.Background(), app.Config.DBTimeout)
+	doc, err := app.Documents.Get(timeout, job.Source, job.ItemID)
+	cancel()
 	if err != nil {
 		job.Fail()
-		errSave := app.JobsRepo.SaveFullText(ctx, job)
-		if errSave != nil {
-			log.WithError(err).WithField("job", job).Error("Error saving failed job status")
-		}
+		saveJob(ctx, job)
 		return err
 	}
 
-	// Get the full text for the item
-	pages, has := item.FullText()
+	// Get the full text for the document
+	pages, has := doc.FullText()
 
 	// It's possible we don't have full text. If so, skip the job.
 	if !has {
 		job.Skip()
-		errSave := app.JobsRepo.SaveFullText(ctx, job)
-		if errSave != nil {
-			log.WithError(err).WithField("job", job).Error("Error saving job status")
-		}
+		saveJob(ctx, job)
 		return nil
 	}
 
-	// Make a results map that will be shared for all pages in the item.
-	results := make(LanguageStats)
+	// Make a stats map that will be shared for all pages in the item, and keep
+	// track of the language spans on each page. If the job was interrupted
+	// before, pick up where it left off.
+	state, err := loadCheckpoint(ctx, job)
+	if err != nil {
+		job.Fail()
+		saveJob(ctx, job)
+		return err
+	}
 
-	for _, p := range pages {
-		err = CalculateLanguages(p.Text, results)
-		if err != nil {
-			job.Fail()
-			errSave := app.JobsRepo.SaveFullText(ctx, job)
-			if errSave != nil {
-				log.WithError(err).WithField("job", job).Error("Error saving job status")
+	keepSpans := app.Config.Language.Output != outputItems
+	err = detectPages(ctx, pages, state, keepSpans, checkpointInterval, func(state *jobState) error {
+		return saveCheckpoint(ctx, job, state)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			// The detector is shutting down, so save what we've done so far and
+			// put the job back in the queue.
+			return interruptJob(ctx, job, state)
+		}
+		job.Fail()
+		saveJob(ctx, job)
+		return err
+	}
+
+	// Derive an item-level classification from the language stats
+	c := Classify(state.Stats, doc.CatalogLanguages(), app.Config.Language.SecondaryShare)
+
+	// Save all the results along with the status of the job, so that a job only
+	// has results if it finished.
+	return finishJob(ctx, job, func(ctx context.Context, res results.Repository) error {
+		if app.Config.Language.Output != outputSpans {
+			err := res.SaveLanguages(ctx, job.ID, job.ItemID, state.Stats)
+			if err != nil {
+				return err
+			}
+		}
+		if keepSpans {
+			// Most of the spans were saved with the checkpoints
+			err := res.MoveCheckpointSpans(ctx, job.ID)
+			if err != nil {
+				return err
+			}
+			err = res.SaveLanguageSpans(ctx, languageSpans(job, state.Spans))
+			if err != nil {
+				return err
+			}
+		}
+		return res.SaveLanguageClassification(ctx, &results.LanguageClassification{
+			JobID:              job.ID,
+			ItemID:             job.ItemID,
+			PrimaryLanguage:    c.Primary,
+			SecondaryLanguages: c.Secondary,
+			Multilingual:       c.Multilingual,
+			Diversity:          c.Diversity,
+			Sentences:          c.Sentences,
+			CatalogAgreement:   c.CatalogAgreement,
+		})
+	})
+
+}
+
+// detectPages identifies the language of each sentence on the pages, starting
+// where the state left off, and adds it to the stats in the state and, if
+// spans are kept, to the spans in the state. The checkpoint function is called
+// with the state whenever the interval has passed since the last checkpoint;
+// it may remove the spans which are complete from the state, so that they are
+// not all kept in memory. If the context is cancelled, the state records the
+// work done so far.
+func detectPages(ctx context.Context, pages []items.PlainText, state *jobState, keepSpans bool,
+	interval time.Duration, checkpoint func(state *jobState) error) error {
+	b := &spanBuilder{spans: state.Spans}
+	defer func() { state.Spans = b.spans }()
+	lastCheckpoint := time.Now()
+
+	for state.Page < len(pages) {
+		app.Health.Progress()
+		b.page = state.Page
+		err := StreamSentencesFrom(pages[state.Page].Text, state.Char, func(s Sentence) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
 			}
+			// A single page can take a long time, so record progress for each
+			// sentence, and checkpoint partway through the page if need be.
+			app.Health.Progress()
+			state.Stats.incrementKey(s.Language)
+			if keepSpans {
+				b.add(s)
+			}
+			state.Char = s.End
+			if time.Since(lastCheckpoint) >= interval {
+				state.Spans = b.spans
+				err := checkpoint(state)
+				b.spans = state.Spans
+				if err != nil {
+					return err
+				}
+				lastCheckpoint = time.Now()
+			}
+			return nil
+		})
+		if err != nil {
 			return err
 		}
+		state.Page++
+		state.Char = 0
 	}
 
-	// This is where we should save the results to the database. But for now, just print them.
-	err = app.ResultsRepo.SaveLanguages(ctx, job.ID, job.ItemID, results)
+	return nil
+}
+
+// languageSpans converts the spans on a page into results for the job.
+func languageSpans(job *jobs.FullText, spans []Span) []*results.LanguageSpan {
+	out := make([]*results.LanguageSpan, 0, len(spans))
+	for _, s := range spans {
+		out = append(out, &results.LanguageSpan{
+			JobID:      job.ID,
+			ItemID:     job.ItemID,
+			Page:       s.Page,
+			StartChar:  s.Start,
+			EndChar:    s.End,
+			Sentences:  s.Sentences,
+			Language:   s.Language,
+			Confidence: s.Confidence,
+		})
+	}
+	return out
+}
+
+// finishJob saves the results for a job, deletes its checkpoint, and marks it
+// as finished, all in one transaction. If any of that fails, the job is marked
+// as failed and has no results.
+func finishJob(ctx context.Context, job *jobs.FullText, save func(ctx context.Context, res results.Repository) error) error {
+	timeout, cancel := context.WithTimeout(context.Background(), app.Config.DBTimeout)
+	defer cancel()
+
+	err := func() error {
+		tx, err := app.DB.Begin(timeout)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(timeout) // Roll back the transaction if something goes wrong
+
+		err = save(timeout, app.ResultsRepo.WithTx(tx))
+		if err != nil {
+			return err
+		}
+		jobsRepo := app.JobsRepo.WithTx(tx)
+		err = jobsRepo.DeleteCheckpoint(timeout, job.ID)
+		if err != nil {
+			return err
+		}
+		job.Finish()
+		err = jobsRepo.SaveFullText(timeout, job)
+		if err != nil {
+			return err
+		}
+		return tx.Commit(timeout)
+	}()
 	if err != nil {
 		job.Fail()
-		errSave := app.JobsRepo.SaveFullText(ctx, job)
-		if errSave != nil {
-			log.WithError(err).WithField("job", job).Error("Error saving job status")
-		}
+		saveJob(ctx, job)
 		return err
 	}
 
-	// The job was successful
-	job.Finish()
-	errSave := app.JobsRepo.SaveFullText(ctx, job)
-	if errSave != nil {
-		log.WithError(err).WithField("job", job).Error("Error saving job status")
-	}
-
+	metrics.JobDone(job)
 	return nil
+}
 
+// saveJob saves the status of the job, logging rather than returning any error
+// since the job has already succeeded or failed. The context is only used for
+// logging: the status is saved with its own timeout even while shutting down.
+func saveJob(ctx context.Context, job *jobs.FullText) {
+	timeout, cancel := context.WithTimeout(context.Background(), app.Config.DBTimeout)
+	defer cancel()
+	err := app.JobsRepo.SaveFullText(timeout, job)
+	if err != nil {
+		logging.From(ctx).WithError(err).WithField(logging.FieldStatus, job.Status).Error("Error saving job status")
+		return
+	}
+	metrics.JobDone(job)
 }
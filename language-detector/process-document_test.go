@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmullen/cchc/common/health"
+	"github.com/lmullen/cchc/common/items"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// Detecting languages records progress for the health checks
+	app.Health = health.New(nil, time.Minute, time.Minute)
+	os.Exit(m.Run())
+}
+
+func TestDetectPages(t *testing.T) {
+	t.Parallel()
+
+	pages := []items.PlainText{
+		{Text: sampleParagraph + sampleParagraph},
+		{Text: "Ich möchte ein Bier. " + sampleParagraph},
+	}
+	var want []Span
+	for i, p := range pages {
+		sentences, err := DetectSentences(p.Text)
+		require.NoError(t, err)
+		want = append(want, CalculateSpans(i, sentences)...)
+	}
+
+	// Checkpointing after every sentence takes the complete spans out of the
+	// state, which keeps only the span that the next sentence might extend
+	state := &jobState{Stats: make(LanguageStats)}
+	var saved []Span
+	err := detectPages(context.Background(), pages, state, true, 0, func(state *jobState) error {
+		saved = append(saved, state.takeSpans()...)
+		assert.LessOrEqual(t, len(state.Spans), 1)
+		return nil
+	})
+	require.NoError(t, err)
+	saved = append(saved, state.Spans...)
+
+	require.Len(t, saved, len(want))
+	for i := range want {
+		assert.Equal(t, []int{want[i].Page, want[i].Start, want[i].End, want[i].Sentences},
+			[]int{saved[i].Page, saved[i].Start, saved[i].End, saved[i].Sentences})
+		assert.Equal(t, want[i].Language, saved[i].Language)
+		assert.InDelta(t, want[i].Confidence, saved[i].Confidence, 1e-9)
+	}
+	assert.Equal(t, len(pages), state.Page)
+}
+
+// BenchmarkDetectPagesWithSpans detects the languages in items with more and
+// more pages of text which switches language every sentence or two, keeping the
+// spans and checkpointing as the language detector does. The held-spans metric
+// is the most spans kept in memory at any checkpoint, and the checkpoint metric
+// is the size of the largest checkpoint. Both should stay flat as the number of
+// pages grows.
+func BenchmarkDetectPagesWithSpans(b *testing.B) {
+	// Only consider the languages in the text, so that detection is quick
+	identifier, err := newIdentifier(backendLingua, []string{"ENG", "DEU"}, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	previous := ldetector
+	ldetector = &detector{identifier: identifier}
+	defer func() { ldetector = previous }()
+
+	page := items.PlainText{Text: strings.Repeat(sampleParagraph, 4)}
+	for _, n := range []int{10, 100, 1000} {
+		pages := make([]items.PlainText, n)
+		for i := range pages {
+			pages[i] = page
+		}
+		b.Run(fmt.Sprintf("%dpages", n), func(b *testing.B) {
+			b.ReportAllocs()
+			var held, largest int
+			for i := 0; i < b.N; i++ {
+				state := &jobState{Stats: make(LanguageStats)}
+				err := detectPages(context.Background(), pages, state, true, time.Millisecond, func(state *jobState) error {
+					if len(state.Spans) > held {
+						held = len(state.Spans)
+					}
+					// Saving the complete spans is left to the database
+					state.takeSpans()
+					data, err := json.Marshal(state)
+					if err != nil {
+						return err
+					}
+					if len(data) > largest {
+						largest = len(data)
+					}
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(held), "held-spans")
+			b.ReportMetric(float64(largest), "checkpoint-bytes")
+		})
+	}
+}
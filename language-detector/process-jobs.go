@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/lmullen/cchc/common/jobs"
+	"github.com/lmullen/cchc/common/logging"
+	"github.com/lmullen/cchc/common/metrics"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -18,16 +21,17 @@ func processJobs(ctx context.Context, wg *sync.WaitGroup) {
 			log.Info("Stopped processing jobs")
 			return
 		default:
+			app.Health.Progress()
 			timeoutGet, cancelGet := context.WithTimeout(context.Background(), 15*time.Second)
 			defer cancelGet()
 			job, err := app.JobsRepo.GetReadyJob(timeoutGet, queue)
 			if err != nil {
 				if err == jobs.ErrNoJobs {
-					log.Infof("No ready jobs, so waiting %s to check again", waittime)
+					log.Infof("No ready jobs, so waiting %s to check again", app.Config.WaitTime)
 					select {
 					case <-ctx.Done():
 						return
-					case <-time.After(waittime):
+					case <-time.After(app.Config.WaitTime):
 						log.Info("Checking again whether there are jobs to be processed")
 						continue
 					}
@@ -36,12 +40,21 @@ func processJobs(ctx context.Context, wg *sync.WaitGroup) {
 				continue
 			}
 
-			// TODO work happens here
-			err = processDocument(job)
+			metrics.JobClaimed(job)
+
+			// Each job gets its own correlation ID, so that all the logs for the job
+			// can be joined
+			jobCtx := logging.WithJob(logging.NewCorrelationID(ctx), job)
+			err = processDocument(jobCtx, job)
 			if err != nil {
-				log.WithError(err).WithField("job", job).Error("Error processing job")
+				if errors.Is(err, errInterrupted) {
+					logging.From(jobCtx).Info("Job was interrupted and will be resumed later")
+					continue
+				}
+				logging.From(jobCtx).WithError(err).Error("Error processing job")
+				continue
 			}
-			log.WithField("job", job).Debug("Successfully processed job")
+			logging.From(jobCtx).Debug("Successfully processed job")
 
 		}
 	}
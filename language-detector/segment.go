@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// chunkSize is the maximum number of bytes of text that are passed to the
+// sentence segmenter at once. Segmenting a whole page at once uses memory in
+// proportion to the size of the page, which is a problem for very large items.
+const chunkSize = 32 * 1024
+
+// segmentSentences splits text into sentences and calls fn on each sentence in
+// order, along with the start and end of the sentence as character (not byte)
+// offsets from the beginning of the text. The text is segmented in chunks of
+// bounded size, so memory use does not grow with the size of the text.
+//
+// Since a chunk might end in the middle of a sentence, the last sentence in
+// each chunk is held back and segmented again as the start of the next chunk.
+// Only a single sentence longer than the chunk size will be split.
+func segmentSentences(text string, fn func(sentence string, start, end int) error) error {
+	pos := 0   // Start of the current chunk in bytes
+	chars := 0 // Start of the current chunk in characters
+
+	for pos < len(text) {
+		end := chunkEnd(text, pos, chunkSize)
+		chunk := text[pos:end]
+
+		doc, err := tokenize(chunk)
+		if err != nil {
+			return fmt.Errorf("Error tokenizing text: %w", err)
+		}
+		// Trailing whitespace can come back as an empty sentence, which is not
+		// worth identifying.
+		var all []string
+		for _, s := range doc.Sentences() {
+			if strings.TrimSpace(s.Text) != "" {
+				all = append(all, s.Text)
+			}
+		}
+		sentences := all
+		if end < len(text) && len(all) > 1 {
+			sentences = all[:len(all)-1]
+		}
+
+		// The segmenter only returns the text of the sentences, so keep track of
+		// where we are in the chunk in order to find the offsets of each sentence.
+		cursor := 0
+		for _, s := range sentences {
+			start := cursor
+			if i := strings.Index(chunk[cursor:], s); i >= 0 {
+				start = cursor + i
+			}
+			stop := start + len(s)
+			if stop > len(chunk) {
+				stop = len(chunk)
+			}
+			chars += utf8.RuneCountInString(chunk[cursor:start])
+			startChar := chars
+			chars += utf8.RuneCountInString(chunk[start:stop])
+			cursor = stop
+
+			err = fn(s, startChar, chars)
+			if err != nil {
+				return err
+			}
+		}
+
+		// If every sentence was emitted, or nothing could be located, move on to
+		// the end of the chunk so that we always make progress.
+		if len(sentences) == len(all) || cursor == 0 {
+			chars += utf8.RuneCountInString(chunk[cursor:])
+			cursor = len(chunk)
+		}
+		pos += cursor
+	}
+
+	return nil
+}
+
+// chunkEnd finds where a chunk of at most size bytes starting at pos should
+// end. Where possible the chunk ends at a paragraph break or other whitespace
+// in the second half of the chunk, and it always ends on a character boundary.
+func chunkEnd(text string, pos, size int) int {
+	end := pos + size
+	if end >= len(text) {
+		return len(text)
+	}
+
+	half := pos + size/2
+	if i := strings.LastIndex(text[half:end], "\n\n"); i >= 0 {
+		return half + i + 2
+	}
+	if i := strings.LastIndexFunc(text[half:end], unicode.IsSpace); i >= 0 {
+		_, width := utf8.DecodeRuneInString(text[half+i:])
+		return half + i + width
+	}
+
+	for end > pos && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return end
+}
+
+// byteOffset converts an offset in characters into an offset in bytes. An
+// offset past the end of the text is the end of the text.
+func byteOffset(text string, chars int) int {
+	n := 0
+	for i := range text {
+		if n == chars {
+			return i
+		}
+		n++
+	}
+	return len(text)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleParagraph = "In the beginning was the Word, and the Word was with God. " +
+	"Übrigens ist das Wetter heute schön, nicht wahr? " +
+	"The people that walked in darkness have seen a great light.\n\n"
+
+func TestSegmentSentences(t *testing.T) {
+	t.Parallel()
+
+	// Make the text several chunks long so that sentences fall across chunk
+	// boundaries.
+	text := strings.Repeat(sampleParagraph, 3*chunkSize/len(sampleParagraph))
+	runes := []rune(text)
+
+	var count, last int
+	err := segmentSentences(text, func(s string, start, end int) error {
+		require.GreaterOrEqual(t, start, last, "sentences should be in order")
+		require.Equal(t, s, string(runes[start:end]), "offsets should locate the sentence")
+		last = end
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3*strings.Count(text, sampleParagraph), count)
+}
+
+func TestStreamSentencesFrom(t *testing.T) {
+	t.Parallel()
+
+	text := "Première phrase en français. " + sampleParagraph + sampleParagraph
+	all, err := DetectSentences(text)
+	require.NoError(t, err)
+	require.Greater(t, len(all), 3)
+
+	// Resuming from the end of a sentence gives the rest of the sentences, with
+	// their offsets from the start of the page
+	var rest []Sentence
+	err = StreamSentencesFrom(text, all[1].End, func(s Sentence) error {
+		rest = append(rest, s)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, rest, len(all)-2)
+	for i, s := range rest {
+		want := all[i+2]
+		assert.Equal(t, []int{want.Start, want.End}, []int{s.Start, s.End})
+		assert.Equal(t, want.Language, s.Language)
+		// The detector's arithmetic can differ in the last digit between runs
+		assert.InDelta(t, want.Confidence, s.Confidence, 1e-9)
+	}
+
+	assert.Equal(t, len("Premi"), byteOffset(text, 5))
+	assert.Equal(t, len("Premiè"), byteOffset(text, 6))
+	assert.Equal(t, len(text), byteOffset(text, len([]rune(text))+10))
+}
+
+func TestChunkEnd(t *testing.T) {
+	t.Parallel()
+
+	text := "First paragraph.\n\nSecond paragraph."
+	assert.Equal(t, 18, chunkEnd(text, 0, 24))
+	assert.Equal(t, len(text), chunkEnd(text, 0, 100))
+
+	// Never split a multibyte character, even without any whitespace
+	text = strings.Repeat("ü", 20)
+	end := chunkEnd(text, 0, 11)
+	assert.Equal(t, 10, end)
+}
+
+// BenchmarkSegmentSentences segments increasingly large texts. The peak-heap
+// metric is the most live memory sampled at any point beyond the text itself,
+// which should stay flat as the input grows.
+func BenchmarkSegmentSentences(b *testing.B) {
+	for _, size := range []int{64 << 10, 1 << 20, 8 << 20} {
+		text := strings.Repeat(sampleParagraph, size/len(sampleParagraph))
+		b.Run(fmt.Sprintf("%dKB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(len(text)))
+			var m runtime.MemStats
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				runtime.ReadMemStats(&m)
+				base := m.HeapAlloc
+				n := 0
+				err := segmentSentences(text, func(s string, start, end int) error {
+					n++
+					if n%1000 == 0 {
+						runtime.GC()
+						runtime.ReadMemStats(&m)
+						if m.HeapAlloc > base && m.HeapAlloc-base > peak {
+							peak = m.HeapAlloc - base
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(peak), "peak-heap-bytes")
+		})
+	}
+}
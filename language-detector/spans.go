@@ -0,0 +1,52 @@
+package main
+
+// Span is a run of contiguous sentences on a page which were all identified as
+// the same language. This lets us locate, for example, a German passage inside
+// an English pamphlet, rather than only knowing that the item is multilingual.
+type Span struct {
+	Page       int
+	Start      int
+	End        int
+	Sentences  int
+	Language   string
+	Confidence float64 // Mean confidence of the sentences in the span
+}
+
+// CalculateSpans groups the sentences on a page into runs of the same language.
+// The page is the index of the page within the item.
+func CalculateSpans(page int, sentences []Sentence) []Span {
+	b := &spanBuilder{page: page}
+	for _, s := range sentences {
+		b.add(s)
+	}
+	return b.spans
+}
+
+// spanBuilder groups sentences into spans as they are streamed from the pages
+// of an item. Set page before adding the sentences on each page.
+type spanBuilder struct {
+	page  int
+	spans []Span
+}
+
+// add extends the current span with the sentence if it is on the same page and
+// in the same language, or else starts a new span.
+func (b *spanBuilder) add(s Sentence) {
+	last := len(b.spans) - 1
+	if last >= 0 && b.spans[last].Page == b.page && b.spans[last].Language == s.Language {
+		// Keep a running mean of the confidence within the span
+		n := float64(b.spans[last].Sentences)
+		b.spans[last].Confidence = (b.spans[last].Confidence*n + s.Confidence) / (n + 1)
+		b.spans[last].Sentences++
+		b.spans[last].End = s.End
+		return
+	}
+	b.spans = append(b.spans, Span{
+		Page:       b.page,
+		Start:      s.Start,
+		End:        s.End,
+		Sentences:  1,
+		Language:   s.Language,
+		Confidence: s.Confidence,
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateSpans(t *testing.T) {
+	t.Parallel()
+
+	text := "This is a dummy document. It has a German passage in the middle. Ich möchte ein Bier. Ich möchte zwei Biere. And then it goes back to English."
+
+	sentences, err := DetectSentences(text)
+	require.NoError(t, err)
+	require.Len(t, sentences, 5)
+
+	spans := CalculateSpans(3, sentences)
+	require.Len(t, spans, 3)
+
+	langs := []string{spans[0].Language, spans[1].Language, spans[2].Language}
+	assert.Equal(t, []string{"ENG", "DEU", "ENG"}, langs)
+	assert.Equal(t, []int{2, 2, 1}, []int{spans[0].Sentences, spans[1].Sentences, spans[2].Sentences})
+
+	// The offsets should let us find the German passage in the original text
+	german := []rune(text)[spans[1].Start:spans[1].End]
+	assert.Equal(t, "Ich möchte ein Bier. Ich möchte zwei Biere.", string(german))
+
+	for _, s := range spans {
+		assert.Equal(t, 3, s.Page)
+		assert.Greater(t, s.Confidence, 0.0)
+		assert.LessOrEqual(t, s.Confidence, 1.0)
+	}
+}
+
+func TestSpansDoNotCrossPages(t *testing.T) {
+	t.Parallel()
+
+	b := &spanBuilder{}
+	b.add(Sentence{Start: 0, End: 10, Language: "ENG", Confidence: 1})
+	b.page = 1
+	b.add(Sentence{Start: 0, End: 12, Language: "ENG", Confidence: 1})
+	b.add(Sentence{Start: 12, End: 20, Language: "ENG", Confidence: 1})
+
+	require.Len(t, b.spans, 2)
+	assert.Equal(t, []int{0, 1}, []int{b.spans[0].Page, b.spans[1].Page})
+	assert.Equal(t, 2, b.spans[1].Sentences)
+	assert.Equal(t, 20, b.spans[1].End)
+}
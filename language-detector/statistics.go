@@ -1,8 +1,6 @@
 package main
 
 import (
-	"fmt"
-
 	"github.com/jdkato/prose/v2"
 )
 
@@ -15,6 +13,16 @@ func (s LanguageStats) incrementKey(k string) {
 	s[k]++
 }
 
+// Sentence is a single sentence within a page, along with the language it was
+// identified as. The start and end are character (not byte) offsets from the
+// beginning of the page.
+type Sentence struct {
+	Start      int
+	End        int
+	Language   string
+	Confidence float64
+}
+
 func tokenize(s string) (*prose.Document, error) {
 	doc, err := prose.NewDocument(s,
 		prose.WithExtraction(false),
@@ -27,33 +35,66 @@ func tokenize(s string) (*prose.Document, error) {
 	return doc, nil
 }
 
+// StreamSentences splits a page of text into sentences, identifies the
+// language of each sentence, and calls fn on each sentence in order. The page
+// is processed in chunks, so this is safe to use on very large pages.
+func StreamSentences(text string, fn func(Sentence) error) error {
+	return segmentSentences(text, func(s string, start, end int) error {
+		lang, confidence := ldetector.detect(s)
+		return fn(Sentence{
+			Start:      start,
+			End:        end,
+			Language:   lang,
+			Confidence: confidence,
+		})
+	})
+}
+
+// StreamSentencesFrom is like StreamSentences, but starts from a character
+// offset in the page, such as the end of the last sentence processed before a
+// job was interrupted. The offsets of the sentences are still from the
+// beginning of the page.
+func StreamSentencesFrom(text string, start int, fn func(Sentence) error) error {
+	return StreamSentences(text[byteOffset(text, start):], func(s Sentence) error {
+		s.Start += start
+		s.End += start
+		return fn(s)
+	})
+}
+
+// DetectSentences splits a page of text into sentences and identifies the
+// language of each sentence.
+func DetectSentences(text string) ([]Sentence, error) {
+	var sentences []Sentence
+	err := StreamSentences(text, func(s Sentence) error {
+		sentences = append(sentences, s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sentences, nil
+}
+
 // CalculateLanguages computes the number of sentences identified as each language.
 // You pass in a map of the the languages. This is passed in rather than generated
 // in the function, because we might want to count multiple pages in the same item.
 func CalculateLanguages(text string, results LanguageStats) error {
 
-	doc, err := tokenize(text)
+	sentences, err := DetectSentences(text)
 	if err != nil {
-		return fmt.Errorf("Error tokenizing text: %w", err)
+		return err
 	}
 
-	// Detect language for each sentence and track results
-	for _, s := range doc.Sentences() {
-		// wl := whatlanggo.Detect(s.Text)
-		// if wl.IsReliable() {
-		// 	whatlang.incrementKey(wl.Lang.Iso6393())
-		// } else {
-		// 	whatlang.incrementKey("und")
-		// }
-
-		lang, exists := ldetector.DetectLanguageOf(s.Text)
-		if exists {
-			results.incrementKey(lang.IsoCode639_3().String())
-		} else {
-			results.incrementKey("UND")
-		}
-	}
+	results.add(sentences)
 
 	return nil
 
 }
+
+// add tracks the language of each sentence in the statistics.
+func (s LanguageStats) add(sentences []Sentence) {
+	for _, sentence := range sentences {
+		s.incrementKey(sentence.Language)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abadojack/whatlanggo"
+)
+
+// whatlangIdentifier identifies languages using whatlang, which is much faster
+// than lingua but less accurate.
+type whatlangIdentifier struct {
+	options whatlanggo.Options
+}
+
+// newWhatlangIdentifier builds a whatlang backend. If any ISO 639-3 language
+// codes are passed in, then only those languages will be considered.
+func newWhatlangIdentifier(codes []string) (*whatlangIdentifier, error) {
+	w := &whatlangIdentifier{}
+
+	if len(codes) > 0 {
+		w.options.Whitelist = make(map[whatlanggo.Lang]bool)
+		for _, code := range codes {
+			code = strings.ToLower(strings.TrimSpace(code))
+			lang := whatlanggo.CodeToLang(code)
+			// Unknown codes are returned as the first language in the list
+			if lang.Iso6393() != code {
+				return nil, fmt.Errorf("%s is not an ISO 639-3 code for a language that whatlang can detect", code)
+			}
+			w.options.Whitelist[lang] = true
+		}
+	}
+
+	return w, nil
+}
+
+// Identify returns the most likely language and whatlang's confidence in it.
+func (w *whatlangIdentifier) Identify(s string) (string, float64) {
+	info := whatlanggo.DetectWithOptions(s, w.options)
+	if info.Lang < 0 || info.Confidence == 0 {
+		return "UND", 0
+	}
+	return strings.ToUpper(info.Lang.Iso6393()), info.Confidence
+}
+
+// Name returns the name of the backend.
+func (w *whatlangIdentifier) Name() string {
+	return backendWhatlang
+}
@@ -4,98 +4,147 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/lmullen/cchc/common/config"
+	"github.com/lmullen/cchc/common/corpora"
 	"github.com/lmullen/cchc/common/db"
-	"github.com/lmullen/cchc/common/items"
+	"github.com/lmullen/cchc/common/health"
 	"github.com/lmullen/cchc/common/jobs"
+	"github.com/lmullen/cchc/common/metrics"
 	"github.com/lmullen/cchc/common/results"
+	"github.com/lmullen/cchc/common/sources"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
-// Configuration options that aren't worth exposing as environment variables
-const (
-	apiTimeout = 60 // The timeout limit for API requests in seconds
-)
-
-// The Config type stores configuration which is read from environment variables.
-type Config struct {
-	dbstr    string
-	loglevel string
-}
-
 // The App type shares access to the database and other resources.
 type App struct {
 	DB          *pgxpool.Pool
-	Config      *Config
-	ItemsRepo   items.Repository
+	Metrics     *http.Server
+	Health      *health.Checker
+	Config      *config.Config
+	Documents   sources.Repository
 	ResultsRepo results.Repository
 	JobsRepo    jobs.Repository
+	Bibles      config.Bibles
+	Corpus      string // Set if looking for quotations of a reference corpus
 }
 
 // Init creates a new app and connects to the database or returns an error
 func (app *App) Init() error {
+	// Read the configuration from a file, environment variables, and flags
+	app.Config = config.Default()
+	app.Config.Quotations.Bibles = defaultBibles
+	err := app.Config.Load("cchc-predictor", os.Args[1:])
+	if err != nil {
+		return err
+	}
+	err = app.Config.SetupLogging("cchc-predictor")
+	if err != nil {
+		return err
+	}
 	log.Info("Starting the prediction modeler fetcher")
-
-	app.Config = &Config{}
+	app.Config.Log()
+
+	// Look for quotations of a reference corpus instead of the Bible, if one
+	// is configured. Its index is built once the database is connected.
+	if app.Config.Quotations.Corpus != "" {
+		app.Corpus = strings.ToLower(app.Config.Quotations.Corpus)
+		if app.Corpus == corpora.BibleID {
+			return errors.New("The reference corpus cannot be the Bible; set the reference texts with CCHC_BIBLES instead")
+		}
+		queue = "quotations-" + app.Corpus
+	} else {
+		app.Bibles = app.Config.Quotations.Bibles
+		for _, b := range app.Bibles {
+			_, err := os.Stat(b.Payload)
+			if err != nil {
+				return fmt.Errorf("The payload for the reference text %s cannot be read: %w", b.ID, err)
+			}
+			log.WithField("translation", b.ID).WithField("payload", b.Payload).Info("Looking for quotations in reference text")
+		}
+		queue = destination(app.Bibles)
+	}
+	log.WithField("destination", queue).Info("Creating and running jobs for the destination")
 
 	// Set a timeout for getting the application set up
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	ll, exists := os.LookupEnv("CCHC_LOGLEVEL")
-	if !exists {
-		ll = "info"
+	// Connect to the database and create the various repositories needed
+	pool, err := db.Connect(ctx, app.Config.DBStr, "cchc-predictor-aggregator")
+	if err != nil {
+		return fmt.Errorf("Failed to connect to database: %w", err)
 	}
-	app.Config.loglevel = ll
+	app.DB = pool
+	app.Documents = sources.NewSourcesRepo(pool)
+	app.JobsRepo = jobs.NewJobsRepo(pool)
+	app.ResultsRepo = results.NewRepo(pool)
+	log.Info("Connected to the database successfully")
 
-	// Set the logging level
-	switch app.Config.loglevel {
-	case "error":
-		log.SetLevel(log.ErrorLevel)
-	case "warn":
-		log.SetLevel(log.WarnLevel)
-	case "info":
-		log.SetLevel(log.InfoLevel)
-	case "debug":
-		log.SetLevel(log.DebugLevel)
-	case "trace":
-		log.SetLevel(log.TraceLevel)
+	// Serve metrics about the database and jobs for Prometheus, along with
+	// health checks. The main loop may wait for ready jobs or for a whole batch
+	// to run through the model, whichever is longer.
+	err = metrics.RegisterPool(pool)
+	if err != nil {
+		return err
 	}
-
-	// Connect to the database and create the various repositories needed
-	dbstr, exists := os.LookupEnv("CCHC_DBSTR")
-	if !exists {
-		return errors.New("CCHC_DBSTR environment variable is not set")
+	longestWait := app.Config.WaitTime
+	if app.Config.JobTimeout > longestWait {
+		longestWait = app.Config.JobTimeout
+	}
+	app.Health = health.New(pool.Ping, app.Config.ProgressWindow, longestWait)
+	mux := http.NewServeMux()
+	app.Health.Register(mux)
+	app.Metrics, err = metrics.Serve(app.Config.MetricsAddr, mux)
+	if err != nil {
+		return err
 	}
-	app.Config.dbstr = dbstr
 
-	db, err := db.Connect(ctx, app.Config.dbstr, "cchc-predictor-aggregator")
+	// Make sure the database schema is the version this application expects
+	err = db.CheckSchema(ctx, app.Config.DBStr, app.Config.AutoMigrate)
 	if err != nil {
-		return fmt.Errorf("Failed to connect to database: %w", err)
+		return err
+	}
+	log.WithField("version", db.RequiredVersion).Info("The database schema is at the required version")
+
+	// Build the index for the reference corpus, which takes the place of the
+	// Bible payloads
+	if app.Corpus != "" {
+		indexCtx, cancel := context.WithTimeout(context.Background(), app.Config.JobTimeout)
+		defer cancel()
+		bible, err := buildCorpusIndex(indexCtx, corpora.NewCorporaRepo(pool), app.Corpus)
+		if err != nil {
+			return fmt.Errorf("Failed to build the index for the reference corpus %s: %w", app.Corpus, err)
+		}
+		app.Bibles = config.Bibles{bible}
+		log.WithField("corpus", bible.ID).WithField("payload", bible.Payload).Info("Looking for quotations in reference corpus")
 	}
-	app.DB = db
-	app.ItemsRepo = items.NewItemRepo(db)
-	app.JobsRepo = jobs.NewJobsRepo(db)
-	app.ResultsRepo = results.NewRepo(db)
-	log.Info("Connected to the database successfully")
 
 	// Initialize the results repo
-	res := results.NewRepo(db)
+	res := results.NewRepo(pool)
 	app.ResultsRepo = res
 
 	// Initialize the jobs repo
-	jobs := jobs.NewJobsRepo(db)
+	jobs := jobs.NewJobsRepo(pool)
 	app.JobsRepo = jobs
 
 	return nil
 }
 
-// Shutdown closes the connection to the database.
+// Shutdown stops serving metrics and closes the connection to the database.
 func (app *App) Shutdown() {
+	if app.Metrics != nil {
+		app.Metrics.Close()
+	}
+	if app.Corpus != "" && len(app.Bibles) == 1 {
+		os.Remove(app.Bibles[0].Payload)
+	}
 	log.Info("Closing the connection to the database")
 	app.DB.Close()
 	log.Info("Shutdown the prediction model fetcher")
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/lmullen/cchc/common/config"
+)
+
+// defaultBibles are the reference texts used if no others are configured.
+var defaultBibles = config.Bibles{{ID: "kjv", Payload: "bible-payload.rda"}}
+
+// destination returns the job destination for looking for quotations in the
+// reference texts. An item searched against one set of translations has not
+// been searched against another, so unless the translations are the defaults, a
+// fingerprint of their IDs is added to the destination and a new set of jobs is
+// created when they change. The order of the translations doesn't matter, and
+// neither do the paths to their payloads.
+func destination(bibles config.Bibles) string {
+	ids := bibleIDs(bibles)
+	if ids == bibleIDs(defaultBibles) {
+		return "quotations"
+	}
+	sum := sha256.Sum256([]byte(ids))
+	return "quotations-" + hex.EncodeToString(sum[:4])
+}
+
+// bibleIDs returns the sorted IDs of the reference texts, separated by commas.
+func bibleIDs(bibles config.Bibles) string {
+	ids := make([]string, len(bibles))
+	for i, b := range bibles {
+		ids[i] = b.ID
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
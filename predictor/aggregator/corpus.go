@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/lmullen/cchc/common/config"
+	"github.com/lmullen/cchc/common/corpora"
+)
+
+// buildCorpusIndex writes out the passages in a reference corpus and builds the
+// payload which the prediction model uses to look for quotations of them, in
+// place of a Bible payload.
+func buildCorpusIndex(ctx context.Context, repo corpora.Repository, corpusID string) (config.Bible, error) {
+	corpus, err := repo.Get(ctx, corpusID)
+	if err != nil {
+		return config.Bible{}, err
+	}
+	if corpus.Passages == 0 {
+		return config.Bible{}, errors.New("the reference corpus has no passages")
+	}
+	passages, err := repo.Passages(ctx, corpusID)
+	if err != nil {
+		return config.Bible{}, err
+	}
+
+	passagesFile, err := writePassagesCSV(passages)
+	if err != nil {
+		return config.Bible{}, err
+	}
+	defer os.Remove(passagesFile)
+
+	payload, err := os.CreateTemp("", "corpus-payload-*.rda")
+	if err != nil {
+		return config.Bible{}, err
+	}
+	payload.Close()
+
+	cmd := exec.CommandContext(ctx,
+		"Rscript", "/predictor/build-index.R",
+		"--out", payload.Name(),
+		passagesFile,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		os.Remove(payload.Name())
+		return config.Bible{}, fmt.Errorf("Problem building the index in R: %w\n%s", err, output)
+	}
+
+	return config.Bible{ID: corpus.ID, Payload: payload.Name()}, nil
+}
+
+// Write out a CSV with the passages in a corpus for building its index
+func writePassagesCSV(passages []*corpora.Passage) (string, error) {
+	f, err := os.CreateTemp("", "passages-*.csv")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	err = w.Write([]string{"passage_id", "text"})
+	if err != nil {
+		return "", fmt.Errorf("Error writing temporary CSV: %w", err)
+	}
+	for _, p := range passages {
+		err := w.Write([]string{p.ID, p.Text})
+		if err != nil {
+			return "", fmt.Errorf("Error writing temporary CSV: %w", err)
+		}
+	}
+	w.Flush()
+
+	return f.Name(), w.Error()
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/lmullen/cchc/common/jobs"
+	"github.com/lmullen/cchc/common/logging"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -21,14 +22,14 @@ func createJobs(ctx context.Context, wg *sync.WaitGroup) {
 		default:
 			timeout, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 			defer cancel()
-			job, err := app.JobsRepo.CreateJobForUnqueued(timeout, queue)
+			job, err := app.JobsRepo.CreateJobForUnqueued(timeout, queue, app.Config.Sources...)
 			if err != nil {
 				if err == jobs.ErrAllQueued {
-					log.Infof("All jobs for %s are queued, so waiting %s to check again", queue, waittime)
+					log.Infof("All jobs for %s are queued, so waiting %s to check again", queue, app.Config.WaitTime)
 					select {
 					case <-ctx.Done():
 						return
-					case <-time.After(waittime):
+					case <-time.After(app.Config.WaitTime):
 						log.Info("Checking again whether jobs need to be created")
 						continue
 					}
@@ -45,7 +46,7 @@ func createJobs(ctx context.Context, wg *sync.WaitGroup) {
 				}
 				continue
 			}
-			log.WithField("job", job).Debug("Created job")
+			log.WithFields(logging.Job(job)).Debug("Created job")
 		}
 	}
 }
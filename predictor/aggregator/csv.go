@@ -32,23 +32,38 @@ func writeDocsCSV(docs []*Doc) (string, error) {
 	return f.Name(), nil
 }
 
-// Read in results of the prediction model and write them to the database.
-func processPredictionsCSV(ctx context.Context, path string) error {
+// predictions are the rows returned by the prediction model for a reference
+// text or corpus.
+type predictions struct {
+	translation string
+	rows        [][]string
+}
+
+// Read in the results of the prediction model for a reference text or corpus.
+func readPredictionsCSV(path string, translation string) (*predictions, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer f.Close()
 
 	r := csv.NewReader(f)
 
-	predictions, err := r.ReadAll()
+	rows, err := r.ReadAll()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, p := range predictions {
+	return &predictions{translation: translation, rows: rows}, nil
+}
+
+// save writes the predictions to the database.
+func (pred *predictions) save(ctx context.Context, res results.Repository) error {
+	for _, p := range pred.rows {
 		// log.Debug(p)
+		if len(p) < 10 {
+			return fmt.Errorf("Expected 10 columns in predictions but got %v", len(p))
+		}
 		jobID, err := uuid.Parse(p[0])
 		if err != nil {
 			return err
@@ -57,8 +72,29 @@ func processPredictionsCSV(ctx context.Context, path string) error {
 		if err != nil {
 			return err
 		}
-		q := results.NewQuotation(jobID, p[1], p[2], p[3], prob)
-		err = app.ResultsRepo.SaveQuotation(ctx, q)
+		passage, err := parsePassage(p[5:10])
+		if err != nil {
+			return err
+		}
+		// Quotations of a reference corpus are keyed by the passage ID, which
+		// the model reports as the verse ID
+		if app.Corpus != "" {
+			err = res.SaveCorpusQuotation(ctx, &results.CorpusQuotation{
+				JobID:       jobID,
+				ItemID:      p[1],
+				CorpusID:    pred.translation,
+				PassageID:   p[3],
+				Probability: prob,
+				Passage:     passage,
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		q := results.NewQuotation(jobID, p[1], p[2], p[3], prob, pred.translation)
+		q.Passage = passage
+		err = res.SaveQuotation(ctx, q)
 		if err != nil {
 			return err
 		}
@@ -66,3 +102,25 @@ func processPredictionsCSV(ctx context.Context, path string) error {
 
 	return nil
 }
+
+// parsePassage reads the page, offsets, and context of the passage matching a
+// quotation. The prediction model leaves the columns empty if it could not
+// locate the passage.
+func parsePassage(cols []string) (*results.QuotedPassage, error) {
+	page, start, end, contextStart, context := cols[0], cols[1], cols[2], cols[3], cols[4]
+	if page == "" || start == "" || end == "" || contextStart == "" {
+		return nil, nil
+	}
+	p := &results.QuotedPassage{Context: context}
+	var err error
+	for _, v := range []struct {
+		col   string
+		value *int
+	}{{page, &p.Page}, {start, &p.StartChar}, {end, &p.EndChar}, {contextStart, &p.ContextStart}} {
+		*v.value, err = strconv.Atoi(v.col)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing passage for quotation: %w", err)
+		}
+	}
+	return p, nil
+}
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"strconv"
+
 	"github.com/google/uuid"
 	"github.com/lmullen/cchc/common/items"
 	"github.com/lmullen/cchc/common/jobs"
 )
 
-// NewDoc creates a document from a job, item, and page of an item
-func NewDoc(job *jobs.FullText, item *items.Item, text items.PlainText) *Doc {
+// NewDoc creates a document from a job and a page of its item
+func NewDoc(job *jobs.FullText, page int, text items.PlainText) *Doc {
 	return &Doc{
 		JobID:  job.ID,
-		ItemID: item.ID,
+		ItemID: job.ItemID,
+		Page:   page,
 		Text:   text.Text,
 	}
 }
@@ -19,14 +22,16 @@ func NewDoc(job *jobs.FullText, item *items.Item, text items.PlainText) *Doc {
 type Doc struct {
 	JobID  uuid.UUID
 	ItemID string
+	Page   int // The index of the page within the item
 	Text   string
 }
 
 // CSVRow converts a Doc into a format for writing to a CSV.
 func (doc *Doc) CSVRow() []string {
-	out := make([]string, 3)
+	out := make([]string, 4)
 	out[0] = doc.JobID.String()
 	out[1] = doc.ItemID
-	out[2] = doc.Text
+	out[2] = strconv.Itoa(doc.Page)
+	out[3] = doc.Text
 	return out
 }
@@ -2,13 +2,14 @@ package main
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/lmullen/cchc/common/jobs"
-
-	log "github.com/sirupsen/logrus"
+	"github.com/lmullen/cchc/common/logging"
+	"github.com/lmullen/cchc/common/metrics"
 )
 
-func setJobs(jobs []*jobs.FullText, succeed bool) {
+func setJobs(ctx context.Context, jobs []*jobs.FullText, succeed bool) {
 	// Set the job status
 	for _, job := range jobs {
 		if succeed {
@@ -22,8 +23,50 @@ func setJobs(jobs []*jobs.FullText, succeed bool) {
 	for _, job := range jobs {
 		err := app.JobsRepo.SaveFullText(context.TODO(), job)
 		if err != nil {
-			log.WithError(err).WithField("job", job).Error("Error saving job status")
+			logging.From(ctx).WithFields(logging.Job(job)).WithError(err).WithField(logging.FieldStatus, job.Status).Error("Error saving job status")
+			continue
+		}
+		metrics.JobDone(job)
+	}
+
+}
+
+// finishJobs saves the quotations found in a batch and marks its jobs as
+// finished, all in one transaction, so that the jobs only have results if they
+// finished.
+func finishJobs(batch []*jobs.FullText, found []*predictions) error {
+	ctx, cancel := context.WithTimeout(context.Background(), app.Config.JobTimeout)
+	defer cancel()
+
+	tx, err := app.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Roll back the transaction if something goes wrong
+
+	res := app.ResultsRepo.WithTx(tx)
+	for _, p := range found {
+		err = p.save(ctx, res)
+		if err != nil {
+			return fmt.Errorf("Error saving quotations from %s: %w", p.translation, err)
+		}
+	}
+
+	jobsRepo := app.JobsRepo.WithTx(tx)
+	for _, job := range batch {
+		job.Finish()
+		err = jobsRepo.SaveFullText(ctx, job)
+		if err != nil {
+			return fmt.Errorf("Error saving job status: %w", err)
 		}
 	}
 
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	for _, job := range batch {
+		metrics.JobDone(job)
+	}
+	return nil
 }
@@ -12,11 +12,11 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-const queue = "quotations"
-const waittime = 15 * time.Minute
-const jobtimeout = 30 * time.Minute
-const itemsPerBatch = 20
-const pagesPerBatch = 500
+// queue is the destination of the jobs. When looking for quotations of a
+// reference corpus, it is quotations-<corpus>, so that each corpus has its own
+// jobs. When looking for quotations in reference texts other than the default,
+// it is quotations-<fingerprint>, so that each set of texts has its own jobs.
+var queue = "quotations"
 
 var app = &App{}
 
@@ -45,6 +45,7 @@ func main() {
 		log.Fatal("Error initializing application: ", err)
 	}
 	defer app.Shutdown()
+	app.Health.Ready(ctx)
 
 	wg := &sync.WaitGroup{}
 
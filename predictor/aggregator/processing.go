@@ -5,8 +5,10 @@ import (
 	"os/exec"
 	"time"
 
+	"github.com/lmullen/cchc/common/config"
 	"github.com/lmullen/cchc/common/jobs"
-	log "github.com/sirupsen/logrus"
+	"github.com/lmullen/cchc/common/logging"
+	"github.com/lmullen/cchc/common/metrics"
 	"golang.org/x/net/context"
 )
 
@@ -15,21 +17,27 @@ func processBatchOfDocs(ctx context.Context) {
 	case <-ctx.Done():
 		return
 	default:
-		timeout, cancel := context.WithTimeout(ctx, jobtimeout)
+		app.Health.Progress()
+
+		// Every entry logged while processing this batch carries the batch ID
+		ctx, _ := logging.NewBatch(ctx)
+		logger := logging.From(ctx)
+
+		timeout, cancel := context.WithTimeout(ctx, app.Config.JobTimeout)
 		defer cancel()
 
 		// Keep track of the jobs in this batch
-		jobsInBatch := make([]*jobs.FullText, 0, itemsPerBatch)
+		jobsInBatch := make([]*jobs.FullText, 0, app.Config.ItemsPerBatch)
 		// Keep track of the specific pages in this batch
-		docsInBatch := make([]*Doc, 0, itemsPerBatch)
+		docsInBatch := make([]*Doc, 0, app.Config.ItemsPerBatch)
 
-		log.Debugf("Collecting a batch of up to %v items or %v pages", itemsPerBatch, pagesPerBatch)
+		logger.Debugf("Collecting a batch of up to %v items or %v pages", app.Config.ItemsPerBatch, app.Config.PagesPerBatch)
 
 		// We are going to get as many items as we want to form a batch.
 		// Keep in mind that each item may contain many subdocuments.
 		// We will skip jobs if they don't have full text. And if we run to the end
 		// the available batches, we will start early with what we have.
-		for len(jobsInBatch) < itemsPerBatch && len(docsInBatch) < pagesPerBatch {
+		for len(jobsInBatch) < app.Config.ItemsPerBatch && len(docsInBatch) < app.Config.PagesPerBatch {
 			job, err := app.JobsRepo.GetReadyJob(timeout, queue)
 			if err != nil {
 				if err == jobs.ErrNoJobs && len(jobsInBatch) > 0 {
@@ -40,17 +48,17 @@ func processBatchOfDocs(ctx context.Context) {
 				if err == jobs.ErrNoJobs && len(jobsInBatch) == 0 {
 					// There are no more jobs and we have no jobs at all. So wait to check
 					// if there are more jobs to process.
-					log.Infof("No ready jobs, so waiting %s to check again", waittime)
+					logger.Infof("No ready jobs, so waiting %s to check again", app.Config.WaitTime)
 					select {
 					case <-ctx.Done():
 						return
-					case <-time.After(waittime):
-						log.Info("Checking again whether there are jobs to be processed")
+					case <-time.After(app.Config.WaitTime):
+						logger.Info("Checking again whether there are jobs to be processed")
 						return
 					}
 				}
 				// We have an error but it is unanticipated so log it and quit this function
-				log.WithError(err).Error("Error getting a job that is ready")
+				logger.WithError(err).Error("Error getting a job that is ready")
 				return
 			}
 
@@ -58,32 +66,38 @@ func processBatchOfDocs(ctx context.Context) {
 			job.Start()
 			err = app.JobsRepo.SaveFullText(timeout, job)
 			if err != nil {
-				log.WithError(err).WithField("job", job).Error("Error saving job status")
+				logger.WithFields(logging.Job(job)).WithError(err).Error("Error saving job status")
 				continue
 			}
+			metrics.JobClaimed(job)
+			app.Health.Progress()
 
-			// Get the item
-			item, err := app.ItemsRepo.Get(timeout, job.ItemID)
+			// Get the document from its source
+			doc, err := app.Documents.Get(timeout, job.Source, job.ItemID)
 			if err != nil {
-				log.WithError(err).WithField("job", job).Error("Error getting item for job")
+				logger.WithFields(logging.Job(job)).WithError(err).Error("Error getting item for job")
 				job.Fail()
 				err = app.JobsRepo.SaveFullText(timeout, job)
 				if err != nil {
-					log.WithError(err).WithField("job", job).Error("Error saving job status")
+					logger.WithFields(logging.Job(job)).WithError(err).Error("Error saving job status")
+					continue
 				}
+				metrics.JobDone(job)
 				continue
 			}
 
 			// Get the full text for the item
-			pages, has := item.FullText()
+			pages, has := doc.FullText()
 
 			// It's possible we don't have full text. If so, skip the job.
 			if !has {
 				job.Skip()
 				errSave := app.JobsRepo.SaveFullText(ctx, job)
 				if errSave != nil {
-					log.WithError(err).WithField("job", job).Error("Error saving job status")
+					logger.WithFields(logging.Job(job)).WithError(err).Error("Error saving job status")
+					continue
 				}
+				metrics.JobDone(job)
 				continue // Keep going collecting items
 			}
 
@@ -91,66 +105,108 @@ func processBatchOfDocs(ctx context.Context) {
 			jobsInBatch = append(jobsInBatch, job)
 
 			// Add the pages to the batch
-			for _, page := range pages {
-				doc := NewDoc(job, item, page)
-				docsInBatch = append(docsInBatch, doc)
+			for i, page := range pages {
+				docsInBatch = append(docsInBatch, NewDoc(job, i, page))
 			}
 		}
 
 		// At this point we have a complement of jobs, so start the process of running them
-		log.Debugf("Running quotation finder on a batch of %v items and %v pages", len(jobsInBatch), len(docsInBatch))
+		logger.Debugf("Running quotation finder on a batch of %v items and %v pages", len(jobsInBatch), len(docsInBatch))
+		metrics.BatchItems.Observe(float64(len(jobsInBatch)))
+		metrics.BatchPages.Observe(float64(len(docsInBatch)))
 
 		// Write the full text to a temporary CSV
 		docsFile, err := writeDocsCSV(docsInBatch)
 		if err != nil {
-			log.WithError(err).Error("Error writing CSV to send to prediction model")
+			logger.WithError(err).Error("Error writing CSV to send to prediction model")
 		}
 
-		// Create a temp file for output.
-		predictionsFile, err := os.CreateTemp("", "prediction-*.csv")
-		if err != nil {
-			log.WithError(err).Error("Error creating temporary file for predictions")
-		}
-		predictionsFile.Close()
-
-		cmd := exec.CommandContext(timeout,
-			"Rscript", "/predictor/id-quotations.R",
-			"--bible", "bible-payload.rda",
-			"--model", "prediction-payload.rda",
-			"--verbose", "2",
-			"--tokens", "5",
-			"--out", predictionsFile.Name(),
-			// "--potential",
-			docsFile,
-		)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			log.WithError(err).WithField("R-output", string(output)).Error("Problem running prediction model in R")
-			setJobs(jobsInBatch, false)
-			return
+		// Look for quotations from each of the reference texts. Nothing is saved
+		// until the model has run for all of them, so that a batch which fails
+		// leaves no results behind.
+		found := make([]*predictions, 0, len(app.Bibles))
+		for _, bible := range app.Bibles {
+			p, err := findQuotations(ctx, timeout, docsFile, bible)
+			if err != nil {
+				setJobs(ctx, jobsInBatch, false)
+				return
+			}
+			found = append(found, p)
 		}
 
-		// Get the predictions back from a temporary file and write them to the database
-		err = processPredictionsCSV(ctx, predictionsFile.Name())
+		err = finishJobs(jobsInBatch, found)
 		if err != nil {
-			log.WithError(err).Error("Error getting results from prediction model")
-			setJobs(jobsInBatch, false)
+			logger.WithError(err).Error("Error saving quotations")
+			setJobs(ctx, jobsInBatch, false)
 			return
 		}
 
-		setJobs(jobsInBatch, true)
+		// Add the quotations from the finished jobs to the counts by verse and
+		// year. Only biblical quotations are counted.
+		if app.Corpus == "" {
+			counted, err := app.ResultsRepo.RefreshQuotationCounts(timeout, queue)
+			if err != nil {
+				logger.WithError(err).Error("Error refreshing the quotation counts")
+			} else {
+				logger.WithField("jobs", counted).Debug("Refreshed the quotation counts")
+			}
+		}
 
 		// Clean up the temporary files
-		err = os.Remove(predictionsFile.Name())
-		if err != nil {
-			log.WithError(err).Warn("Problem removing the temporary files")
-		}
 		err = os.Remove(docsFile)
 		if err != nil {
-			log.WithError(err).Warn("Problem removing the temporary files")
+			logger.WithError(err).Warn("Problem removing the temporary files")
 		}
 
-		log.Debugf("Finished processing a batch of %v items and %v pages", len(jobsInBatch), len(docsInBatch))
+		logger.Debugf("Finished processing a batch of %v items and %v pages", len(jobsInBatch), len(docsInBatch))
+
+	}
+}
 
+// findQuotations runs the prediction model on a batch of documents for one
+// reference text, and returns the quotations it finds. Errors are logged, since
+// the batch fails as a whole.
+func findQuotations(ctx, timeout context.Context, docsFile string, bible config.Bible) (*predictions, error) {
+	logger := logging.From(ctx).WithField("translation", bible.ID)
+
+	// Create a temp file for output.
+	predictionsFile, err := os.CreateTemp("", "prediction-*.csv")
+	if err != nil {
+		logger.WithError(err).Error("Error creating temporary file for predictions")
+		return nil, err
 	}
+	predictionsFile.Close()
+	defer func() {
+		err := os.Remove(predictionsFile.Name())
+		if err != nil {
+			logger.WithError(err).Warn("Problem removing the temporary files")
+		}
+	}()
+
+	cmd := exec.CommandContext(timeout,
+		"Rscript", "/predictor/id-quotations.R",
+		"--bible", bible.Payload,
+		"--model", "prediction-payload.rda",
+		"--verbose", "2",
+		"--tokens", "5",
+		"--out", predictionsFile.Name(),
+		// "--potential",
+		docsFile,
+	)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	metrics.RscriptDuration.WithLabelValues(metrics.Result(err)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		logger.WithError(err).WithField("R-output", string(output)).Error("Problem running prediction model in R")
+		return nil, err
+	}
+
+	// Get the predictions back from a temporary file
+	p, err := readPredictionsCSV(predictionsFile.Name(), bible.ID)
+	if err != nil {
+		logger.WithError(err).Error("Error getting results from prediction model")
+		return nil, err
+	}
+
+	return p, nil
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// checkpoint records how far the import of a file has progressed, so that an
+// interrupted import can resume where it left off. It is saved in the same
+// transaction as each batch of books.
+type checkpoint struct {
+	Path     string
+	Size     int64
+	Modified time.Time
+	Counts   Counts // Counts.Records is how many records have been read
+	Finished bool
+}
+
+// getCheckpoint gets the checkpoint for a file. If the file has not been
+// imported before, or if it has changed since, the checkpoint starts from the
+// beginning of the file.
+func getCheckpoint(ctx context.Context, path string, size int64, modified time.Time) (*checkpoint, error) {
+	query := `
+	SELECT size, modified, records, imported, changed, text_changed, skipped,
+		duplicates, invalid, bad_dates, missing_text, finished
+	FROM stacks_import_files
+	WHERE path = $1;
+	`
+	// The database only keeps microseconds
+	modified = modified.Truncate(time.Microsecond)
+	fresh := &checkpoint{Path: path, Size: size, Modified: modified}
+
+	c := checkpoint{Path: path}
+	err := db.QueryRow(ctx, query, path).Scan(&c.Size, &c.Modified, &c.Counts.Records,
+		&c.Counts.Imported, &c.Counts.Changed, &c.Counts.TextChanged, &c.Counts.Skipped,
+		&c.Counts.Duplicates, &c.Counts.Invalid, &c.Counts.BadDates, &c.Counts.MissingText,
+		&c.Finished)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fresh, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.Size != size || !c.Modified.Equal(modified) {
+		return fresh, nil
+	}
+	return &c, nil
+}
+
+// save writes the checkpoint as part of a transaction.
+func (c *checkpoint) save(ctx context.Context, tx pgx.Tx) error {
+	query := `
+	INSERT INTO stacks_import_files (path, size, modified, records, imported,
+		changed, text_changed, skipped, duplicates, invalid, bad_dates,
+		missing_text, finished, updated)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW())
+	ON CONFLICT (path) DO UPDATE SET
+		size = EXCLUDED.size,
+		modified = EXCLUDED.modified,
+		records = EXCLUDED.records,
+		imported = EXCLUDED.imported,
+		changed = EXCLUDED.changed,
+		text_changed = EXCLUDED.text_changed,
+		skipped = EXCLUDED.skipped,
+		duplicates = EXCLUDED.duplicates,
+		invalid = EXCLUDED.invalid,
+		bad_dates = EXCLUDED.bad_dates,
+		missing_text = EXCLUDED.missing_text,
+		finished = EXCLUDED.finished,
+		updated = NOW();
+	`
+	_, err := tx.Exec(ctx, query, c.Path, c.Size, c.Modified, c.Counts.Records,
+		c.Counts.Imported, c.Counts.Changed, c.Counts.TextChanged, c.Counts.Skipped,
+		c.Counts.Duplicates, c.Counts.Invalid, c.Counts.BadDates, c.Counts.MissingText,
+		c.Finished)
+	return err
+}
@@ -5,6 +5,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
@@ -18,13 +21,19 @@ var db *pgxpool.Pool
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s <path/to/a/batch.json ...> \n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <path/to/a/batch.json or directory ...> \n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Files can be .json or .jsonl, optionally compressed as .json.gz or .jsonl.gz.")
 		flag.PrintDefaults()
 	}
 	debug := flag.BoolP("debug", "d", false, "Turn on debugging messages")
+	workers := flag.IntP("workers", "w", 4, "Number of files to import at once")
+	batchSize := flag.IntP("batch-size", "b", 500, "Number of books to save in each batch")
+	restart := flag.Bool("restart", false, "Ignore the checkpoints and import every file from the start")
+	update := flag.Bool("update", false, "Update books which are already in the database if they have changed")
+	reportPath := flag.String("report", "", "Also write the validation report as JSON to this file")
 	help := flag.Bool("help", false, "help")
 	flag.Parse()
-	batches := flag.Args()
+	paths := flag.Args()
 
 	if *help {
 		flag.Usage()
@@ -41,13 +50,17 @@ func main() {
 		log.SetLevel(log.InfoLevel)
 	}
 
-	if len(batches) == 0 {
-		log.Error("Provide paths to Stacks JSON files")
+	if len(paths) == 0 {
+		log.Error("Provide paths to Stacks JSON files or directories")
 		flag.Usage()
 		os.Exit(1)
 	}
+	if *workers < 1 || *batchSize < 1 {
+		log.Error("The number of workers and the batch size must be at least 1")
+		os.Exit(1)
+	}
 
-	err := checkPathsToBatches(batches)
+	files, err := findInputs(paths)
 	if err != nil {
 		log.Println("Error with arguments: ", err)
 		os.Exit(1)
@@ -64,9 +77,56 @@ func main() {
 		log.Fatal("Error connecting to database: ", err)
 	}
 	defer db.Close()
+	err = database.CheckSchema(ctx, connstr, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Stop reading new records when interrupted. The batches being saved are
+	// finished, so the import can be resumed from the checkpoints.
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	for _, b := range batches {
-		processBatch(b)
+	imp := &importer{
+		batchSize: *batchSize,
+		restart:   *restart,
+		update:    *update,
+		seen:      &lccnSet{seen: make(map[string]bool)},
+	}
+	reports := make([]*FileReport, len(files))
+	next := make(chan int)
+	wg := &sync.WaitGroup{}
+	for w := 0; w < *workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				reports[i] = imp.importFile(sigCtx, files[i])
+			}
+		}()
+	}
+	for i := range files {
+		if sigCtx.Err() != nil {
+			reports[i] = &FileReport{Path: files[i], Status: statusInterrupted}
+			continue
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	report := newReport(reports)
+	fmt.Println()
+	report.Print(os.Stdout)
+	if *reportPath != "" {
+		err = report.Save(*reportPath)
+		if err != nil {
+			log.WithError(err).Error("Error saving the report")
+			os.Exit(1)
+		}
+	}
+	if report.Failed > 0 {
+		os.Exit(2)
 	}
 
 }
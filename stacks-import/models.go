@@ -1,10 +1,12 @@
 package main
 
 import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
-	"time"
-
-	"golang.org/x/net/context"
+	"errors"
+	"strings"
 )
 
 // Book represents a JSON object in the Stacks export, with metadata and full text
@@ -17,7 +19,7 @@ type Book struct {
 	Publisher       string          `json:"publisher"`
 	Published       json.RawMessage `json:"published"`
 	PublicationDate json.RawMessage `json:"publication_date"`
-	Year            int             `json:"-"`
+	Year            sql.NullInt32   `json:"-"`
 	SubjectFull     []string        `json:"subject_full"`
 	Subject         []string        `json:"subject"`
 	Person          []string        `json:"person"`
@@ -32,60 +34,91 @@ func (b Book) String() string {
 	return b.LCCN
 }
 
-// Exists checks whether a book as already been serialized to the database
-func (b Book) Exists() (bool, error) {
-	query := `SELECT EXISTS (SELECT 1 FROM stacks_books WHERE lccn=$1);`
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	var exists bool
-	err := db.QueryRow(ctx, query, b.LCCN).Scan(&exists)
-	if err != nil {
-		return false, err
-	}
-	return exists, err
-}
-
-// Save serializes a book to the database
-func (b Book) Save() error {
-	query := `
-	INSERT INTO stacks_books(
-		lccn, 
-		isbn,
-		title,
-		publisher,
-		year,
-		subject_full,
-		subject,
-		person, 
-		lang,
-		text) 
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	ON CONFLICT DO NOTHING;
-	`
+// errNoLCCN is returned for a record without an LCCN, which identifies a book.
+var errNoLCCN = errors.New("record has no LCCN")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// bookColumns are the columns of stacks_books which are imported, in the order
+// of the values returned by row.
+var bookColumns = []string{"lccn", "isbn", "title", "publisher", "year",
+	"subject_full", "subject", "person", "lang", "text", "original_metadata",
+	"content_hash", "text_hash"}
 
-	_, err := db.Exec(ctx, query, b.LCCN, b.ISBN, b.Title, b.Publisher,
-		b.Year, b.SubjectFull, b.Subject, b.Person, b.Language, b.Text)
+// parseBook decodes a record from the Stacks export and cleans it up for
+// importing. A book whose publication date cannot be parsed has no year, and a
+// book without text has a NULL text so that no jobs are created for it; the
+// return values say whether either happened.
+func parseBook(record json.RawMessage) (book *Book, badDate, noText bool, err error) {
+	book = &Book{}
+	err = json.Unmarshal(record, book)
+	if err != nil {
+		return nil, false, false, err
+	}
+	book.LCCN = strings.TrimSpace(book.LCCN)
+	if book.LCCN == "" {
+		return nil, false, false, errNoLCCN
+	}
 
-	return err
+	y, err := year(extractDateString(book.PublicationDate))
+	if err != nil {
+		badDate = true
+	} else {
+		book.Year = sql.NullInt32{Int32: int32(y), Valid: true}
+	}
+	noText = strings.TrimSpace(book.Text) == ""
 
+	return book, badDate, noText, nil
 }
 
-// SaveJSON adds the original metadata as JSON to the database
-func (b Book) SaveJSON() error {
-	query := `UPDATE stacks_books SET original_metadata=$1 WHERE lccn=$2`
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	json, err := json.Marshal(b)
+// parseLCCN decodes only the LCCN of a record from the Stacks export.
+func parseLCCN(record json.RawMessage) (string, error) {
+	var r struct {
+		LCCN string `json:"lccn"`
+	}
+	err := json.Unmarshal(record, &r)
 	if err != nil {
-		return err
+		return "", err
+	}
+	lccn := strings.TrimSpace(r.LCCN)
+	if lccn == "" {
+		return "", errNoLCCN
 	}
+	return lccn, nil
+}
 
-	_, err = db.Exec(ctx, query, json, b.LCCN)
+// row returns the values of a book for copying into stacks_books. The original
+// metadata is the record without its text, which is stored separately. The
+// content hash covers the metadata and the text, and the text hash covers only
+// the text, so that an updated export can be compared with the stored books.
+func (b Book) row() ([]interface{}, error) {
+	var year, text, textHash interface{} // NULL unless they are present
+	if b.Year.Valid {
+		year = b.Year.Int32
+	}
+	if strings.TrimSpace(b.Text) != "" {
+		text = b.Text
+		textHash = hash(b.Text)
+	}
+	fullText := b.Text
+	b.Text = ""
+	metadata, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	contentHash := hash(string(metadata), fullText)
+	return []interface{}{b.LCCN, b.ISBN, b.Title, b.Publisher, year,
+		b.SubjectFull, b.Subject, b.Person, b.Language, text, string(metadata),
+		contentHash, textHash}, nil
+}
 
-	return err
+// hash returns the hex-encoded SHA-256 hash of the strings, separated by NUL
+// bytes. The hash of a single string matches sha256() in PostgreSQL.
+func hash(parts ...string) string {
+	h := sha256.New()
+	for i, p := range parts {
+		if i > 0 {
+			h.Write([]byte{0})
+		}
+		h.Write([]byte(p))
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
@@ -1,54 +1,423 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/lmullen/cchc/common/results"
+	"github.com/lmullen/cchc/common/sources"
 	log "github.com/sirupsen/logrus"
 )
 
-func processBatch(path string) {
-	log.WithField("file", path).Info("Processing file")
-	f, err := os.Open(path)
+// batchTimeout is how long saving a single batch of books may take. Batches are
+// saved with their own timeout, so that the batch in progress is finished when
+// the import is interrupted.
+const batchTimeout = 5 * time.Minute
+
+// importer imports files from the Stacks export.
+type importer struct {
+	batchSize int
+	restart   bool // Ignore the checkpoints and import every file from the start
+	update    bool // Update books which are already in the database if they have changed
+	seen      *lccnSet
+}
+
+// lccnSet keeps track of the books in the input, so that duplicates can be
+// found across all the files being imported.
+type lccnSet struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+// add adds an LCCN to the set, returning false if it was already there.
+func (s *lccnSet) add(lccn string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen[lccn] {
+		return false
+	}
+	s.seen[lccn] = true
+	return true
+}
+
+// batch is a batch of books read from a file, along with the outcomes of the
+// records which were read for it. The books have not been saved yet, so the
+// counts of imported and skipped books are filled in when they are.
+type batch struct {
+	books  []*Book
+	counts Counts
+}
+
+// importFile imports a single file, resuming from its checkpoint, and reports
+// on what happened to its records. Reading the file and saving the books
+// happen concurrently.
+func (imp *importer) importFile(ctx context.Context, path string) *FileReport {
+	report := &FileReport{Path: path}
+	logger := log.WithField("file", path)
+	fail := func(err error) *FileReport {
+		report.Status = statusFailed
+		report.Error = err.Error()
+		logger.WithError(err).Error("Error importing file")
+		return report
+	}
+
+	info, err := os.Stat(path)
 	if err != nil {
-		log.WithField("file", path).WithError(err).Error("Error opening file")
+		return fail(err)
+	}
+	timeout, cancel := context.WithTimeout(context.Background(), batchTimeout)
+	c, err := getCheckpoint(timeout, path, info.Size(), info.ModTime())
+	cancel()
+	if err != nil {
+		return fail(fmt.Errorf("Error getting checkpoint: %w", err))
+	}
+	if imp.restart {
+		c = &checkpoint{Path: c.Path, Size: info.Size(), Modified: info.ModTime().Truncate(time.Microsecond)}
+	}
+	report.Counts = c.Counts
+	if c.Finished {
+		report.Status = statusDone
+		logger.Info("Skipping file which was already imported")
+		return report
+	}
+	report.Resumed = c.Counts.Records
+	if report.Resumed > 0 {
+		logger.WithField("records", report.Resumed).Info("Resuming file")
+	} else {
+		logger.Info("Processing file")
 	}
 
-	decoder := json.NewDecoder(f)
+	in, err := openInput(path)
+	if err != nil {
+		return fail(err)
+	}
+	defer in.Close()
 
-	for decoder.More() {
-		var book Book
-		err = decoder.Decode(&book)
+	// Stop reading if saving fails
+	readCtx, stopReading := context.WithCancel(ctx)
+	defer stopReading()
+	batches := make(chan *batch, 1)
+	var readErr error
+	go func() {
+		defer close(batches)
+		readErr = imp.readBatches(readCtx, in, path, c.Counts.Records, report, batches)
+	}()
+
+	for b := range batches {
+		err = imp.saveBatch(c, b)
 		if err != nil {
-			log.Error(err)
-			break
+			stopReading()
+			for range batches {
+				// Let the reader finish
+			}
+			return fail(fmt.Errorf("Error saving books: %w", err))
+		}
+		report.Counts = c.Counts
+		logger.WithField("records", c.Counts.Records).Debug("Saved batch of books")
+	}
+
+	if readErr != nil {
+		if ctx.Err() != nil {
+			report.Status = statusInterrupted
+			report.Error = "The import was stopped; run it again to resume"
+			return report
 		}
+		return fail(readErr)
+	}
+
+	c.Finished = true
+	timeout, cancel = context.WithTimeout(context.Background(), batchTimeout)
+	defer cancel()
+	tx, err := db.Begin(timeout)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback(timeout)
+	err = c.save(timeout, tx)
+	if err == nil {
+		err = tx.Commit(timeout)
+	}
+	if err != nil {
+		return fail(fmt.Errorf("Error saving checkpoint: %w", err))
+	}
 
-		book.Year, err = year(extractDateString(book.PublicationDate))
+	report.Status = statusImported
+	logger.WithField("imported", c.Counts.Imported).Info("Finished file")
+	return report
+}
+
+// readBatches reads the records in a file after the first start records, and
+// sends them in batches to be saved. Problems with the records are counted in
+// the batches and noted in the report. The LCCNs of the first start records are
+// still read, so that duplicates of them are found as they would be if the file
+// had been read in one run.
+func (imp *importer) readBatches(ctx context.Context, r io.Reader, path string, start int,
+	report *FileReport, out chan<- *batch) error {
+	next := newRecordReader(r, path)
+	b := &batch{}
+	send := func() error {
+		select {
+		case out <- b:
+			b = &batch{}
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for n := 1; ; n++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		record, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
 		if err != nil {
-			log.WithField("book", book).WithError(err).Warn("Error parsing date")
+			// The rest of the file cannot be read, but save what has been read
+			sendErr := send()
+			if sendErr != nil {
+				return sendErr
+			}
+			return fmt.Errorf("Record %d cannot be read, so the rest of the file was not imported: %w", n, err)
+		}
+		if n <= start {
+			// The record was read by an earlier run, but a later record might
+			// still be a duplicate of it
+			lccn, err := parseLCCN(record)
+			if err == nil {
+				imp.seen.add(lccn)
+			}
+			continue
+		}
+
+		b.counts.Records++
+		book, badDate, noText, err := parseBook(record)
+		switch {
+		case err != nil:
+			b.counts.Invalid++
+			report.problem(n, "", err.Error())
+		case !imp.seen.add(book.LCCN):
+			b.counts.Duplicates++
+			report.problem(n, book.LCCN, "duplicate of an earlier record")
+		default:
+			if badDate {
+				b.counts.BadDates++
+				report.problem(n, book.LCCN, fmt.Sprintf("publication date cannot be parsed: %s", book.PublicationDate))
+			}
+			if noText {
+				b.counts.MissingText++
+				report.problem(n, book.LCCN, "book has no text")
+			}
+			b.books = append(b.books, book)
+		}
+
+		if len(b.books) >= imp.batchSize {
+			err = send()
+			if err != nil {
+				return err
+			}
 		}
+	}
+
+	// The last batch might not have any books, but it still has the counts
+	return send()
+}
+
+// newRecordReader returns a function which reads the next record from a file.
+// A JSON Lines file is read a line at a time, so a record which is not valid
+// JSON is counted as invalid. Other files are read as a stream of JSON objects,
+// so such a record stops the file.
+func newRecordReader(r io.Reader, path string) func() (json.RawMessage, error) {
+	if strings.HasSuffix(strings.TrimSuffix(strings.ToLower(path), ".gz"), ".jsonl") {
+		br := bufio.NewReader(r)
+		return func() (json.RawMessage, error) {
+			for {
+				line, err := br.ReadBytes('\n')
+				line = bytes.TrimSpace(line)
+				if len(line) > 0 {
+					return line, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	dec := json.NewDecoder(r)
+	return func() (json.RawMessage, error) {
+		if !dec.More() {
+			return nil, io.EOF
+		}
+		var record json.RawMessage
+		err := dec.Decode(&record)
+		return record, err
+	}
+}
+
+// saveBatch copies a batch of books into the database and saves the checkpoint
+// in the same transaction. Books which are already in the database are skipped,
+// unless updating, in which case those which have changed are updated. The
+// checkpoint is only updated if the batch is saved.
+func (imp *importer) saveBatch(c *checkpoint, b *batch) error {
+	ctx, cancel := context.WithTimeout(context.Background(), batchTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Roll back the transaction if something goes wrong
 
-		exists, err := book.Exists()
+	counts := b.counts
+	if len(b.books) > 0 {
+		_, err = tx.Exec(ctx, `CREATE TEMPORARY TABLE stacks_import (LIKE stacks_books) ON COMMIT DROP;`)
 		if err != nil {
-			log.Error(err)
-			break
+			return err
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"stacks_import"}, bookColumns,
+			pgx.CopyFromSlice(len(b.books), func(i int) ([]interface{}, error) {
+				return b.books[i].row()
+			}))
+		if err != nil {
+			return err
 		}
-		if !exists {
-			log.WithField("book", book).Debug("Saving book to the database")
-			err := book.Save()
+		if imp.update {
+			counts.Changed, counts.TextChanged, err = updateBooks(ctx, tx)
 			if err != nil {
-				log.WithField("book", book).WithError(err).Error("Error saving to database")
+				return err
 			}
-			book.Text = ""
-			err = book.SaveJSON()
+		}
+		tag, err := tx.Exec(ctx, `
+		INSERT INTO stacks_books (`+strings.Join(bookColumns, ", ")+`)
+		SELECT `+strings.Join(bookColumns, ", ")+` FROM stacks_import
+		ON CONFLICT (lccn) DO NOTHING;`)
+		if err != nil {
+			return err
+		}
+		counts.Imported = int(tag.RowsAffected())
+		counts.Skipped = len(b.books) - counts.Imported - counts.Changed
+	}
+
+	next := *c
+	next.Counts.add(counts)
+	err = next.save(ctx, tx)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	*c = next
+	return nil
+}
+
+// updateBooks updates the books in the stacks_import table which are already in
+// the database but whose content hash differs. Books whose text has changed
+// have their jobs and results removed first, so that the services will process
+// them again. It returns the number of books which were changed, and of those,
+// the number whose text changed.
+func updateBooks(ctx context.Context, tx pgx.Tx) (changed, textChanged int, err error) {
+	err = tx.QueryRow(ctx, `
+	SELECT COUNT(*)
+	FROM stacks_import i
+	JOIN stacks_books b ON b.lccn = i.lccn
+	WHERE b.text_hash IS DISTINCT FROM i.text_hash;`).Scan(&textChanged)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Error comparing texts: %w", err)
+	}
+
+	if textChanged > 0 {
+		rows, err := tx.Query(ctx, `
+		SELECT j.id
+		FROM jobs.fulltext j
+		JOIN stacks_import i ON i.lccn = j.item_id
+		JOIN stacks_books b ON b.lccn = i.lccn
+		WHERE j.source = $1 AND b.text_hash IS DISTINCT FROM i.text_hash
+		FOR UPDATE OF j;`, sources.Stacks)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Error getting jobs for changed books: %w", err)
+		}
+		var jobs []uuid.UUID
+		for rows.Next() {
+			var id uuid.UUID
+			err = rows.Scan(&id)
 			if err != nil {
-				log.WithField("book", book).WithError(err).Error("Error saving book JSON to database")
+				rows.Close()
+				return 0, 0, fmt.Errorf("Error getting jobs for changed books: %w", err)
 			}
+			jobs = append(jobs, id)
+		}
+		rows.Close()
+		if rows.Err() != nil {
+			return 0, 0, fmt.Errorf("Error getting jobs for changed books: %w", rows.Err())
+		}
+		err = results.DeleteJobs(ctx, tx, jobs)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Error removing jobs for changed books: %w", err)
+		}
+	}
+
+	var set []string
+	for _, col := range bookColumns[1:] { // Every column but the LCCN
+		set = append(set, col+" = i."+col)
+	}
+	tag, err := tx.Exec(ctx, `
+	UPDATE stacks_books b
+	SET `+strings.Join(set, ", ")+`, updated = NOW()
+	FROM stacks_import i
+	WHERE b.lccn = i.lccn AND b.content_hash IS DISTINCT FROM i.content_hash;`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Error updating changed books: %w", err)
+	}
+	return int(tag.RowsAffected()), textChanged, nil
+}
 
-		} else {
-			log.WithField("book", book).Warn("Skipping book is already in database")
+// input is a file from the Stacks export, which might be compressed.
+type input struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// openInput opens a file for reading, decompressing it if its name ends in .gz.
+func openInput(path string) (*input, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	in := &input{Reader: f, closers: []io.Closer{f}}
+	if strings.HasSuffix(strings.ToLower(path), ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("Error decompressing file: %w", err)
 		}
+		in.Reader = gz
+		in.closers = append([]io.Closer{gz}, in.closers...)
 	}
+	return in, nil
+}
 
+// Close closes the decompressor, if any, and the file.
+func (in *input) Close() error {
+	var err error
+	for _, c := range in.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
 }
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.json", "a.jsonl.gz", "notes.txt", "sub/c.json.gz"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+
+	files, err := findInputs([]string{dir, filepath.Join(dir, "b.json")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.jsonl.gz"),
+		filepath.Join(dir, "b.json"),
+		filepath.Join(dir, "sub/c.json.gz"),
+	}, files)
+
+	_, err = findInputs([]string{filepath.Join(dir, "notes.txt")})
+	assert.Error(t, err)
+	_, err = findInputs([]string{filepath.Join(dir, "missing.json")})
+	assert.Error(t, err)
+}
+
+func TestParseBook(t *testing.T) {
+	book, badDate, noText, err := parseBook(json.RawMessage(`{"lccn": " 2001012345 ", "publication_date": "1865-04-15T00:00:00Z", "text_en": "Four score"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "2001012345", book.LCCN)
+	assert.Equal(t, int32(1865), book.Year.Int32)
+	assert.False(t, badDate)
+	assert.False(t, noText)
+
+	book, badDate, noText, err = parseBook(json.RawMessage(`{"lccn": "2001012346", "publication_date": "unknown"}`))
+	require.NoError(t, err)
+	assert.False(t, book.Year.Valid)
+	assert.True(t, badDate)
+	assert.True(t, noText)
+	row, err := book.row()
+	require.NoError(t, err)
+	assert.Nil(t, row[4], "the year is NULL")
+	assert.Nil(t, row[9], "the text is NULL")
+
+	_, _, _, err = parseBook(json.RawMessage(`{"title": "No LCCN"}`))
+	assert.ErrorIs(t, err, errNoLCCN)
+	_, _, _, err = parseBook(json.RawMessage(`{"lccn": 2001012347}`))
+	assert.Error(t, err)
+	_, _, _, err = parseBook(json.RawMessage(`{"lccn": `))
+	assert.Error(t, err)
+}
+
+func TestReadBatchesResumed(t *testing.T) {
+	records := strings.Join([]string{
+		`{"lccn": "1", "publication_date": "1850-01-01T00:00:00Z", "text_en": "First"}`,
+		`{"lccn": "2", "publication_date": "1851-01-01T00:00:00Z", "text_en": "Second"}`,
+		`{"lccn": " 1 ", "publication_date": "1850-01-01T00:00:00Z", "text_en": "First, again"}`,
+		`{"lccn": "3", "publication_date": "1852-01-01T00:00:00Z", "text_en": "Third"}`,
+	}, "\n")
+
+	// Resuming after the first two records still finds the duplicate of the
+	// first one
+	imp := &importer{batchSize: 10, seen: &lccnSet{seen: make(map[string]bool)}}
+	report := &FileReport{}
+	out := make(chan *batch, 1)
+	require.NoError(t, imp.readBatches(context.Background(), strings.NewReader(records), "books.jsonl", 2, report, out))
+	b := <-out
+
+	assert.Equal(t, 2, b.counts.Records)
+	assert.Equal(t, 1, b.counts.Duplicates)
+	require.Len(t, b.books, 1)
+	assert.Equal(t, "3", b.books[0].LCCN)
+	require.Len(t, report.Problems, 1)
+	assert.Equal(t, Problem{Record: 3, LCCN: "1", Problem: "duplicate of an earlier record"}, report.Problems[0])
+}
+
+func TestBookHashes(t *testing.T) {
+	hashes := func(record string) (content, text interface{}) {
+		book, _, _, err := parseBook(json.RawMessage(record))
+		require.NoError(t, err)
+		row, err := book.row()
+		require.NoError(t, err)
+		return row[11], row[12]
+	}
+
+	content, text := hashes(`{"lccn": "1", "title": "Sermons", "text_en": "abc"}`)
+	// The text hash matches encode(sha256(convert_to(text, 'UTF8')), 'hex')
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", text)
+
+	retitled, sameText := hashes(`{"lccn": "1", "title": "Sermons, corrected", "text_en": "abc"}`)
+	assert.NotEqual(t, content, retitled)
+	assert.Equal(t, text, sameText)
+
+	edited, newText := hashes(`{"lccn": "1", "title": "Sermons", "text_en": "abcd"}`)
+	assert.NotEqual(t, content, edited)
+	assert.NotEqual(t, text, newText)
+
+	_, noText := hashes(`{"lccn": "1", "title": "Sermons"}`)
+	assert.Nil(t, noText)
+}
+
+// readAll reads every record until the end of the input or an error
+func readAll(next func() (json.RawMessage, error)) ([]string, error) {
+	var records []string
+	for {
+		r, err := next()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, string(r))
+	}
+}
+
+func TestRecordReader(t *testing.T) {
+	// A JSON Lines file keeps going past a bad line
+	lines := "{\"lccn\": \"1\"}\n\n{\"lccn\": \n{\"lccn\": \"3\"}"
+	records, err := readAll(newRecordReader(strings.NewReader(lines), "batch.jsonl"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"lccn": "1"}`, `{"lccn":`, `{"lccn": "3"}`}, records)
+
+	// A stream of JSON objects stops at a syntax error
+	stream := `{"lccn": "1"} {"lccn": "2"}
+	{"lccn": } {"lccn": "4"}`
+	records, err = readAll(newRecordReader(strings.NewReader(stream), "batch.json"))
+	assert.Error(t, err)
+	assert.Len(t, records, 2)
+}
+
+func TestOpenInputGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "batch.jsonl.gz")
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte("{\"lccn\": \"1\"}\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+
+	in, err := openInput(path)
+	require.NoError(t, err)
+	defer in.Close()
+	records, err := readAll(newRecordReader(in, path))
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"lccn": "1"}`}, records)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// maxProblems is how many problem records are listed in the report for each
+// file. Every problem is still counted.
+const maxProblems = 20
+
+// Statuses of a file in the report
+const (
+	statusImported    = "imported"
+	statusDone        = "already imported"
+	statusInterrupted = "interrupted"
+	statusFailed      = "failed"
+)
+
+// Counts are the outcomes of the records read from the Stacks export.
+type Counts struct {
+	Records     int `json:"records"`      // Records read, including the problems
+	Imported    int `json:"imported"`     // New books saved to the database
+	Changed     int `json:"changed"`      // Books which were updated because they had changed
+	TextChanged int `json:"text_changed"` // Changed books whose text changed, so their jobs were removed
+	Skipped     int `json:"skipped"`      // Books which were already in the database, unchanged if updating
+	Duplicates  int `json:"duplicates"`   // Books which appeared earlier in the input
+	Invalid     int `json:"invalid"`      // Records which could not be decoded or had no LCCN
+	BadDates    int `json:"bad_dates"`    // Books whose publication date could not be parsed
+	MissingText int `json:"missing_text"` // Books without any text
+}
+
+func (c *Counts) add(other Counts) {
+	c.Records += other.Records
+	c.Imported += other.Imported
+	c.Changed += other.Changed
+	c.TextChanged += other.TextChanged
+	c.Skipped += other.Skipped
+	c.Duplicates += other.Duplicates
+	c.Invalid += other.Invalid
+	c.BadDates += other.BadDates
+	c.MissingText += other.MissingText
+}
+
+// Problem is a record which was invalid or had data which could not be used.
+type Problem struct {
+	Record  int    `json:"record"` // The position of the record in the file, from 1
+	LCCN    string `json:"lccn,omitempty"`
+	Problem string `json:"problem"`
+}
+
+// FileReport is the outcome of importing a single file. The counts include any
+// earlier runs which were resumed, but the problems only include this run.
+type FileReport struct {
+	Path     string    `json:"path"`
+	Status   string    `json:"status"`
+	Resumed  int       `json:"resumed_at,omitempty"` // Records already read by an earlier run
+	Error    string    `json:"error,omitempty"`
+	Counts   Counts    `json:"counts"`
+	Problems []Problem `json:"problems,omitempty"`
+}
+
+func (r *FileReport) problem(record int, lccn, problem string) {
+	if len(r.Problems) < maxProblems {
+		r.Problems = append(r.Problems, Problem{Record: record, LCCN: lccn, Problem: problem})
+	}
+}
+
+// Report is the validation report for an import.
+type Report struct {
+	Files  []*FileReport `json:"files"`
+	Totals Counts        `json:"totals"`
+	Failed int           `json:"failed"` // Files which could not be finished
+}
+
+// newReport totals the reports for each file.
+func newReport(files []*FileReport) *Report {
+	r := &Report{Files: files}
+	for _, f := range files {
+		r.Totals.add(f.Counts)
+		if f.Status == statusFailed || f.Status == statusInterrupted {
+			r.Failed++
+		}
+	}
+	return r
+}
+
+// Print writes the report as a table for each file, then lists the problems.
+func (r *Report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tSTATUS\tRECORDS\tIMPORTED\tCHANGED\tTEXT CHANGED\tSKIPPED\tDUPLICATES\tINVALID\tBAD DATES\tMISSING TEXT")
+	for _, f := range r.Files {
+		printCounts(tw, f.Path, f.Status, f.Counts)
+	}
+	printCounts(tw, "TOTAL", fmt.Sprintf("%d failed", r.Failed), r.Totals)
+	tw.Flush()
+
+	for _, f := range r.Files {
+		if f.Error == "" && len(f.Problems) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s\n", f.Path)
+		if f.Resumed > 0 {
+			fmt.Fprintf(w, "  Resumed after record %d\n", f.Resumed)
+		}
+		if f.Error != "" {
+			fmt.Fprintf(w, "  Error: %s\n", f.Error)
+		}
+		for _, p := range f.Problems {
+			fmt.Fprintf(w, "  Record %d", p.Record)
+			if p.LCCN != "" {
+				fmt.Fprintf(w, " (%s)", p.LCCN)
+			}
+			fmt.Fprintf(w, ": %s\n", p.Problem)
+		}
+	}
+}
+
+func printCounts(w io.Writer, name, status string, c Counts) {
+	fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n", name, status, c.Records,
+		c.Imported, c.Changed, c.TextChanged, c.Skipped, c.Duplicates, c.Invalid, c.BadDates, c.MissingText)
+}
+
+// Save writes the report as JSON.
+func (r *Report) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(r)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
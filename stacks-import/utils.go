@@ -3,32 +3,88 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 )
 
-// Check that there is no problem with reading the files passed in
-func checkPathsToBatches(paths []string) error {
-	for _, path := range paths {
-		_, err := os.Stat(path)
-		if os.IsNotExist(err) {
-			return err
-		} else if filepath.Ext(path) != ".json" {
-			err := fmt.Errorf("Error: %s is not a .json file", path)
-			return err
-		} else if err != nil {
+// isInput checks whether a file is one of the kinds of files in the Stacks
+// export: JSON or JSON Lines, either of which might be compressed with gzip.
+func isInput(path string) bool {
+	ext := filepath.Ext(strings.TrimSuffix(strings.ToLower(path), ".gz"))
+	return ext == ".json" || ext == ".jsonl"
+}
+
+// findInputs gets the files to import from the paths passed in. A directory is
+// searched for the files in it, including in its subdirectories, but a file
+// passed in directly has to be one which can be imported. The paths are made
+// absolute, since they identify the files in the checkpoints.
+func findInputs(paths []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) error {
+		abs, err := filepath.Abs(path)
+		if err != nil {
 			return err
 		}
+		if !seen[abs] {
+			files = append(files, abs)
+			seen[abs] = true
+		}
+		return nil
 	}
-	return nil
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if !isInput(path) {
+				return nil, fmt.Errorf("%s is not a .json, .jsonl, .json.gz, or .jsonl.gz file", path)
+			}
+			err = add(path)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var found []string
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && isInput(p) {
+				found = append(found, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("%s does not have any files to import", path)
+		}
+		sort.Strings(found)
+		for _, p := range found {
+			err = add(p)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
 }
 
+// datePattern matches a date in the publication date of a book
+var datePattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+
 func extractDateString(j json.RawMessage) string {
-	s := string(j)
-	re := regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
-	return re.FindString(s)
+	return datePattern.FindString(string(j))
 }
 
 func year(date string) (int, error) {